@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/cloudtrust/common-service/v2/database (interfaces: EventStorer)
+//
+// Generated by this command:
+//
+//	mockgen --build_flags=--mod=mod -destination=./mock/database.go -package=mock -mock_names=EventStorer=EventStorer github.com/cloudtrust/common-service/v2/database EventStorer
+//
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// EventStorer is a mock of EventStorer interface.
+type EventStorer struct {
+	ctrl     *gomock.Controller
+	recorder *EventStorerMockRecorder
+	isgomock struct{}
+}
+
+// EventStorerMockRecorder is the mock recorder for EventStorer.
+type EventStorerMockRecorder struct {
+	mock *EventStorer
+}
+
+// NewEventStorer creates a new mock instance.
+func NewEventStorer(ctrl *gomock.Controller) *EventStorer {
+	mock := &EventStorer{ctrl: ctrl}
+	mock.recorder = &EventStorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *EventStorer) EXPECT() *EventStorerMockRecorder {
+	return m.recorder
+}
+
+// Store mocks base method.
+func (m *EventStorer) Store(arg0 context.Context, arg1 map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Store", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Store indicates an expected call of Store.
+func (mr *EventStorerMockRecorder) Store(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Store", reflect.TypeOf((*EventStorer)(nil).Store), arg0, arg1)
+}
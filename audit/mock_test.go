@@ -0,0 +1,5 @@
+package audit
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/database.go -package=mock -mock_names=EventStorer=EventStorer github.com/cloudtrust/common-service/v2/database EventStorer
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/events.go -package=mock -mock_names=Producer=Producer github.com/cloudtrust/common-service/v2/events Producer
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/logging.go -package=mock -mock_names=Logger=Logger github.com/cloudtrust/common-service/v2/log Logger
@@ -0,0 +1,189 @@
+// Package audit provides a single audit reporter interface whose implementations send the events to the audit database,
+// to Kafka, to the logs or to several of them (for instance, to dual-write during a migration from the database to Kafka)
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	cs "github.com/cloudtrust/common-service/v2"
+	"github.com/cloudtrust/common-service/v2/database"
+	"github.com/cloudtrust/common-service/v2/events"
+	"github.com/cloudtrust/common-service/v2/log"
+)
+
+// Event is an audit event, independent of the sink it is reported to
+type Event struct {
+	Time            time.Time
+	Origin          string
+	Type            string
+	AgentRealmName  string
+	AgentUserID     string
+	AgentUsername   string
+	TargetRealmName string
+	TargetUserID    string
+	TargetUsername  string
+	KcEventType     string
+	KcOperationType string
+	ClientID        string
+	// Details are additional information, stored in additional_info by the database sink. The keys of the audit table
+	// columns (user_id, ct_event_type, ...) are reserved: all the sinks reject them with database.ErrInvalidAuditEvent
+	Details map[string]string
+}
+
+// checkDetails checks that the details don't use a reserved key, so that an event is accepted or rejected by all sinks
+func (e Event) checkDetails() error {
+	for _, key := range slices.Sorted(maps.Keys(e.Details)) {
+		if database.IsReservedDetailKey(key) {
+			return fmt.Errorf("%w: reserved key %s can't be used as a detail", database.ErrInvalidAuditEvent, key)
+		}
+	}
+	return nil
+}
+
+// NewEvent creates an event occurring now
+func NewEvent(origin string, eventType string) Event {
+	return Event{
+		Time:    time.Now().UTC(),
+		Origin:  origin,
+		Type:    eventType,
+		Details: map[string]string{},
+	}
+}
+
+// NewEventFromContext creates an event occurring now. The agent is the user of the context
+func NewEventFromContext(ctx context.Context, origin string, eventType string) Event {
+	var event = NewEvent(origin, eventType)
+	event.AgentRealmName, _ = ctx.Value(cs.CtContextRealm).(string)
+	event.AgentUserID, _ = ctx.Value(cs.CtContextUserID).(string)
+	event.AgentUsername, _ = ctx.Value(cs.CtContextUsername).(string)
+	return event
+}
+
+// Reporter reports audit events to a sink
+type Reporter interface {
+	ReportEvent(ctx context.Context, event Event) error
+}
+
+type dbReporter struct {
+	storer database.EventStorer
+}
+
+// NewDBReporter creates a reporter storing the events in the audit table through the given storer (usually a
+// database.EventsDBModule)
+func NewDBReporter(storer database.EventStorer) Reporter {
+	return &dbReporter{storer: storer}
+}
+
+func (r *dbReporter) ReportEvent(ctx context.Context, event Event) error {
+	if err := event.checkDetails(); err != nil {
+		return err
+	}
+	var builder = database.NewAuditEvent(event.Type).
+		AuditTime(event.Time).
+		Origin(event.Origin).
+		Agent(event.AgentRealmName, event.AgentUserID, event.AgentUsername).
+		TargetUser(event.TargetRealmName, event.TargetUserID, event.TargetUsername).
+		KeycloakEvent(event.KcEventType, event.KcOperationType).
+		Client(event.ClientID)
+	for key, value := range event.Details {
+		builder.Detail(key, value)
+	}
+	return builder.Report(ctx, r.storer)
+}
+
+type kafkaReporter struct {
+	reporter events.AuditEventsReporterModule
+}
+
+// NewKafkaReporter creates a reporter sending the events to Kafka through the given audit events reporter. Failures are
+// logged by the events reporter and are not returned: only the invalid events are rejected
+func NewKafkaReporter(reporter events.AuditEventsReporterModule) Reporter {
+	return &kafkaReporter{reporter: reporter}
+}
+
+func (r *kafkaReporter) ReportEvent(ctx context.Context, event Event) error {
+	if err := event.checkDetails(); err != nil {
+		return err
+	}
+	var details = make(map[string]string, len(event.Details)+3)
+	for key, value := range event.Details {
+		details[key] = value
+	}
+	for key, value := range map[string]string{
+		events.CtEventKcEventType:     event.KcEventType,
+		events.CtEventKcOperationType: event.KcOperationType,
+		events.CtEventClientID:        event.ClientID,
+	} {
+		if value != "" {
+			details[key] = value
+		}
+	}
+
+	var kafkaEvent events.Event
+	if event.TargetUserID != "" || event.TargetUsername != "" {
+		kafkaEvent = events.NewEventOnUser(event.Origin, event.Type, event.AgentRealmName, event.AgentUserID, event.AgentUsername,
+			event.TargetRealmName, event.TargetUserID, event.TargetUsername, details)
+	} else {
+		kafkaEvent = events.NewEvent(event.Origin, event.Type, event.AgentRealmName, event.AgentUserID, event.AgentUsername,
+			event.TargetRealmName, details)
+	}
+	r.reporter.ReportEvent(ctx, kafkaEvent.WithTime(event.Time))
+	return nil
+}
+
+type logReporter struct {
+	logger log.Logger
+}
+
+// NewLogReporter creates a reporter writing the events in the logs
+func NewLogReporter(logger log.Logger) Reporter {
+	return &logReporter{logger: logger}
+}
+
+func (r *logReporter) ReportEvent(ctx context.Context, event Event) error {
+	if err := event.checkDetails(); err != nil {
+		return err
+	}
+	var keyvals = []any{"msg", "Audit event", "audit_time", event.Time.UTC().Format(database.AuditTimeFormat), "origin", event.Origin,
+		"ct_event_type", event.Type, "agent_realm_name", event.AgentRealmName, "agent_user_id", event.AgentUserID,
+		"agent_username", event.AgentUsername, "target_realm_name", event.TargetRealmName, "target_user_id", event.TargetUserID,
+		"target_username", event.TargetUsername}
+	var optional = []string{"kc_event_type", event.KcEventType, "kc_operation_type", event.KcOperationType, "client_id", event.ClientID}
+	for i := 0; i < len(optional); i += 2 {
+		if optional[i+1] != "" {
+			keyvals = append(keyvals, optional[i], optional[i+1])
+		}
+	}
+	if len(event.Details) > 0 {
+		keyvals = append(keyvals, "details", event.Details)
+	}
+	r.logger.Info(ctx, keyvals...)
+	return nil
+}
+
+type fanOutReporter struct {
+	reporters []Reporter
+	logger    log.Logger
+}
+
+// NewFanOutReporter creates a reporter sending the events to all the given reporters. A failing reporter does not
+// prevent the next ones from being called: errors are logged and returned together
+func NewFanOutReporter(logger log.Logger, reporters ...Reporter) Reporter {
+	return &fanOutReporter{reporters: reporters, logger: logger}
+}
+
+func (r *fanOutReporter) ReportEvent(ctx context.Context, event Event) error {
+	var errs []error
+	for _, reporter := range r.reporters {
+		if err := reporter.ReportEvent(ctx, event); err != nil {
+			r.logger.Warn(ctx, "msg", "Can't report audit event", "ct_event_type", event.Type, "err", err.Error())
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,220 @@
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	cs "github.com/cloudtrust/common-service/v2"
+	"github.com/cloudtrust/common-service/v2/audit/mock"
+	"github.com/cloudtrust/common-service/v2/database"
+	"github.com/cloudtrust/common-service/v2/events"
+	"github.com/cloudtrust/common-service/v2/events/fb"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func createTestEvent() Event {
+	var event = NewEvent("back-office", "UPDATE_USER")
+	event.Time = time.Date(2024, time.March, 5, 10, 20, 30, 123000000, time.UTC)
+	event.AgentRealmName = "master"
+	event.AgentUserID = "agent-id"
+	event.AgentUsername = "agent"
+	event.TargetRealmName = "customer"
+	event.TargetUserID = "user-id"
+	event.TargetUsername = "user"
+	event.ClientID = "client"
+	event.Details["field"] = "email"
+	return event
+}
+
+func TestNewEventFromContext(t *testing.T) {
+	var ctx = context.WithValue(context.Background(), cs.CtContextRealm, "master")
+	ctx = context.WithValue(ctx, cs.CtContextUserID, "agent-id")
+	ctx = context.WithValue(ctx, cs.CtContextUsername, "agent")
+
+	var event = NewEventFromContext(ctx, "back-office", "UPDATE_USER")
+	assert.Equal(t, "back-office", event.Origin)
+	assert.Equal(t, "UPDATE_USER", event.Type)
+	assert.Equal(t, "master", event.AgentRealmName)
+	assert.Equal(t, "agent-id", event.AgentUserID)
+	assert.Equal(t, "agent", event.AgentUsername)
+	assert.False(t, event.Time.IsZero())
+	assert.NotNil(t, event.Details)
+}
+
+func TestDBReporter(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockStorer = mock.NewEventStorer(mockCtrl)
+	var reporter = NewDBReporter(mockStorer)
+	var ctx = context.TODO()
+
+	t.Run("Event is mapped to the audit columns", func(t *testing.T) {
+		mockStorer.EXPECT().Store(ctx, map[string]string{
+			"ct_event_type":    "UPDATE_USER",
+			"origin":           "back-office",
+			"audit_time":       "2024-03-05 10:20:30.123",
+			"agent_realm_name": "master",
+			"agent_user_id":    "agent-id",
+			"agent_username":   "agent",
+			"realm_name":       "customer",
+			"user_id":          "user-id",
+			"username":         "user",
+			"client_id":        "client",
+			"field":            "email",
+		}).Return(nil)
+		assert.Nil(t, reporter.ReportEvent(ctx, createTestEvent()))
+	})
+
+	t.Run("Store fails", func(t *testing.T) {
+		var storeErr = errors.New("store error")
+		mockStorer.EXPECT().Store(ctx, gomock.Any()).Return(storeErr)
+		assert.Equal(t, storeErr, reporter.ReportEvent(ctx, createTestEvent()))
+	})
+
+	t.Run("Details can't override the audit columns", func(t *testing.T) {
+		var event = createTestEvent()
+		event.Details["user_id"] = "other-user"
+		var err = reporter.ReportEvent(ctx, event)
+		assert.ErrorIs(t, err, database.ErrInvalidAuditEvent)
+	})
+}
+
+func TestKafkaReporter(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockProducer = mock.NewProducer(mockCtrl)
+	var mockLogger = mock.NewLogger(mockCtrl)
+	var reporter = NewKafkaReporter(events.NewAuditEventReporterModule(mockProducer, mockLogger))
+	var ctx = context.TODO()
+
+	var decode = func(t *testing.T, content []byte) (*fb.CloudtrustEvent, map[string]string) {
+		var bytes, err = base64.StdEncoding.DecodeString(string(content))
+		assert.Nil(t, err)
+		var event = fb.GetRootAsCloudtrustEvent(bytes, 0)
+		var details = map[string]string{}
+		for i := 0; i < event.DetailsLength(); i++ {
+			var tuple = new(fb.Tuple)
+			event.Details(tuple, i)
+			details[string(tuple.Key())] = string(tuple.Value())
+		}
+		return event, details
+	}
+
+	t.Run("Event on a user", func(t *testing.T) {
+		var input = createTestEvent()
+		mockProducer.EXPECT().SendPartitionedMessageBytes("agent-id", gomock.Any()).DoAndReturn(func(_ string, content []byte) error {
+			var event, details = decode(t, content)
+			assert.Equal(t, input.Time.UnixMilli(), event.Time())
+			assert.Equal(t, "back-office", string(event.Origin()))
+			assert.Equal(t, "UPDATE_USER", string(event.CtEventType()))
+			assert.Equal(t, "master", details[events.CtEventAgentRealmName])
+			assert.Equal(t, "customer", details[events.CtEventTargetRealmName])
+			assert.Equal(t, "user-id", details[events.CtEventTargetUserID])
+			assert.Equal(t, "user", details[events.CtEventTargetUsername])
+			assert.Equal(t, "client", details[events.CtEventClientID])
+			assert.Equal(t, "email", details["field"])
+			assert.NotContains(t, details, events.CtEventKcEventType)
+			return nil
+		})
+		assert.Nil(t, reporter.ReportEvent(ctx, input))
+	})
+
+	t.Run("Event without target user", func(t *testing.T) {
+		var input = NewEvent("back-office", "UPDATE_REALM")
+		input.AgentUserID = "agent-id"
+		input.TargetRealmName = "customer"
+		mockProducer.EXPECT().SendPartitionedMessageBytes("agent-id", gomock.Any()).DoAndReturn(func(_ string, content []byte) error {
+			var _, details = decode(t, content)
+			assert.Equal(t, "customer", details[events.CtEventTargetRealmName])
+			assert.NotContains(t, details, events.CtEventTargetUserID)
+			return nil
+		})
+		assert.Nil(t, reporter.ReportEvent(ctx, input))
+	})
+
+	t.Run("Details can't override the audit columns", func(t *testing.T) {
+		var event = createTestEvent()
+		event.Details[events.CtEventKcEventType] = "LOGIN"
+		var err = reporter.ReportEvent(ctx, event)
+		assert.ErrorIs(t, err, database.ErrInvalidAuditEvent)
+	})
+}
+
+func TestLogReporter(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockLogger = mock.NewLogger(mockCtrl)
+	var reporter = NewLogReporter(mockLogger)
+	var ctx = context.TODO()
+
+	mockLogger.EXPECT().Info(ctx, "msg", "Audit event", "audit_time", "2024-03-05 10:20:30.123", "origin", "back-office",
+		"ct_event_type", "UPDATE_USER", "agent_realm_name", "master", "agent_user_id", "agent-id", "agent_username", "agent",
+		"target_realm_name", "customer", "target_user_id", "user-id", "target_username", "user", "client_id", "client",
+		"details", map[string]string{"field": "email"})
+	assert.Nil(t, reporter.ReportEvent(ctx, createTestEvent()))
+
+	var invalid = createTestEvent()
+	invalid.Details["user_id"] = "other-user"
+	assert.ErrorIs(t, reporter.ReportEvent(ctx, invalid), database.ErrInvalidAuditEvent)
+}
+
+type reporterFunc func(ctx context.Context, event Event) error
+
+func (f reporterFunc) ReportEvent(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+func TestFanOutReporter(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockLogger = mock.NewLogger(mockCtrl)
+	var ctx = context.TODO()
+	var event = createTestEvent()
+
+	var calls []string
+	var succeeding = func(name string) Reporter {
+		return reporterFunc(func(_ context.Context, e Event) error {
+			assert.Equal(t, event, e)
+			calls = append(calls, name)
+			return nil
+		})
+	}
+	var failing = func(name string, err error) Reporter {
+		return reporterFunc(func(_ context.Context, _ Event) error {
+			calls = append(calls, name)
+			return err
+		})
+	}
+
+	t.Run("All sinks succeed", func(t *testing.T) {
+		calls = nil
+		var reporter = NewFanOutReporter(mockLogger, succeeding("db"), succeeding("kafka"))
+		assert.Nil(t, reporter.ReportEvent(ctx, event))
+		assert.Equal(t, []string{"db", "kafka"}, calls)
+	})
+
+	t.Run("A failing sink does not prevent the next ones", func(t *testing.T) {
+		calls = nil
+		var dbErr = errors.New("db error")
+		var kafkaErr = errors.New("kafka error")
+		var reporter = NewFanOutReporter(mockLogger, failing("db", dbErr), succeeding("log"), failing("kafka", kafkaErr))
+		mockLogger.EXPECT().Warn(ctx, "msg", "Can't report audit event", "ct_event_type", "UPDATE_USER", "err", gomock.Any()).Times(2)
+
+		var err = reporter.ReportEvent(ctx, event)
+		assert.ErrorIs(t, err, dbErr)
+		assert.ErrorIs(t, err, kafkaErr)
+		assert.Equal(t, []string{"db", "log", "kafka"}, calls)
+	})
+
+	t.Run("No sink", func(t *testing.T) {
+		assert.Nil(t, NewFanOutReporter(mockLogger).ReportEvent(ctx, event))
+	})
+}
@@ -0,0 +1,296 @@
+package configuration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudtrust/common-service/v2/database/sqlretry"
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+)
+
+const (
+	insertAuthzStmt = `INSERT INTO authorizations (realm_id, group_name, action, target_realm_id, target_group_name) SELECT ?, ?, ?, ?, ? FROM DUAL
+		WHERE NOT EXISTS (SELECT 1 FROM authorizations WHERE realm_id = ? AND group_name = ? AND action = ? AND target_realm_id <=> ? AND target_group_name <=> ?)`
+	deleteAuthzStmt       = `DELETE FROM authorizations WHERE realm_id = ?`
+	deleteGroupAuthzStmt  = `DELETE FROM authorizations WHERE ((realm_id = ? AND group_name = ?) OR (target_realm_id = ? AND target_group_name = ?))`
+	deleteGroupRightsStmt = `DELETE FROM authorizations WHERE realm_id = ? AND group_name = ?`
+	selectAuthzExistsStmt = `SELECT 1 FROM authorizations WHERE realm_id = ? AND group_name = ? AND action = ? AND target_realm_id <=> ? AND target_group_name <=> ? LIMIT 1`
+)
+
+// Types of the audit events reported by the authorization management of ConfigurationWriterDBModule
+const (
+	EventCreateAuthorizations      = "CREATE_AUTHORIZATIONS"
+	EventDeleteAuthorizations      = "DELETE_AUTHORIZATIONS"
+	EventUpdateGroupAuthorizations = "UPDATE_GROUP_AUTHORIZATIONS"
+)
+
+var (
+	// ErrInvalidAuthorization is returned when an authorization or a filter misses a mandatory field
+	ErrInvalidAuthorization = errors.New("invalid authorization")
+	// ErrAuthorizationOutOfScope is returned when an authorization action is not in the scope of the module
+	ErrAuthorizationOutOfScope = errors.New("authorization action out of scope")
+)
+
+// CreateAuthorizations inserts the given authorizations. The ones which already exist are ignored: the count reported in
+// the audit event only includes the created authorizations
+func (c *ConfigurationWriterDBModule) CreateAuthorizations(ctx context.Context, authz []Authorization) error {
+	if len(authz) == 0 {
+		return nil
+	}
+	if err := c.checkAuthorizations(authz); err != nil {
+		return err
+	}
+	var count int64
+	var err = sqlretry.WithTransaction(ctx, c.db, nil, func(tx sqltypes.Transaction) error {
+		var err error
+		count, err = c.insertAuthorizations(ctx, tx, authz)
+		return err
+	})
+	if err != nil {
+		c.logger.Warn(ctx, "msg", "Can't create authorizations", "err", err.Error())
+		return err
+	}
+	c.reportEvent(ctx, EventCreateAuthorizations, "count", strconv.FormatInt(count, 10))
+	c.notifyChange(ctx, ChangeAuthorizations, authorizationsRealm(authz))
+	return nil
+}
+
+// DeleteAuthorizations deletes the authorizations matching the filter. RealmID is mandatory, the other nil fields of the
+// filter match any value. Only the authorizations in the scope of the module are deleted
+func (c *ConfigurationWriterDBModule) DeleteAuthorizations(ctx context.Context, filter Authorization) error {
+	if filter.RealmID == nil {
+		return fmt.Errorf("%w: missing realm_id in filter", ErrInvalidAuthorization)
+	}
+	if filter.Action != nil && !c.isInAuthorizationScope(*filter.Action) {
+		return fmt.Errorf("%w: %s", ErrAuthorizationOutOfScope, *filter.Action)
+	}
+
+	var query = deleteAuthzStmt
+	var args = []any{*filter.RealmID}
+	for _, criterion := range []struct {
+		column string
+		value  *string
+	}{
+		{"group_name", filter.GroupName},
+		{"action", filter.Action},
+		{"target_realm_id", filter.TargetRealmID},
+		{"target_group_name", filter.TargetGroupName},
+	} {
+		if criterion.value != nil {
+			query += " AND " + criterion.column + " = ?"
+			args = append(args, *criterion.value)
+		}
+	}
+	var scopeClause, scopeArgs = c.authorizationScopeClause()
+
+	var count int64
+	var err = sqlretry.WithTransaction(ctx, c.db, nil, func(tx sqltypes.Transaction) error {
+		var err error
+		count, err = c.deleteAuthorizations(ctx, tx, query+scopeClause, append(args, scopeArgs...)...)
+		return err
+	})
+	if err != nil {
+		c.logger.Warn(ctx, "msg", "Can't delete authorizations", "realmID", *filter.RealmID, "err", err.Error())
+		return err
+	}
+	c.reportEvent(ctx, EventDeleteAuthorizations, "realm_id", *filter.RealmID, "count", strconv.FormatInt(count, 10))
+	c.notifyChange(ctx, ChangeAuthorizations, *filter.RealmID)
+	return nil
+}
+
+// DeleteAllAuthorizationsOfGroup deletes the authorizations given to a group and the ones targeting it, for instance
+// when the group is deleted. Only the authorizations in the scope of the module are deleted
+func (c *ConfigurationWriterDBModule) DeleteAllAuthorizationsOfGroup(ctx context.Context, realmID string, groupName string) error {
+	var scopeClause, scopeArgs = c.authorizationScopeClause()
+	var args = append([]any{realmID, groupName, realmID, groupName}, scopeArgs...)
+
+	var count int64
+	var err = sqlretry.WithTransaction(ctx, c.db, nil, func(tx sqltypes.Transaction) error {
+		var err error
+		count, err = c.deleteAuthorizations(ctx, tx, deleteGroupAuthzStmt+scopeClause, args...)
+		return err
+	})
+	if err != nil {
+		c.logger.Warn(ctx, "msg", "Can't delete authorizations of group", "realmID", realmID, "group", groupName, "err", err.Error())
+		return err
+	}
+	c.reportEvent(ctx, EventDeleteAuthorizations, "realm_id", realmID, "group_name", groupName, "count", strconv.FormatInt(count, 10))
+	c.notifyChange(ctx, ChangeAuthorizations, realmID)
+	return nil
+}
+
+// ReplaceAuthorizationsForGroup replaces, in a single transaction, the authorizations given to a group by the given
+// ones. All of them must be given to this group and duplicates are ignored. Authorizations out of the scope of the module
+// are kept
+func (c *ConfigurationWriterDBModule) ReplaceAuthorizationsForGroup(ctx context.Context, realmID string, groupName string, authz []Authorization) error {
+	if err := c.checkAuthorizations(authz); err != nil {
+		return err
+	}
+	for _, authorization := range authz {
+		if *authorization.RealmID != realmID || *authorization.GroupName != groupName {
+			return fmt.Errorf("%w: authorization given to %s/%s instead of %s/%s", ErrInvalidAuthorization,
+				*authorization.RealmID, *authorization.GroupName, realmID, groupName)
+		}
+	}
+
+	var scopeClause, scopeArgs = c.authorizationScopeClause()
+	var count int64
+	var err = sqlretry.WithTransaction(ctx, c.db, nil, func(tx sqltypes.Transaction) error {
+		var _, err = c.deleteAuthorizations(ctx, tx, deleteGroupRightsStmt+scopeClause, append([]any{realmID, groupName}, scopeArgs...)...)
+		if err != nil {
+			return err
+		}
+		count, err = c.insertAuthorizations(ctx, tx, authz)
+		return err
+	})
+	if err != nil {
+		c.logger.Warn(ctx, "msg", "Can't replace authorizations of group", "realmID", realmID, "group", groupName, "err", err.Error())
+		return err
+	}
+	c.reportEvent(ctx, EventUpdateGroupAuthorizations, "realm_id", realmID, "group_name", groupName, "count", strconv.FormatInt(count, 10))
+	c.notifyChange(ctx, ChangeAuthorizations, realmID)
+	return nil
+}
+
+// AuthorizationExists checks if the given authorization exists. Authorizations out of the scope of the module are
+// considered as missing
+func (c *ConfigurationWriterDBModule) AuthorizationExists(ctx context.Context, authz Authorization) (bool, error) {
+	if err := checkAuthorization(authz); err != nil {
+		return false, err
+	}
+	if !c.isInAuthorizationScope(*authz.Action) {
+		return false, nil
+	}
+
+	var found int
+	var err = c.db.QueryRowContext(ctx, selectAuthzExistsStmt, *authz.RealmID, *authz.GroupName, *authz.Action,
+		authz.TargetRealmID, authz.TargetGroupName).Scan(&found)
+	switch err {
+	case nil:
+		return true, nil
+	case sql.ErrNoRows:
+		return false, nil
+	default:
+		c.logger.Warn(ctx, "msg", "Can't check authorization", "err", err.Error())
+		return false, err
+	}
+}
+
+func (c *ConfigurationWriterDBModule) isInAuthorizationScope(action string) bool {
+	return isInAuthorizationScope(c.authScope, action)
+}
+
+// authorizationScopeClause returns the condition restricting a statement to the actions of the scope
+func (c *ConfigurationWriterDBModule) authorizationScopeClause() (string, []any) {
+	if c.authScope == nil {
+		return "", nil
+	}
+	if len(c.authScope) == 0 {
+		return " AND FALSE", nil
+	}
+	var actions = make([]string, 0, len(c.authScope))
+	for action := range c.authScope {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	var args = make([]any, len(actions))
+	for i, action := range actions {
+		args[i] = action
+	}
+	return " AND action IN (?" + strings.Repeat(", ?", len(actions)-1) + ")", args
+}
+
+func (c *ConfigurationWriterDBModule) checkAuthorizations(authz []Authorization) error {
+	for _, authorization := range authz {
+		if err := checkAuthorization(authorization); err != nil {
+			return err
+		}
+		if !c.isInAuthorizationScope(*authorization.Action) {
+			return fmt.Errorf("%w: %s", ErrAuthorizationOutOfScope, *authorization.Action)
+		}
+	}
+	return nil
+}
+
+func checkAuthorization(authz Authorization) error {
+	switch {
+	case authz.RealmID == nil:
+		return fmt.Errorf("%w: missing realm_id", ErrInvalidAuthorization)
+	case authz.GroupName == nil:
+		return fmt.Errorf("%w: missing group_name", ErrInvalidAuthorization)
+	case authz.Action == nil:
+		return fmt.Errorf("%w: missing action", ErrInvalidAuthorization)
+	case authz.TargetGroupName != nil && authz.TargetRealmID == nil:
+		return fmt.Errorf("%w: target_group_name requires target_realm_id", ErrInvalidAuthorization)
+	}
+	return nil
+}
+
+// authorizationsRealm returns the realm of the given authorizations, or an empty string if they belong to several realms
+func authorizationsRealm(authz []Authorization) string {
+	var realmID = *authz[0].RealmID
+	for _, authorization := range authz[1:] {
+		if *authorization.RealmID != realmID {
+			return ""
+		}
+	}
+	return realmID
+}
+
+// authorizationKey identifies an authorization. A nil target is distinct from an empty one
+type authorizationKey struct {
+	realmID, groupName, action         string
+	targetRealmID, targetGroupName     string
+	hasTargetRealm, hasTargetGroupName bool
+}
+
+func newAuthorizationKey(authz Authorization) authorizationKey {
+	var key = authorizationKey{realmID: *authz.RealmID, groupName: *authz.GroupName, action: *authz.Action}
+	if authz.TargetRealmID != nil {
+		key.targetRealmID, key.hasTargetRealm = *authz.TargetRealmID, true
+	}
+	if authz.TargetGroupName != nil {
+		key.targetGroupName, key.hasTargetGroupName = *authz.TargetGroupName, true
+	}
+	return key
+}
+
+// insertAuthorizations inserts the given authorizations, ignoring the duplicates of the batch and the ones which already
+// exist, and returns the number of created authorizations. Existing ones are searched with <=> as the targets can be NULL
+func (c *ConfigurationWriterDBModule) insertAuthorizations(ctx context.Context, tx sqltypes.Transaction, authz []Authorization) (int64, error) {
+	var count int64
+	var inserted = make(map[authorizationKey]bool, len(authz))
+	for _, authorization := range authz {
+		var key = newAuthorizationKey(authorization)
+		if inserted[key] {
+			continue
+		}
+		inserted[key] = true
+
+		var values = []any{*authorization.RealmID, *authorization.GroupName, *authorization.Action, authorization.TargetRealmID,
+			authorization.TargetGroupName}
+		var res, err = tx.ExecContext(ctx, insertAuthzStmt, append(values, values...)...)
+		if err != nil {
+			return count, err
+		}
+		created, err := res.RowsAffected()
+		if err != nil {
+			return count, err
+		}
+		count += created
+	}
+	return count, nil
+}
+
+func (c *ConfigurationWriterDBModule) deleteAuthorizations(ctx context.Context, tx sqltypes.Transaction, query string, args ...any) (int64, error) {
+	var res, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,297 @@
+package configuration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudtrust/common-service/v2/database/dbtest"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuthorization(realmID, groupName, action string, targetRealmID, targetGroupName *string) Authorization {
+	return Authorization{
+		RealmID:         &realmID,
+		GroupName:       &groupName,
+		Action:          &action,
+		TargetRealmID:   targetRealmID,
+		TargetGroupName: targetGroupName,
+	}
+}
+
+func TestCheckAuthorization(t *testing.T) {
+	var value = "value"
+
+	assert.Nil(t, checkAuthorization(newAuthorization("realm", "group", "action", nil, nil)))
+	assert.Nil(t, checkAuthorization(newAuthorization("realm", "group", "action", &value, nil)))
+	assert.True(t, errors.Is(checkAuthorization(Authorization{GroupName: &value, Action: &value}), ErrInvalidAuthorization))
+	assert.True(t, errors.Is(checkAuthorization(Authorization{RealmID: &value, Action: &value}), ErrInvalidAuthorization))
+	assert.True(t, errors.Is(checkAuthorization(Authorization{RealmID: &value, GroupName: &value}), ErrInvalidAuthorization))
+	assert.True(t, errors.Is(checkAuthorization(newAuthorization("realm", "group", "action", nil, &value)), ErrInvalidAuthorization))
+}
+
+func TestAuthorizationScopeClause(t *testing.T) {
+	var clause, args = NewConfigurationWriterDBModule(nil, nil, "", nil).authorizationScopeClause()
+	assert.Equal(t, "", clause)
+	assert.Nil(t, args)
+
+	clause, args = NewConfigurationWriterDBModule(nil, nil, "", nil, []string{"b", "a"}, []string{"c"}).authorizationScopeClause()
+	assert.Equal(t, " AND action IN (?, ?, ?)", clause)
+	assert.Equal(t, []any{"a", "b", "c"}, args)
+
+	clause, args = NewConfigurationWriterDBModule(nil, nil, "", nil, []string{}).authorizationScopeClause()
+	assert.Equal(t, " AND FALSE", clause)
+	assert.Nil(t, args)
+}
+
+func TestCreateAuthorizations(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var reporter = &recordingReporter{}
+	var module = NewConfigurationWriterDBModule(db, reporter, "back-office", log.NewNopLogger(), []string{"MGMT_GetUser", "MGMT_DeleteUser"})
+	var ctx = context.TODO()
+	var targetRealm = "target"
+	var authz = []Authorization{
+		newAuthorization("realm", "group", "MGMT_GetUser", nil, nil),
+		newAuthorization("realm", "group", "MGMT_DeleteUser", &targetRealm, nil),
+	}
+
+	t.Run("Create authorizations", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On("INSERT INTO authorizations").WithArgs("realm", "group", "MGMT_GetUser", (*string)(nil), (*string)(nil),
+			"realm", "group", "MGMT_GetUser", (*string)(nil), (*string)(nil)).WithRowsAffected(1)
+		db.On("INSERT INTO authorizations").WithArgs("realm", "group", "MGMT_DeleteUser", &targetRealm, (*string)(nil),
+			"realm", "group", "MGMT_DeleteUser", &targetRealm, (*string)(nil)).WithRowsAffected(1)
+
+		assert.Nil(t, module.CreateAuthorizations(ctx, authz))
+		var inserts = db.StatementsMatching("INSERT INTO authorizations")
+		assert.Len(t, inserts, 2)
+		assert.Contains(t, inserts[0].Query, "WHERE NOT EXISTS")
+		assert.Contains(t, inserts[0].Query, "target_realm_id <=> ? AND target_group_name <=> ?")
+		assert.True(t, db.Transactions()[0].IsCommitted())
+		assert.Equal(t, []reportedEvent{{eventType: EventCreateAuthorizations, origin: "back-office",
+			values: []string{"count", "2"}}}, reporter.events)
+	})
+
+	t.Run("Existing and duplicated authorizations are ignored", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		// The authorization without target already exists
+		db.On("INSERT INTO authorizations").WithArgs("realm", "group", "MGMT_GetUser", (*string)(nil), (*string)(nil),
+			"realm", "group", "MGMT_GetUser", (*string)(nil), (*string)(nil)).WithRowsAffected(0)
+		db.On("INSERT INTO authorizations").WithRowsAffected(1)
+
+		var empty = ""
+		assert.Nil(t, module.CreateAuthorizations(ctx, append(authz, authz[0], authz[1],
+			newAuthorization("realm", "group", "MGMT_GetUser", &empty, nil))))
+		var inserts = db.StatementsMatching("INSERT INTO authorizations")
+		assert.Len(t, inserts, 3)
+		assert.Equal(t, &empty, inserts[2].Args[3])
+		assert.True(t, db.Transactions()[0].IsCommitted())
+		assert.Equal(t, []reportedEvent{{eventType: EventCreateAuthorizations, origin: "back-office",
+			values: []string{"count", "2"}}}, reporter.events)
+	})
+
+	t.Run("Deadlock is retried", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On("INSERT INTO authorizations").WithError(errors.New("Error 1213 (40001): Deadlock found when trying to get lock")).Once()
+		db.On("INSERT INTO authorizations").WithRowsAffected(1)
+
+		assert.Nil(t, module.CreateAuthorizations(ctx, authz))
+		assert.Len(t, db.Transactions(), 2)
+		assert.True(t, db.Transactions()[1].IsCommitted())
+		assert.Equal(t, []reportedEvent{{eventType: EventCreateAuthorizations, origin: "back-office",
+			values: []string{"count", "2"}}}, reporter.events)
+	})
+
+	t.Run("Nothing to create", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+
+		assert.Nil(t, module.CreateAuthorizations(ctx, nil))
+		assert.Len(t, db.Transactions(), 0)
+		assert.Len(t, reporter.events, 0)
+	})
+
+	t.Run("Action out of scope", func(t *testing.T) {
+		db.Reset()
+
+		var err = module.CreateAuthorizations(ctx, append(authz, newAuthorization("realm", "group", "MGMT_UpdateUser", nil, nil)))
+		assert.True(t, errors.Is(err, ErrAuthorizationOutOfScope))
+		assert.Len(t, db.Statements(), 0)
+	})
+
+	t.Run("Insert fails", func(t *testing.T) {
+		var dbErr = errors.New("db error")
+		db.Reset()
+		reporter.events = nil
+		db.On("INSERT INTO authorizations").WithError(dbErr)
+
+		assert.Equal(t, dbErr, module.CreateAuthorizations(ctx, authz))
+		assert.True(t, db.Transactions()[0].IsRolledBack())
+		assert.Len(t, reporter.events, 0)
+	})
+}
+
+func TestDeleteAuthorizations(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var reporter = &recordingReporter{}
+	var module = NewConfigurationWriterDBModule(db, reporter, "back-office", log.NewNopLogger(), []string{"MGMT_GetUser"})
+	var ctx = context.TODO()
+	var realmID = "realm"
+	var groupName = "group"
+	var targetRealm = "target"
+
+	t.Run("Delete matching authorizations", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On(`DELETE FROM authorizations WHERE realm_id = \? AND group_name = \? AND target_realm_id = \? AND action IN \(\?\)`).
+			WithArgs(realmID, groupName, targetRealm, "MGMT_GetUser").WithRowsAffected(3)
+
+		assert.Nil(t, module.DeleteAuthorizations(ctx, Authorization{RealmID: &realmID, GroupName: &groupName, TargetRealmID: &targetRealm}))
+		assert.True(t, db.Transactions()[0].IsCommitted())
+		assert.Equal(t, []reportedEvent{{eventType: EventDeleteAuthorizations, origin: "back-office",
+			values: []string{"realm_id", realmID, "count", "3"}}}, reporter.events)
+	})
+
+	t.Run("Missing realm", func(t *testing.T) {
+		db.Reset()
+
+		assert.True(t, errors.Is(module.DeleteAuthorizations(ctx, Authorization{GroupName: &groupName}), ErrInvalidAuthorization))
+		assert.Len(t, db.Statements(), 0)
+	})
+
+	t.Run("Action out of scope", func(t *testing.T) {
+		var action = "MGMT_DeleteUser"
+		db.Reset()
+
+		assert.True(t, errors.Is(module.DeleteAuthorizations(ctx, Authorization{RealmID: &realmID, Action: &action}), ErrAuthorizationOutOfScope))
+		assert.Len(t, db.Statements(), 0)
+	})
+}
+
+func TestDeleteAllAuthorizationsOfGroup(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var reporter = &recordingReporter{}
+	var module = NewConfigurationWriterDBModule(db, reporter, "back-office", log.NewNopLogger())
+	var ctx = context.TODO()
+
+	t.Run("Delete authorizations of group", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On("DELETE FROM authorizations WHERE \\(\\(realm_id").WithArgs("realm", "group", "realm", "group").WithRowsAffected(2)
+
+		assert.Nil(t, module.DeleteAllAuthorizationsOfGroup(ctx, "realm", "group"))
+		assert.NotContains(t, db.Statements()[0].Query, "action IN")
+		assert.Equal(t, []reportedEvent{{eventType: EventDeleteAuthorizations, origin: "back-office",
+			values: []string{"realm_id", "realm", "group_name", "group", "count", "2"}}}, reporter.events)
+	})
+
+	t.Run("Delete fails", func(t *testing.T) {
+		var dbErr = errors.New("db error")
+		db.Reset()
+		reporter.events = nil
+		db.On("DELETE FROM authorizations").WithError(dbErr)
+
+		assert.Equal(t, dbErr, module.DeleteAllAuthorizationsOfGroup(ctx, "realm", "group"))
+		assert.True(t, db.Transactions()[0].IsRolledBack())
+		assert.Len(t, reporter.events, 0)
+	})
+}
+
+func TestReplaceAuthorizationsForGroup(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var reporter = &recordingReporter{}
+	var module = NewConfigurationWriterDBModule(db, reporter, "back-office", log.NewNopLogger(), []string{"MGMT_GetUser"})
+	var ctx = context.TODO()
+	var authz = []Authorization{newAuthorization("realm", "group", "MGMT_GetUser", nil, nil)}
+
+	t.Run("Replace authorizations", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On(`DELETE FROM authorizations WHERE realm_id = \? AND group_name = \? AND action IN \(\?\)`).WithArgs("realm", "group", "MGMT_GetUser")
+		db.On("INSERT INTO authorizations").WithRowsAffected(1)
+
+		assert.Nil(t, module.ReplaceAuthorizationsForGroup(ctx, "realm", "group", authz))
+		var statements = db.Statements()
+		assert.Len(t, statements, 2)
+		assert.Equal(t, statements[0].Tx, statements[1].Tx)
+		assert.True(t, db.Transactions()[0].IsCommitted())
+		assert.Equal(t, []reportedEvent{{eventType: EventUpdateGroupAuthorizations, origin: "back-office",
+			values: []string{"realm_id", "realm", "group_name", "group", "count", "1"}}}, reporter.events)
+	})
+
+	t.Run("Remove all authorizations", func(t *testing.T) {
+		db.Reset()
+		db.On("DELETE FROM authorizations")
+
+		assert.Nil(t, module.ReplaceAuthorizationsForGroup(ctx, "realm", "group", nil))
+		assert.Len(t, db.Statements(), 1)
+	})
+
+	t.Run("Authorization of another group", func(t *testing.T) {
+		db.Reset()
+
+		var err = module.ReplaceAuthorizationsForGroup(ctx, "realm", "other-group", authz)
+		assert.True(t, errors.Is(err, ErrInvalidAuthorization))
+		assert.Len(t, db.Statements(), 0)
+	})
+
+	t.Run("Insert fails", func(t *testing.T) {
+		var dbErr = errors.New("db error")
+		db.Reset()
+		reporter.events = nil
+		db.On("DELETE FROM authorizations")
+		db.On("INSERT INTO authorizations").WithError(dbErr)
+
+		assert.Equal(t, dbErr, module.ReplaceAuthorizationsForGroup(ctx, "realm", "group", authz))
+		assert.True(t, db.Transactions()[0].IsRolledBack())
+		assert.Len(t, reporter.events, 0)
+	})
+}
+
+func TestAuthorizationExists(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var module = NewConfigurationWriterDBModule(db, &recordingReporter{}, "back-office", log.NewNopLogger(), []string{"MGMT_GetUser"})
+	var ctx = context.TODO()
+	var targetRealm = "target"
+	var authz = newAuthorization("realm", "group", "MGMT_GetUser", &targetRealm, nil)
+
+	t.Run("Authorization exists", func(t *testing.T) {
+		db.Reset()
+		db.On("SELECT 1 FROM authorizations").WithArgs("realm", "group", "MGMT_GetUser", &targetRealm, (*string)(nil)).WithRows([]any{1})
+
+		var exists, err = module.AuthorizationExists(ctx, authz)
+		assert.Nil(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("Authorization does not exist", func(t *testing.T) {
+		db.Reset()
+		db.On("SELECT 1 FROM authorizations")
+
+		var exists, err = module.AuthorizationExists(ctx, authz)
+		assert.Nil(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("Action out of scope", func(t *testing.T) {
+		db.Reset()
+
+		var exists, err = module.AuthorizationExists(ctx, newAuthorization("realm", "group", "MGMT_DeleteUser", nil, nil))
+		assert.Nil(t, err)
+		assert.False(t, exists)
+		assert.Len(t, db.Statements(), 0)
+	})
+
+	t.Run("Query fails", func(t *testing.T) {
+		var dbErr = errors.New("db error")
+		db.Reset()
+		db.On("SELECT 1 FROM authorizations").WithError(dbErr)
+
+		var _, err = module.AuthorizationExists(ctx, authz)
+		assert.Equal(t, dbErr, err)
+	})
+}
@@ -0,0 +1,196 @@
+package configuration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/metrics"
+)
+
+// Names of the counters published by the CachedConfigurationReader. Values are labelled with the cached item
+// (configuration, admin_configuration or configurations)
+const (
+	CounterConfigurationCacheHits   = "configuration_cache_hits"
+	CounterConfigurationCacheMisses = "configuration_cache_misses"
+
+	cachedItemLabel     = "item"
+	cachedConfiguration = "configuration"
+	cachedAdminConfig   = "admin_configuration"
+	cachedBothConfigs   = "configurations"
+	defaultCacheTTL     = time.Minute
+)
+
+// RealmConfigurationReader reads the configurations of a realm. It is implemented by ConfigurationReaderDBModule
+type RealmConfigurationReader interface {
+	GetRealmConfigurations(ctx context.Context, realmID string) (RealmConfiguration, RealmAdminConfiguration, error)
+	GetConfiguration(ctx context.Context, realmID string) (RealmConfiguration, error)
+	GetAdminConfiguration(ctx context.Context, realmID string) (RealmAdminConfiguration, error)
+}
+
+// CacheOptions configures a CachedConfigurationReader
+type CacheOptions struct {
+	// TTL is the time a configuration is kept in the cache. Defaults to 1 minute
+	TTL time.Duration
+	// RealmTTL overrides TTL for some realms
+	RealmTTL map[string]time.Duration
+	// NegativeTTL is the time a missing configuration (sql.ErrNoRows) is kept in the cache. Defaults to TTL
+	NegativeTTL time.Duration
+}
+
+type cacheKey struct {
+	item    string
+	realmID string
+}
+
+type cacheEntry struct {
+	config      RealmConfiguration
+	adminConfig RealmAdminConfiguration
+	err         error
+	expiresAt   time.Time
+}
+
+type pendingLoad struct {
+	done  chan struct{}
+	entry cacheEntry
+}
+
+// CachedConfigurationReader is a RealmConfigurationReader keeping the configurations of the realms in memory. Concurrent
+// misses for the same realm share a single load and missing configurations are cached as well. Returned configurations
+// are shared between the callers and must not be modified
+type CachedConfigurationReader struct {
+	reader  RealmConfigurationReader
+	options CacheOptions
+	hits    metrics.Counter
+	misses  metrics.Counter
+	now     func() time.Time
+
+	mutex   sync.Mutex
+	entries map[cacheKey]cacheEntry
+	pending map[cacheKey]*pendingLoad
+	// generation is incremented on invalidation so that loads started before are not cached
+	generation map[string]uint64
+}
+
+// NewCachedConfigurationReader creates a caching decorator for the given configuration reader
+func NewCachedConfigurationReader(reader RealmConfigurationReader, options CacheOptions, m metrics.Metrics) *CachedConfigurationReader {
+	if options.TTL <= 0 {
+		options.TTL = defaultCacheTTL
+	}
+	if options.NegativeTTL <= 0 {
+		options.NegativeTTL = options.TTL
+	}
+	return &CachedConfigurationReader{
+		reader:     reader,
+		options:    options,
+		hits:       m.NewCounter(CounterConfigurationCacheHits),
+		misses:     m.NewCounter(CounterConfigurationCacheMisses),
+		now:        time.Now,
+		entries:    make(map[cacheKey]cacheEntry),
+		pending:    make(map[cacheKey]*pendingLoad),
+		generation: make(map[string]uint64),
+	}
+}
+
+// GetRealmConfigurations returns both configuration and admin configuration of a realm
+func (c *CachedConfigurationReader) GetRealmConfigurations(ctx context.Context, realmID string) (RealmConfiguration, RealmAdminConfiguration, error) {
+	var entry, err = c.get(ctx, cacheKey{item: cachedBothConfigs, realmID: realmID}, func() cacheEntry {
+		var config, adminConfig, err = c.reader.GetRealmConfigurations(ctx, realmID)
+		return cacheEntry{config: config, adminConfig: adminConfig, err: err}
+	})
+	return entry.config, entry.adminConfig, err
+}
+
+// GetConfiguration returns a realm configuration
+func (c *CachedConfigurationReader) GetConfiguration(ctx context.Context, realmID string) (RealmConfiguration, error) {
+	var entry, err = c.get(ctx, cacheKey{item: cachedConfiguration, realmID: realmID}, func() cacheEntry {
+		var config, err = c.reader.GetConfiguration(ctx, realmID)
+		return cacheEntry{config: config, err: err}
+	})
+	return entry.config, err
+}
+
+// GetAdminConfiguration returns a realm admin configuration
+func (c *CachedConfigurationReader) GetAdminConfiguration(ctx context.Context, realmID string) (RealmAdminConfiguration, error) {
+	var entry, err = c.get(ctx, cacheKey{item: cachedAdminConfig, realmID: realmID}, func() cacheEntry {
+		var adminConfig, err = c.reader.GetAdminConfiguration(ctx, realmID)
+		return cacheEntry{adminConfig: adminConfig, err: err}
+	})
+	return entry.adminConfig, err
+}
+
+// Invalidate removes the configurations of a realm from the cache. Loads in progress for this realm are not cached
+func (c *CachedConfigurationReader) Invalidate(realmID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, item := range []string{cachedConfiguration, cachedAdminConfig, cachedBothConfigs} {
+		delete(c.entries, cacheKey{item: item, realmID: realmID})
+	}
+	c.generation[realmID]++
+}
+
+// InvalidateAll empties the cache
+func (c *CachedConfigurationReader) InvalidateAll() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key := range c.entries {
+		c.generation[key.realmID]++
+	}
+	for key := range c.pending {
+		c.generation[key.realmID]++
+	}
+	c.entries = make(map[cacheKey]cacheEntry)
+}
+
+func (c *CachedConfigurationReader) get(ctx context.Context, key cacheKey, load func() cacheEntry) (cacheEntry, error) {
+	c.mutex.Lock()
+	if entry, ok := c.entries[key]; ok && c.now().Before(entry.expiresAt) {
+		c.mutex.Unlock()
+		c.hits.With(cachedItemLabel, key.item).Add(1)
+		return entry, entry.err
+	}
+	c.misses.With(cachedItemLabel, key.item).Add(1)
+
+	if call, ok := c.pending[key]; ok {
+		c.mutex.Unlock()
+		select {
+		case <-call.done:
+			return call.entry, call.entry.err
+		case <-ctx.Done():
+			return cacheEntry{}, ctx.Err()
+		}
+	}
+
+	var call = &pendingLoad{done: make(chan struct{})}
+	var generation = c.generation[key.realmID]
+	c.pending[key] = call
+	c.mutex.Unlock()
+
+	call.entry = load()
+
+	c.mutex.Lock()
+	delete(c.pending, key)
+	if generation == c.generation[key.realmID] {
+		switch {
+		case call.entry.err == nil:
+			call.entry.expiresAt = c.now().Add(c.ttl(key.realmID))
+			c.entries[key] = call.entry
+		case errors.Is(call.entry.err, sql.ErrNoRows):
+			call.entry.expiresAt = c.now().Add(c.options.NegativeTTL)
+			c.entries[key] = call.entry
+		}
+	}
+	c.mutex.Unlock()
+	close(call.done)
+
+	return call.entry, call.entry.err
+}
+
+func (c *CachedConfigurationReader) ttl(realmID string) time.Duration {
+	if ttl, ok := c.options.RealmTTL[realmID]; ok {
+		return ttl
+	}
+	return c.options.TTL
+}
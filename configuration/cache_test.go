@@ -0,0 +1,241 @@
+package configuration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/configuration/mock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+var _ RealmConfigurationReader = &ConfigurationReaderDBModule{}
+
+// stubReader is a RealmConfigurationReader returning the results of its functions
+type stubReader struct {
+	calls          int
+	getBoth        func() (RealmConfiguration, RealmAdminConfiguration, error)
+	getConfig      func() (RealmConfiguration, error)
+	getAdminConfig func() (RealmAdminConfiguration, error)
+}
+
+func (r *stubReader) GetRealmConfigurations(_ context.Context, _ string) (RealmConfiguration, RealmAdminConfiguration, error) {
+	r.calls++
+	return r.getBoth()
+}
+
+func (r *stubReader) GetConfiguration(_ context.Context, _ string) (RealmConfiguration, error) {
+	r.calls++
+	return r.getConfig()
+}
+
+func (r *stubReader) GetAdminConfiguration(_ context.Context, _ string) (RealmAdminConfiguration, error) {
+	r.calls++
+	return r.getAdminConfig()
+}
+
+type cacheMocks struct {
+	reader *stubReader
+	hits   *mock.Counter
+	misses *mock.Counter
+	now    time.Time
+}
+
+func newCachedReader(t *testing.T, options CacheOptions) (*CachedConfigurationReader, *cacheMocks) {
+	var mockCtrl = gomock.NewController(t)
+	var mockMetrics = mock.NewMetrics(mockCtrl)
+	var mocks = &cacheMocks{
+		reader: &stubReader{},
+		hits:   mock.NewCounter(mockCtrl),
+		misses: mock.NewCounter(mockCtrl),
+		now:    time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC),
+	}
+	mockMetrics.EXPECT().NewCounter(CounterConfigurationCacheHits).Return(mocks.hits)
+	mockMetrics.EXPECT().NewCounter(CounterConfigurationCacheMisses).Return(mocks.misses)
+	mocks.hits.EXPECT().With(cachedItemLabel, gomock.Any()).Return(mocks.hits).AnyTimes()
+	mocks.misses.EXPECT().With(cachedItemLabel, gomock.Any()).Return(mocks.misses).AnyTimes()
+
+	var reader = NewCachedConfigurationReader(mocks.reader, options, mockMetrics)
+	reader.now = func() time.Time { return mocks.now }
+	return reader, mocks
+}
+
+func TestCachedConfigurationReader(t *testing.T) {
+	var ctx = context.TODO()
+	var realmID = "realm-id"
+	var mode = "trustID"
+	var adminConfig = RealmAdminConfiguration{Mode: &mode}
+	var returnAdminConfig = func() (RealmAdminConfiguration, error) { return adminConfig, nil }
+
+	t.Run("Admin configuration is cached until it expires", func(t *testing.T) {
+		var reader, mocks = newCachedReader(t, CacheOptions{TTL: time.Minute})
+		mocks.reader.getAdminConfig = returnAdminConfig
+
+		mocks.misses.EXPECT().Add(1.0)
+		var res, err = reader.GetAdminConfiguration(ctx, realmID)
+		assert.Nil(t, err)
+		assert.Equal(t, adminConfig, res)
+
+		mocks.hits.EXPECT().Add(1.0)
+		mocks.now = mocks.now.Add(59 * time.Second)
+		res, err = reader.GetAdminConfiguration(ctx, realmID)
+		assert.Nil(t, err)
+		assert.Equal(t, adminConfig, res)
+		assert.Equal(t, 1, mocks.reader.calls)
+
+		mocks.misses.EXPECT().Add(1.0)
+		mocks.now = mocks.now.Add(time.Second)
+		_, err = reader.GetAdminConfiguration(ctx, realmID)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, mocks.reader.calls)
+	})
+
+	t.Run("Realm TTL override", func(t *testing.T) {
+		var reader, mocks = newCachedReader(t, CacheOptions{TTL: time.Hour, RealmTTL: map[string]time.Duration{realmID: time.Second}})
+		mocks.reader.getConfig = func() (RealmConfiguration, error) { return RealmConfiguration{}, nil }
+
+		mocks.misses.EXPECT().Add(1.0).Times(2)
+		_, _ = reader.GetConfiguration(ctx, realmID)
+		mocks.now = mocks.now.Add(time.Second)
+		_, _ = reader.GetConfiguration(ctx, realmID)
+		assert.Equal(t, 2, mocks.reader.calls)
+	})
+
+	t.Run("Missing configuration is cached", func(t *testing.T) {
+		var reader, mocks = newCachedReader(t, CacheOptions{TTL: time.Hour, NegativeTTL: time.Minute})
+		mocks.reader.getBoth = func() (RealmConfiguration, RealmAdminConfiguration, error) {
+			return RealmConfiguration{}, RealmAdminConfiguration{}, sql.ErrNoRows
+		}
+
+		mocks.misses.EXPECT().Add(1.0)
+		var _, _, err = reader.GetRealmConfigurations(ctx, realmID)
+		assert.Equal(t, sql.ErrNoRows, err)
+
+		mocks.hits.EXPECT().Add(1.0)
+		_, _, err = reader.GetRealmConfigurations(ctx, realmID)
+		assert.Equal(t, sql.ErrNoRows, err)
+		assert.Equal(t, 1, mocks.reader.calls)
+
+		mocks.reader.getBoth = func() (RealmConfiguration, RealmAdminConfiguration, error) {
+			return RealmConfiguration{}, adminConfig, nil
+		}
+		mocks.misses.EXPECT().Add(1.0)
+		mocks.now = mocks.now.Add(time.Minute)
+		_, res, err := reader.GetRealmConfigurations(ctx, realmID)
+		assert.Nil(t, err)
+		assert.Equal(t, adminConfig, res)
+	})
+
+	t.Run("Other errors are not cached", func(t *testing.T) {
+		var reader, mocks = newCachedReader(t, CacheOptions{})
+		var dbErr = errors.New("db error")
+		mocks.reader.getAdminConfig = func() (RealmAdminConfiguration, error) { return RealmAdminConfiguration{}, dbErr }
+
+		mocks.misses.EXPECT().Add(1.0).Times(2)
+		var _, err = reader.GetAdminConfiguration(ctx, realmID)
+		assert.Equal(t, dbErr, err)
+
+		mocks.reader.getAdminConfig = returnAdminConfig
+		_, err = reader.GetAdminConfiguration(ctx, realmID)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, mocks.reader.calls)
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		var reader, mocks = newCachedReader(t, CacheOptions{})
+		mocks.reader.getAdminConfig = returnAdminConfig
+
+		mocks.misses.EXPECT().Add(1.0).Times(4)
+		mocks.hits.EXPECT().Add(1.0)
+		_, _ = reader.GetAdminConfiguration(ctx, realmID)
+		_, _ = reader.GetAdminConfiguration(ctx, "other")
+
+		reader.Invalidate(realmID)
+		_, _ = reader.GetAdminConfiguration(ctx, realmID)
+		_, _ = reader.GetAdminConfiguration(ctx, "other")
+		assert.Equal(t, 3, mocks.reader.calls)
+
+		reader.InvalidateAll()
+		_, _ = reader.GetAdminConfiguration(ctx, "other")
+		assert.Equal(t, 4, mocks.reader.calls)
+	})
+
+	t.Run("Concurrent misses share a single load", func(t *testing.T) {
+		var reader, mocks = newCachedReader(t, CacheOptions{})
+		var release = make(chan struct{})
+		var loading = make(chan struct{})
+		var waiters sync.WaitGroup
+		waiters.Add(9)
+
+		// Expectations are matched in their declaration order: the first miss is the one of the load
+		mocks.misses.EXPECT().Add(1.0).Times(1)
+		mocks.misses.EXPECT().Add(1.0).Do(func(float64) { waiters.Done() }).Times(9)
+		mocks.reader.getAdminConfig = func() (RealmAdminConfiguration, error) {
+			close(loading)
+			<-release
+			return adminConfig, nil
+		}
+
+		var wg sync.WaitGroup
+		var call = func() {
+			defer wg.Done()
+			var res, err = reader.GetAdminConfiguration(ctx, realmID)
+			assert.Nil(t, err)
+			assert.Equal(t, adminConfig, res)
+		}
+		wg.Add(1)
+		go call()
+		<-loading
+		for i := 0; i < 9; i++ {
+			wg.Add(1)
+			go call()
+		}
+		// The misses are counted with the lock held: once counted, the waiters find the pending load
+		waiters.Wait()
+		close(release)
+		wg.Wait()
+		assert.Equal(t, 1, mocks.reader.calls)
+	})
+
+	t.Run("Waiter context is done", func(t *testing.T) {
+		var reader, mocks = newCachedReader(t, CacheOptions{})
+		var release = make(chan struct{})
+		var loading = make(chan struct{})
+		mocks.misses.EXPECT().Add(1.0).Times(2)
+		mocks.reader.getAdminConfig = func() (RealmAdminConfiguration, error) {
+			close(loading)
+			<-release
+			return adminConfig, nil
+		}
+
+		var done = make(chan struct{})
+		go func() {
+			_, _ = reader.GetAdminConfiguration(ctx, realmID)
+			close(done)
+		}()
+		<-loading
+		var cancelledCtx, cancel = context.WithCancel(ctx)
+		cancel()
+		var _, err = reader.GetAdminConfiguration(cancelledCtx, realmID)
+		assert.Equal(t, context.Canceled, err)
+		close(release)
+		<-done
+	})
+
+	t.Run("Load in progress during invalidation is not cached", func(t *testing.T) {
+		var reader, mocks = newCachedReader(t, CacheOptions{})
+		mocks.reader.getAdminConfig = func() (RealmAdminConfiguration, error) {
+			reader.Invalidate(realmID)
+			return adminConfig, nil
+		}
+
+		mocks.misses.EXPECT().Add(1.0).Times(2)
+		_, _ = reader.GetAdminConfiguration(ctx, realmID)
+		_, _ = reader.GetAdminConfiguration(ctx, realmID)
+		assert.Equal(t, 2, mocks.reader.calls)
+	})
+}
@@ -0,0 +1,110 @@
+package configuration
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/log"
+)
+
+// ChangeKind is the kind of data affected by a ChangeEvent
+type ChangeKind string
+
+// Kinds of the change events
+const (
+	ChangeRealmConfiguration ChangeKind = "realm_configuration"
+	ChangeContextKeys        ChangeKind = "context_keys"
+	ChangeAuthorizations     ChangeKind = "authorizations"
+)
+
+// ChangeEvent notifies that the configuration of a realm, its context keys or the authorizations changed. For the
+// context keys, RealmID is the customer realm. An empty RealmID means that any realm may be affected
+type ChangeEvent struct {
+	Kind      ChangeKind `json:"kind"`
+	RealmID   string     `json:"realm_id,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ChangeListener is called for each change event
+type ChangeListener func(ctx context.Context, event ChangeEvent)
+
+// AuthorizationsReloader reloads the authorizations. It is implemented by security.AuthorizationManager
+type AuthorizationsReloader interface {
+	ReloadAuthorizations(ctx context.Context) error
+}
+
+// ChangeNotifier dispatches the change events to its subscribers. Its Notify method can be given as ChangeListener to
+// the sources of events (ConfigurationWriterDBModule, ChangeWatcher, KafkaChangeSubscriber)
+type ChangeNotifier struct {
+	mutex         sync.RWMutex
+	subscriptions []subscription
+	nextID        int
+}
+
+type subscription struct {
+	id       int
+	listener ChangeListener
+}
+
+// NewChangeNotifier creates a ChangeNotifier without subscriber
+func NewChangeNotifier() *ChangeNotifier {
+	return &ChangeNotifier{}
+}
+
+// Subscribe registers a listener. The returned function unregisters it
+func (n *ChangeNotifier) Subscribe(listener ChangeListener) func() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	var id = n.nextID
+	n.nextID++
+	n.subscriptions = append(n.subscriptions, subscription{id: id, listener: listener})
+	return func() {
+		n.mutex.Lock()
+		defer n.mutex.Unlock()
+		for i, sub := range n.subscriptions {
+			if sub.id == id {
+				// Copy on removal: Notify may be iterating on the previous slice
+				n.subscriptions = append(n.subscriptions[:i:i], n.subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Notify calls the subscribed listeners in their order of subscription
+func (n *ChangeNotifier) Notify(ctx context.Context, event ChangeEvent) {
+	n.mutex.RLock()
+	var subscriptions = n.subscriptions
+	n.mutex.RUnlock()
+
+	for _, sub := range subscriptions {
+		sub.listener(ctx, event)
+	}
+}
+
+// InvalidateOnChange returns a listener removing the changed realm configurations from the given cache
+func InvalidateOnChange(cache *CachedConfigurationReader) ChangeListener {
+	return func(_ context.Context, event ChangeEvent) {
+		if event.Kind != ChangeRealmConfiguration {
+			return
+		}
+		if event.RealmID == "" {
+			cache.InvalidateAll()
+		} else {
+			cache.Invalidate(event.RealmID)
+		}
+	}
+}
+
+// ReloadAuthorizationsOnChange returns a listener reloading the authorizations when they changed
+func ReloadAuthorizationsOnChange(reloader AuthorizationsReloader, logger log.Logger) ChangeListener {
+	return func(ctx context.Context, event ChangeEvent) {
+		if event.Kind != ChangeAuthorizations {
+			return
+		}
+		if err := reloader.ReloadAuthorizations(ctx); err != nil {
+			logger.Warn(ctx, "msg", "Can't reload authorizations after a change", "err", err.Error())
+		}
+	}
+}
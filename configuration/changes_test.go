@@ -0,0 +1,94 @@
+package configuration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// recordingListener records the change events it receives
+type recordingListener struct {
+	events []ChangeEvent
+}
+
+func (l *recordingListener) listen(_ context.Context, event ChangeEvent) {
+	l.events = append(l.events, event)
+}
+
+type stubReloader struct {
+	calls int
+	err   error
+}
+
+func (r *stubReloader) ReloadAuthorizations(_ context.Context) error {
+	r.calls++
+	return r.err
+}
+
+func TestChangeNotifier(t *testing.T) {
+	var ctx = context.TODO()
+	var notifier = NewChangeNotifier()
+	var calls []string
+	var event = ChangeEvent{Kind: ChangeRealmConfiguration, RealmID: "realm"}
+
+	var unsubscribeFirst = notifier.Subscribe(func(_ context.Context, e ChangeEvent) {
+		assert.Equal(t, event, e)
+		calls = append(calls, "first")
+	})
+	notifier.Subscribe(func(_ context.Context, _ ChangeEvent) {
+		calls = append(calls, "second")
+	})
+
+	notifier.Notify(ctx, event)
+	assert.Equal(t, []string{"first", "second"}, calls)
+
+	calls = nil
+	unsubscribeFirst()
+	unsubscribeFirst()
+	notifier.Notify(ctx, event)
+	assert.Equal(t, []string{"second"}, calls)
+}
+
+func TestInvalidateOnChange(t *testing.T) {
+	var ctx = context.TODO()
+	var mode = "trustID"
+	var reader, mocks = newCachedReader(t, CacheOptions{})
+	mocks.reader.getAdminConfig = func() (RealmAdminConfiguration, error) { return RealmAdminConfiguration{Mode: &mode}, nil }
+	mocks.hits.EXPECT().Add(gomock.Any()).AnyTimes()
+	mocks.misses.EXPECT().Add(gomock.Any()).AnyTimes()
+	var listener = InvalidateOnChange(reader)
+
+	_, _ = reader.GetAdminConfiguration(ctx, "realm")
+	listener(ctx, ChangeEvent{Kind: ChangeAuthorizations, RealmID: "realm"})
+	listener(ctx, ChangeEvent{Kind: ChangeRealmConfiguration, RealmID: "other-realm"})
+	_, _ = reader.GetAdminConfiguration(ctx, "realm")
+	assert.Equal(t, 1, mocks.reader.calls)
+
+	listener(ctx, ChangeEvent{Kind: ChangeRealmConfiguration, RealmID: "realm"})
+	_, _ = reader.GetAdminConfiguration(ctx, "realm")
+	assert.Equal(t, 2, mocks.reader.calls)
+
+	listener(ctx, ChangeEvent{Kind: ChangeRealmConfiguration})
+	_, _ = reader.GetAdminConfiguration(ctx, "realm")
+	assert.Equal(t, 3, mocks.reader.calls)
+}
+
+func TestReloadAuthorizationsOnChange(t *testing.T) {
+	var ctx = context.TODO()
+	var reloader = &stubReloader{}
+	var listener = ReloadAuthorizationsOnChange(reloader, log.NewNopLogger())
+
+	listener(ctx, ChangeEvent{Kind: ChangeRealmConfiguration, RealmID: "realm"})
+	assert.Equal(t, 0, reloader.calls)
+
+	listener(ctx, ChangeEvent{Kind: ChangeAuthorizations})
+	assert.Equal(t, 1, reloader.calls)
+
+	reloader.err = errors.New("db error")
+	listener(ctx, ChangeEvent{Kind: ChangeAuthorizations, RealmID: "realm"})
+	assert.Equal(t, 2, reloader.calls)
+}
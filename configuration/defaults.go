@@ -0,0 +1,81 @@
+package configuration
+
+import "reflect"
+
+// DefaultRealmConfiguration returns the values used for the fields missing from a realm configuration: the self-service
+// features are disabled until they are explicitly enabled
+func DefaultRealmConfiguration() RealmConfiguration {
+	return RealmConfiguration{
+		APISelfAuthenticatorDeletionEnabled: boolPtr(false),
+		APISelfPasswordChangeEnabled:        boolPtr(false),
+		APISelfAccountEditingEnabled:        boolPtr(false),
+		APISelfAccountDeletionEnabled:       boolPtr(false),
+		APISelfIDPLinksManagementEnabled:    boolPtr(false),
+		ShowAuthenticatorsTab:               boolPtr(false),
+		ShowPasswordTab:                     boolPtr(false),
+		ShowProfileTab:                      boolPtr(false),
+		ShowMailEditing:                     boolPtr(false),
+		ShowAccountDeletionButton:           boolPtr(false),
+		ShowIDPLinksTab:                     boolPtr(false),
+		SelfRegisterGroupNames:              &[]string{},
+		OnboardingUserEditingEnabled:        boolPtr(false),
+	}
+}
+
+// DefaultRealmAdminConfiguration returns the values used for the fields missing from a realm admin configuration: no
+// check is available and the optional features are disabled. There is no default mode
+func DefaultRealmAdminConfiguration() RealmAdminConfiguration {
+	return RealmAdminConfiguration{
+		AvailableChecks:                                map[string]bool{},
+		SelfRegisterEnabled:                            boolPtr(false),
+		NeedVerifiedContact:                            boolPtr(false),
+		ConsentRequiredSocial:                          boolPtr(false),
+		ConsentRequiredCorporate:                       boolPtr(false),
+		VideoIdentificationVoucherEnabled:              boolPtr(false),
+		VideoIdentificationAccountingEnabled:           boolPtr(false),
+		VideoIdentificationPrepaymentRequired:          boolPtr(false),
+		AuxiliaryVideoIdentificationVoucherEnabled:     boolPtr(false),
+		AuxiliaryVideoIdentificationAccountingEnabled:  boolPtr(false),
+		AuxiliaryVideoIdentificationPrepaymentRequired: boolPtr(false),
+		AutoIdentificationVoucherEnabled:               boolPtr(false),
+		AutoIdentificationAccountingEnabled:            boolPtr(false),
+		AutoIdentificationPrepaymentRequired:           boolPtr(false),
+		OnboardingStatusEnabled:                        boolPtr(false),
+		AutoGeneratedUsernameEnabled:                   boolPtr(false),
+		AutoGeneratedUsernameToggleEnabled:             boolPtr(false),
+	}
+}
+
+// WithDefaults returns a copy of the configuration where the missing fields are set with the values of
+// DefaultRealmConfiguration. The deprecated fields are left untouched
+func (c RealmConfiguration) WithDefaults() RealmConfiguration {
+	applyDefaults(&c, DefaultRealmConfiguration())
+	return c
+}
+
+// WithDefaults returns a copy of the configuration where the missing fields are set with the values of
+// DefaultRealmAdminConfiguration
+func (c RealmAdminConfiguration) WithDefaults() RealmAdminConfiguration {
+	applyDefaults(&c, DefaultRealmAdminConfiguration())
+	return c
+}
+
+// applyDefaults sets the nil pointer, slice and map fields of target with the corresponding fields of defaults. As
+// defaults is built for each call, its values are not shared with other configurations
+func applyDefaults(target any, defaults any) {
+	var targetValue = reflect.ValueOf(target).Elem()
+	var defaultsValue = reflect.ValueOf(defaults)
+	for i := 0; i < targetValue.NumField(); i++ {
+		var field = targetValue.Field(i)
+		switch field.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			if field.IsNil() {
+				field.Set(defaultsValue.Field(i))
+			}
+		}
+	}
+}
+
+func boolPtr(value bool) *bool {
+	return &value
+}
@@ -0,0 +1,41 @@
+package configuration
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealmConfigurationWithDefaults(t *testing.T) {
+	var enabled = true
+	var conf = RealmConfiguration{ShowPasswordTab: &enabled}
+
+	var res = conf.WithDefaults()
+	assert.True(t, *res.ShowPasswordTab)
+	assert.False(t, *res.ShowProfileTab)
+	assert.False(t, *res.APISelfAccountEditingEnabled)
+	assert.Len(t, *res.SelfRegisterGroupNames, 0)
+	assert.Nil(t, res.DefaultClientID)
+	assert.Nil(t, res.DeprecatedAPISelfMailEditingEnabled)
+	// The configuration itself is not modified
+	assert.Nil(t, conf.ShowProfileTab)
+
+	// Defaults are not shared between configurations
+	*res.ShowProfileTab = true
+	assert.False(t, *RealmConfiguration{}.WithDefaults().ShowProfileTab)
+}
+
+func TestRealmAdminConfigurationWithDefaults(t *testing.T) {
+	var res = RealmAdminConfiguration{}.WithDefaults()
+	assert.Nil(t, res.Mode)
+	assert.NotNil(t, res.AvailableChecks)
+
+	// Every *bool of the admin configuration has a default value
+	var value = reflect.ValueOf(res)
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).Type() == reflect.TypeOf((*bool)(nil)) {
+			assert.False(t, value.Field(i).IsNil(), value.Type().Field(i).Name)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package configuration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/IBM/sarama"
+	"github.com/cloudtrust/common-service/v2/log"
+)
+
+// NewKafkaChangePublisher returns a listener publishing the change events as JSON messages on the given topic, so that
+// the other replicas receive them through a KafkaChangeSubscriber. Messages are keyed by realm to keep their order
+func NewKafkaChangePublisher(producer sarama.SyncProducer, topic string, logger log.Logger) ChangeListener {
+	return func(ctx context.Context, event ChangeEvent) {
+		var value, err = json.Marshal(event)
+		if err != nil {
+			logger.Error(ctx, "msg", "Can't serialize configuration change", "err", err.Error())
+			return
+		}
+		var key = event.RealmID
+		if key == "" {
+			key = string(event.Kind)
+		}
+		_, _, err = producer.SendMessage(&sarama.ProducerMessage{
+			Topic: topic,
+			Key:   sarama.StringEncoder(key),
+			Value: sarama.ByteEncoder(value),
+		})
+		if err != nil {
+			logger.Warn(ctx, "msg", "Can't publish configuration change", "kind", string(event.Kind), "realm", event.RealmID, "err", err.Error())
+		}
+	}
+}
+
+// KafkaChangeSubscriber is a sarama.ConsumerGroupHandler notifying the change events published by
+// NewKafkaChangePublisher. Each replica must receive all the events: replicas must not share their consumer group
+type KafkaChangeSubscriber struct {
+	listener ChangeListener
+	logger   log.Logger
+}
+
+// NewKafkaChangeSubscriber creates a KafkaChangeSubscriber notifying the given listener, usually the Notify method of a
+// ChangeNotifier
+func NewKafkaChangeSubscriber(listener ChangeListener, logger log.Logger) *KafkaChangeSubscriber {
+	return &KafkaChangeSubscriber{
+		listener: listener,
+		logger:   logger,
+	}
+}
+
+// Setup is called at the beginning of a new session
+func (s *KafkaChangeSubscriber) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// Cleanup is called at the end of a session
+func (s *KafkaChangeSubscriber) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim notifies the events of the claimed partition. Invalid messages are logged and skipped
+func (s *KafkaChangeSubscriber) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	var ctx = session.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			s.handleMessage(ctx, message)
+			session.MarkMessage(message, "")
+		}
+	}
+}
+
+// ConsumeLoop consumes the given topics with the consumer group until the context is done or the group is closed
+func (s *KafkaChangeSubscriber) ConsumeLoop(ctx context.Context, group sarama.ConsumerGroup, topics ...string) {
+	for ctx.Err() == nil {
+		if err := group.Consume(ctx, topics, s); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			s.logger.Warn(ctx, "msg", "Can't consume configuration changes", "err", err.Error())
+		}
+	}
+}
+
+func (s *KafkaChangeSubscriber) handleMessage(ctx context.Context, message *sarama.ConsumerMessage) {
+	var event ChangeEvent
+	if err := json.Unmarshal(message.Value, &event); err != nil {
+		s.logger.Warn(ctx, "msg", "Invalid configuration change message", "offset", message.Offset, "err", err.Error())
+		return
+	}
+	switch event.Kind {
+	case ChangeRealmConfiguration, ChangeContextKeys, ChangeAuthorizations:
+	default:
+		s.logger.Warn(ctx, "msg", "Unknown configuration change kind", "offset", message.Offset, "kind", string(event.Kind))
+		return
+	}
+	s.listener(ctx, event)
+}
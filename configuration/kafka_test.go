@@ -0,0 +1,101 @@
+package configuration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cloudtrust/common-service/v2/configuration/mock"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestKafkaChangePublisher(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	var mockProducer = mock.NewSyncProducer(mockCtrl)
+	var ctx = context.TODO()
+	var updatedAt = time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)
+	var publish = NewKafkaChangePublisher(mockProducer, "configuration-changes", log.NewNopLogger())
+
+	t.Run("Realm change is keyed by realm", func(t *testing.T) {
+		mockProducer.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(msg *sarama.ProducerMessage) (int32, int64, error) {
+			assert.Equal(t, "configuration-changes", msg.Topic)
+			assert.Equal(t, sarama.StringEncoder("realm"), msg.Key)
+			assert.Equal(t, sarama.ByteEncoder(`{"kind":"realm_configuration","realm_id":"realm","updated_at":"2024-03-05T10:00:00Z"}`), msg.Value)
+			return 0, 0, nil
+		})
+		publish(ctx, ChangeEvent{Kind: ChangeRealmConfiguration, RealmID: "realm", UpdatedAt: updatedAt})
+	})
+
+	t.Run("Change without realm is keyed by kind", func(t *testing.T) {
+		mockProducer.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(msg *sarama.ProducerMessage) (int32, int64, error) {
+			assert.Equal(t, sarama.StringEncoder(ChangeAuthorizations), msg.Key)
+			return 0, 0, errors.New("kafka error")
+		})
+		publish(ctx, ChangeEvent{Kind: ChangeAuthorizations, UpdatedAt: updatedAt})
+	})
+}
+
+func TestKafkaChangeSubscriber(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	var mockSession = mock.NewConsumerGroupSession(mockCtrl)
+	var mockClaim = mock.NewConsumerGroupClaim(mockCtrl)
+	var mockGroup = mock.NewConsumerGroup(mockCtrl)
+	var listener = &recordingListener{}
+	var subscriber = NewKafkaChangeSubscriber(listener.listen, log.NewNopLogger())
+	var updatedAt = time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, subscriber.Setup(mockSession))
+	assert.Nil(t, subscriber.Cleanup(mockSession))
+
+	t.Run("Consume claim", func(t *testing.T) {
+		var messages = make(chan *sarama.ConsumerMessage, 3)
+		var valid = &sarama.ConsumerMessage{Value: []byte(`{"kind":"authorizations","realm_id":"realm","updated_at":"2024-03-05T10:00:00Z"}`)}
+		var invalid = &sarama.ConsumerMessage{Value: []byte(`not json`)}
+		var unknown = &sarama.ConsumerMessage{Value: []byte(`{"kind":"unknown"}`)}
+		messages <- valid
+		messages <- invalid
+		messages <- unknown
+		close(messages)
+
+		mockSession.EXPECT().Context().Return(context.TODO())
+		mockClaim.EXPECT().Messages().Return(messages).AnyTimes()
+		mockSession.EXPECT().MarkMessage(valid, "")
+		mockSession.EXPECT().MarkMessage(invalid, "")
+		mockSession.EXPECT().MarkMessage(unknown, "")
+
+		assert.Nil(t, subscriber.ConsumeClaim(mockSession, mockClaim))
+		assert.Equal(t, []ChangeEvent{{Kind: ChangeAuthorizations, RealmID: "realm", UpdatedAt: updatedAt}}, listener.events)
+	})
+
+	t.Run("Session ends", func(t *testing.T) {
+		var ctx, cancel = context.WithCancel(context.TODO())
+		cancel()
+		mockSession.EXPECT().Context().Return(ctx)
+		mockClaim.EXPECT().Messages().Return(make(chan *sarama.ConsumerMessage)).AnyTimes()
+
+		assert.Nil(t, subscriber.ConsumeClaim(mockSession, mockClaim))
+	})
+
+	t.Run("Consume loop retries until the group is closed", func(t *testing.T) {
+		var topics = []string{"configuration-changes"}
+		gomock.InOrder(
+			mockGroup.EXPECT().Consume(gomock.Any(), topics, subscriber).Return(errors.New("kafka error")),
+			mockGroup.EXPECT().Consume(gomock.Any(), topics, subscriber).Return(nil),
+			mockGroup.EXPECT().Consume(gomock.Any(), topics, subscriber).Return(sarama.ErrClosedConsumerGroup),
+		)
+		subscriber.ConsumeLoop(context.TODO(), mockGroup, topics...)
+	})
+
+	t.Run("Consume loop stops when the context is done", func(t *testing.T) {
+		var ctx, cancel = context.WithCancel(context.TODO())
+		mockGroup.EXPECT().Consume(ctx, gomock.Any(), subscriber).DoAndReturn(func(context.Context, []string, sarama.ConsumerGroupHandler) error {
+			cancel()
+			return nil
+		})
+		subscriber.ConsumeLoop(ctx, mockGroup, "configuration-changes")
+	})
+}
@@ -0,0 +1,6 @@
+-- Columns polled by configuration.ChangeWatcher to detect the changes made by other replicas
+ALTER TABLE realm_configuration
+  ADD COLUMN updated_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3);
+
+ALTER TABLE authorizations
+  ADD COLUMN updated_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3);
@@ -91,6 +91,26 @@ func (mr *CloudtrustDBMockRecorder) Exec(query any, args ...any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*CloudtrustDB)(nil).Exec), varargs...)
 }
 
+// ExecContext mocks base method.
+func (m *CloudtrustDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExecContext", varargs...)
+	ret0, _ := ret[0].(sql.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecContext indicates an expected call of ExecContext.
+func (mr *CloudtrustDBMockRecorder) ExecContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecContext", reflect.TypeOf((*CloudtrustDB)(nil).ExecContext), varargs...)
+}
+
 // Ping mocks base method.
 func (m *CloudtrustDB) Ping() error {
 	m.ctrl.T.Helper()
@@ -105,6 +125,20 @@ func (mr *CloudtrustDBMockRecorder) Ping() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*CloudtrustDB)(nil).Ping))
 }
 
+// PingContext mocks base method.
+func (m *CloudtrustDB) PingContext(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PingContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PingContext indicates an expected call of PingContext.
+func (mr *CloudtrustDBMockRecorder) PingContext(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingContext", reflect.TypeOf((*CloudtrustDB)(nil).PingContext), ctx)
+}
+
 // Query mocks base method.
 func (m *CloudtrustDB) Query(query string, args ...any) (sqltypes.SQLRows, error) {
 	m.ctrl.T.Helper()
@@ -125,6 +159,26 @@ func (mr *CloudtrustDBMockRecorder) Query(query any, args ...any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*CloudtrustDB)(nil).Query), varargs...)
 }
 
+// QueryContext mocks base method.
+func (m *CloudtrustDB) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryContext", varargs...)
+	ret0, _ := ret[0].(sqltypes.SQLRows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryContext indicates an expected call of QueryContext.
+func (mr *CloudtrustDBMockRecorder) QueryContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryContext", reflect.TypeOf((*CloudtrustDB)(nil).QueryContext), varargs...)
+}
+
 // QueryRow mocks base method.
 func (m *CloudtrustDB) QueryRow(query string, args ...any) sqltypes.SQLRow {
 	m.ctrl.T.Helper()
@@ -144,6 +198,25 @@ func (mr *CloudtrustDBMockRecorder) QueryRow(query any, args ...any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRow", reflect.TypeOf((*CloudtrustDB)(nil).QueryRow), varargs...)
 }
 
+// QueryRowContext mocks base method.
+func (m *CloudtrustDB) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryRowContext", varargs...)
+	ret0, _ := ret[0].(sqltypes.SQLRow)
+	return ret0
+}
+
+// QueryRowContext indicates an expected call of QueryRowContext.
+func (mr *CloudtrustDBMockRecorder) QueryRowContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRowContext", reflect.TypeOf((*CloudtrustDB)(nil).QueryRowContext), varargs...)
+}
+
 // Stats mocks base method.
 func (m *CloudtrustDB) Stats() sql.DBStats {
 	m.ctrl.T.Helper()
@@ -370,6 +443,26 @@ func (mr *TransactionMockRecorder) Exec(query any, args ...any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*Transaction)(nil).Exec), varargs...)
 }
 
+// ExecContext mocks base method.
+func (m *Transaction) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExecContext", varargs...)
+	ret0, _ := ret[0].(sql.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecContext indicates an expected call of ExecContext.
+func (mr *TransactionMockRecorder) ExecContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecContext", reflect.TypeOf((*Transaction)(nil).ExecContext), varargs...)
+}
+
 // Query mocks base method.
 func (m *Transaction) Query(query string, args ...any) (sqltypes.SQLRows, error) {
 	m.ctrl.T.Helper()
@@ -390,6 +483,26 @@ func (mr *TransactionMockRecorder) Query(query any, args ...any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*Transaction)(nil).Query), varargs...)
 }
 
+// QueryContext mocks base method.
+func (m *Transaction) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryContext", varargs...)
+	ret0, _ := ret[0].(sqltypes.SQLRows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryContext indicates an expected call of QueryContext.
+func (mr *TransactionMockRecorder) QueryContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryContext", reflect.TypeOf((*Transaction)(nil).QueryContext), varargs...)
+}
+
 // QueryRow mocks base method.
 func (m *Transaction) QueryRow(query string, args ...any) sqltypes.SQLRow {
 	m.ctrl.T.Helper()
@@ -409,6 +522,25 @@ func (mr *TransactionMockRecorder) QueryRow(query any, args ...any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRow", reflect.TypeOf((*Transaction)(nil).QueryRow), varargs...)
 }
 
+// QueryRowContext mocks base method.
+func (m *Transaction) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryRowContext", varargs...)
+	ret0, _ := ret[0].(sqltypes.SQLRow)
+	return ret0
+}
+
+// QueryRowContext indicates an expected call of QueryRowContext.
+func (mr *TransactionMockRecorder) QueryRowContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRowContext", reflect.TypeOf((*Transaction)(nil).QueryRowContext), varargs...)
+}
+
 // Rollback mocks base method.
 func (m *Transaction) Rollback() error {
 	m.ctrl.T.Helper()
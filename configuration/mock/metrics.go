@@ -0,0 +1,194 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/cloudtrust/common-service/v2/metrics (interfaces: Metrics,Counter)
+//
+// Generated by this command:
+//
+//	mockgen --build_flags=--mod=mod -destination=./mock/metrics.go -package=mock -mock_names=Metrics=Metrics,Counter=Counter github.com/cloudtrust/common-service/v2/metrics Metrics,Counter
+//
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	metrics "github.com/cloudtrust/common-service/v2/metrics"
+	metrics0 "github.com/go-kit/kit/metrics"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// Metrics is a mock of Metrics interface.
+type Metrics struct {
+	ctrl     *gomock.Controller
+	recorder *MetricsMockRecorder
+	isgomock struct{}
+}
+
+// MetricsMockRecorder is the mock recorder for Metrics.
+type MetricsMockRecorder struct {
+	mock *Metrics
+}
+
+// NewMetrics creates a new mock instance.
+func NewMetrics(ctrl *gomock.Controller) *Metrics {
+	mock := &Metrics{ctrl: ctrl}
+	mock.recorder = &MetricsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Metrics) EXPECT() *MetricsMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *Metrics) Close() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Close")
+}
+
+// Close indicates an expected call of Close.
+func (mr *MetricsMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*Metrics)(nil).Close))
+}
+
+// NewCounter mocks base method.
+func (m *Metrics) NewCounter(name string) metrics.Counter {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewCounter", name)
+	ret0, _ := ret[0].(metrics.Counter)
+	return ret0
+}
+
+// NewCounter indicates an expected call of NewCounter.
+func (mr *MetricsMockRecorder) NewCounter(name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewCounter", reflect.TypeOf((*Metrics)(nil).NewCounter), name)
+}
+
+// NewGauge mocks base method.
+func (m *Metrics) NewGauge(name string) metrics.Gauge {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewGauge", name)
+	ret0, _ := ret[0].(metrics.Gauge)
+	return ret0
+}
+
+// NewGauge indicates an expected call of NewGauge.
+func (mr *MetricsMockRecorder) NewGauge(name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewGauge", reflect.TypeOf((*Metrics)(nil).NewGauge), name)
+}
+
+// NewHistogram mocks base method.
+func (m *Metrics) NewHistogram(name string) metrics.Histogram {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewHistogram", name)
+	ret0, _ := ret[0].(metrics.Histogram)
+	return ret0
+}
+
+// NewHistogram indicates an expected call of NewHistogram.
+func (mr *MetricsMockRecorder) NewHistogram(name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewHistogram", reflect.TypeOf((*Metrics)(nil).NewHistogram), name)
+}
+
+// Ping mocks base method.
+func (m *Metrics) Ping(timeout time.Duration) (time.Duration, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", timeout)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MetricsMockRecorder) Ping(timeout any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*Metrics)(nil).Ping), timeout)
+}
+
+// Stats mocks base method.
+func (m *Metrics) Stats(arg0 context.Context, name string, tags map[string]string, fields map[string]any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", arg0, name, tags, fields)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MetricsMockRecorder) Stats(arg0, name, tags, fields any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*Metrics)(nil).Stats), arg0, name, tags, fields)
+}
+
+// WriteLoop mocks base method.
+func (m *Metrics) WriteLoop(c <-chan time.Time) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "WriteLoop", c)
+}
+
+// WriteLoop indicates an expected call of WriteLoop.
+func (mr *MetricsMockRecorder) WriteLoop(c any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteLoop", reflect.TypeOf((*Metrics)(nil).WriteLoop), c)
+}
+
+// Counter is a mock of Counter interface.
+type Counter struct {
+	ctrl     *gomock.Controller
+	recorder *CounterMockRecorder
+	isgomock struct{}
+}
+
+// CounterMockRecorder is the mock recorder for Counter.
+type CounterMockRecorder struct {
+	mock *Counter
+}
+
+// NewCounter creates a new mock instance.
+func NewCounter(ctrl *gomock.Controller) *Counter {
+	mock := &Counter{ctrl: ctrl}
+	mock.recorder = &CounterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Counter) EXPECT() *CounterMockRecorder {
+	return m.recorder
+}
+
+// Add mocks base method.
+func (m *Counter) Add(delta float64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Add", delta)
+}
+
+// Add indicates an expected call of Add.
+func (mr *CounterMockRecorder) Add(delta any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*Counter)(nil).Add), delta)
+}
+
+// With mocks base method.
+func (m *Counter) With(labelValues ...string) metrics0.Counter {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range labelValues {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "With", varargs...)
+	ret0, _ := ret[0].(metrics0.Counter)
+	return ret0
+}
+
+// With indicates an expected call of With.
+func (mr *CounterMockRecorder) With(labelValues ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "With", reflect.TypeOf((*Counter)(nil).With), labelValues...)
+}
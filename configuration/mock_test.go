@@ -2,4 +2,6 @@ package configuration
 
 import _ "github.com/golang/mock/mockgen/model"
 
-//go:generate mockgen --build_flags=--mod=mod -destination=./mock/cloudtrustdb.go -package=mock -mock_names=CloudtrustDB=CloudtrustDB,SQLRow=SQLRow,SQLRows=SQLRows,Transaction github.com/cloudtrust/common-service/v2/database/sqltypes CloudtrustDB,SQLRow,SQLRows
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/cloudtrustdb.go -package=mock -mock_names=CloudtrustDB=CloudtrustDB,SQLRow=SQLRow,SQLRows=SQLRows,Transaction=Transaction github.com/cloudtrust/common-service/v2/database/sqltypes CloudtrustDB,SQLRow,SQLRows,Transaction
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/metrics.go -package=mock -mock_names=Metrics=Metrics,Counter=Counter github.com/cloudtrust/common-service/v2/metrics Metrics,Counter
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/sarama.go -package=mock -mock_names=SyncProducer=SyncProducer,ConsumerGroup=ConsumerGroup,ConsumerGroupSession=ConsumerGroupSession,ConsumerGroupClaim=ConsumerGroupClaim github.com/IBM/sarama SyncProducer,ConsumerGroup,ConsumerGroupSession,ConsumerGroupClaim
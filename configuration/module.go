@@ -25,6 +25,15 @@ type ConfigurationReaderDBModule struct {
 
 // NewConfigurationReaderDBModule returns a ConfigurationDB module.
 func NewConfigurationReaderDBModule(db sqltypes.CloudtrustDB, logger log.Logger, actions ...[]string) *ConfigurationReaderDBModule {
+	return &ConfigurationReaderDBModule{
+		db:        db,
+		authScope: newAuthorizationScope(actions...),
+		logger:    logger,
+	}
+}
+
+// newAuthorizationScope returns the set of the given actions, or nil when no action is given (no restriction)
+func newAuthorizationScope(actions ...[]string) map[string]bool {
 	var authScope map[string]bool
 	if len(actions) > 0 {
 		authScope = make(map[string]bool)
@@ -34,11 +43,7 @@ func NewConfigurationReaderDBModule(db sqltypes.CloudtrustDB, logger log.Logger,
 			}
 		}
 	}
-	return &ConfigurationReaderDBModule{
-		db:        db,
-		authScope: authScope,
-		logger:    logger,
-	}
+	return authScope
 }
 
 // GetRealmConfigurations returns both configuration and admin configuration of a realm
@@ -280,8 +285,12 @@ func (c *ConfigurationReaderDBModule) scanAuthorization(scanner sqltypes.SQLRow)
 }
 
 func (c *ConfigurationReaderDBModule) isInAuthorizationScope(action string) bool {
-	if c.authScope != nil {
-		if _, ok := c.authScope[action]; !ok {
+	return isInAuthorizationScope(c.authScope, action)
+}
+
+func isInAuthorizationScope(authScope map[string]bool, action string) bool {
+	if authScope != nil {
+		if _, ok := authScope[action]; !ok {
 			return false
 		}
 	}
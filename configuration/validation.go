@@ -0,0 +1,134 @@
+package configuration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	cerrors "github.com/cloudtrust/common-service/v2/errors"
+	"github.com/cloudtrust/common-service/v2/validation"
+)
+
+// Modes of a realm admin configuration
+const (
+	ModeTrustID   = "trustID"
+	ModeCorporate = "corporate"
+)
+
+const (
+	regExpAbsoluteURL = `^https?://[^\s]+$`
+	maxValueLength    = 255
+)
+
+var (
+	// AvailableModes lists all available modes for RealmAdminConfiguration
+	AvailableModes = []string{ModeTrustID, ModeCorporate}
+
+	// ErrUnknownConfigurationFields is returned by the strict decoding functions when the JSON representation contains
+	// fields which are not part of the configuration
+	ErrUnknownConfigurationFields = errors.New("unknown configuration fields")
+)
+
+// NewRealmConfigurationStrict is NewRealmConfiguration reporting the unknown fields of the JSON representation. It is
+// meant for the configurations provided by the users: the configurations stored in database are still read with
+// NewRealmConfiguration so that fields removed from RealmConfiguration do not prevent a realm from working
+func NewRealmConfigurationStrict(confJSON string) (RealmConfiguration, error) {
+	if err := checkUnknownFields(confJSON, RealmConfiguration{}); err != nil {
+		return RealmConfiguration{}, err
+	}
+	return NewRealmConfiguration(confJSON)
+}
+
+// NewRealmAdminConfigurationStrict is NewRealmAdminConfiguration reporting the unknown fields of the JSON representation
+func NewRealmAdminConfigurationStrict(configJSON string) (RealmAdminConfiguration, error) {
+	if err := checkUnknownFields(configJSON, RealmAdminConfiguration{}); err != nil {
+		return RealmAdminConfiguration{}, err
+	}
+	return NewRealmAdminConfiguration(configJSON)
+}
+
+// Validate checks the values of a realm configuration
+func (c RealmConfiguration) Validate() error {
+	return validation.NewParameterValidator().
+		ValidateParameterLength("default_client_id", c.DefaultClientID, 1, maxValueLength, false).
+		ValidateParameterRegExp("default_redirect_uri", c.DefaultRedirectURI, regExpAbsoluteURL, false).
+		ValidateParameterLength("self_service_default_tab", c.SelfServiceDefaultTab, 1, maxValueLength, false).
+		ValidateParameterRegExp("allowed_back_url", c.AllowedBackURL, regExpAbsoluteURL, false).
+		ValidateParameterRegExpSlice("allowed_back_urls", c.AllowedBackURLs, regExpAbsoluteURL, false).
+		ValidateParameterRegExp("redirect_cancelled_registration_url", c.RedirectCancelledRegistrationURL, regExpAbsoluteURL, false).
+		ValidateParameterRegExp("redirect_successful_registration_url", c.RedirectSuccessfulRegistrationURL, regExpAbsoluteURL, false).
+		ValidateParameterRegExp("onboarding_redirect_uri", c.OnboardingRedirectURI, regExpAbsoluteURL, false).
+		ValidateParameterLength("onboarding_client_id", c.OnboardingClientID, 1, maxValueLength, false).
+		ValidateParameterFunc(func() error {
+			if c.SelfRegisterGroupNames == nil {
+				return nil
+			}
+			for _, groupName := range *c.SelfRegisterGroupNames {
+				if groupName == "" {
+					return cerrors.CreateBadRequestError(cerrors.MsgErrInvalidParam + ".self_register_group_names")
+				}
+			}
+			return nil
+		}).
+		ValidateParameterLength("barcode_type", c.BarcodeType, 1, maxValueLength, false).
+		Status()
+}
+
+// Validate checks the values of a realm admin configuration
+func (c RealmAdminConfiguration) Validate() error {
+	return validation.NewParameterValidator().
+		ValidateParameterInSlice("mode", c.Mode, AvailableModes, false).
+		ValidateParameterFunc(func() error {
+			for checkKey := range c.AvailableChecks {
+				if !validation.IsStringInSlice(AvailableCheckKeys, checkKey) {
+					return cerrors.CreateBadRequestError(cerrors.MsgErrInvalidParam + ".available-checks")
+				}
+			}
+			return nil
+		}).
+		ValidateParameterLength("register_theme", c.RegisterTheme, 1, maxValueLength, false).
+		ValidateParameterLength("sse_theme", c.SseTheme, 1, maxValueLength, false).
+		ValidateParameterLength("bo_theme", c.BoTheme, 1, maxValueLength, false).
+		ValidateParameterLength("signer_theme", c.SignerTheme, 1, maxValueLength, false).
+		Status()
+}
+
+// checkUnknownFields returns ErrUnknownConfigurationFields with the sorted list of the top-level fields of the JSON
+// object which are not declared by the given struct. Invalid JSON is left to the decoding
+func checkUnknownFields(confJSON string, target any) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(confJSON), &fields); err != nil {
+		return nil
+	}
+	var known = jsonFieldNames(reflect.TypeOf(target))
+	var unknown []string
+	for name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("%w: %s", ErrUnknownConfigurationFields, strings.Join(unknown, ", "))
+}
+
+func jsonFieldNames(structType reflect.Type) map[string]bool {
+	var res = make(map[string]bool)
+	for i := 0; i < structType.NumField(); i++ {
+		var field = structType.Field(i)
+		var name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		res[name] = true
+	}
+	return res
+}
@@ -0,0 +1,80 @@
+package configuration
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRealmConfigurationStrict(t *testing.T) {
+	t.Run("Known fields", func(t *testing.T) {
+		var conf, err = NewRealmConfigurationStrict(`{"default_client_id":"client","api_self_mail_editing_enabled":true}`)
+		assert.Nil(t, err)
+		assert.Equal(t, "client", *conf.DefaultClientID)
+		assert.True(t, *conf.APISelfAccountEditingEnabled)
+	})
+	t.Run("Unknown fields", func(t *testing.T) {
+		var _, err = NewRealmConfigurationStrict(`{"default_client_idd":"client","show_password":true,"show_profile_tab":true}`)
+		assert.True(t, errors.Is(err, ErrUnknownConfigurationFields))
+		assert.Contains(t, err.Error(), "default_client_idd, show_password")
+	})
+	t.Run("Invalid JSON", func(t *testing.T) {
+		var _, err = NewRealmConfigurationStrict(`{`)
+		assert.NotNil(t, err)
+		assert.False(t, errors.Is(err, ErrUnknownConfigurationFields))
+	})
+}
+
+func TestNewRealmAdminConfigurationStrict(t *testing.T) {
+	var conf, err = NewRealmAdminConfigurationStrict(`{"mode":"trustID","available-checks":{"IDNow":true}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, ModeTrustID, *conf.Mode)
+
+	_, err = NewRealmAdminConfigurationStrict(`{"mode":"trustID","available_checks":{"IDNow":true}}`)
+	assert.True(t, errors.Is(err, ErrUnknownConfigurationFields))
+	assert.Contains(t, err.Error(), "available_checks")
+}
+
+func TestRealmConfigurationValidate(t *testing.T) {
+	var validURL = "https://example.com/path"
+	var invalidURL = "not a url"
+	var empty = ""
+
+	assert.Nil(t, RealmConfiguration{}.Validate())
+	assert.Nil(t, RealmConfiguration{
+		DefaultRedirectURI:     &validURL,
+		AllowedBackURLs:        []string{validURL, "http://localhost:8080/*"},
+		SelfRegisterGroupNames: &[]string{"group"},
+	}.Validate())
+
+	for name, conf := range map[string]RealmConfiguration{
+		"default_client_id":         {DefaultClientID: &empty},
+		"default_redirect_uri":      {DefaultRedirectURI: &invalidURL},
+		"allowed_back_urls":         {AllowedBackURLs: []string{validURL, invalidURL}},
+		"onboarding_redirect_uri":   {OnboardingRedirectURI: &invalidURL},
+		"self_register_group_names": {SelfRegisterGroupNames: &[]string{"group", ""}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var err = conf.Validate()
+			assert.NotNil(t, err)
+			assert.Contains(t, err.Error(), name)
+		})
+	}
+}
+
+func TestRealmAdminConfigurationValidate(t *testing.T) {
+	var mode = ModeCorporate
+	var invalidMode = "unknown"
+
+	assert.Nil(t, RealmAdminConfiguration{}.Validate())
+	assert.Nil(t, RealmAdminConfiguration{Mode: &mode, AvailableChecks: map[string]bool{CheckKeyIDNow: true, CheckKeyPhysical: false}}.Validate())
+
+	var err = RealmAdminConfiguration{Mode: &invalidMode}.Validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "mode")
+
+	err = RealmAdminConfiguration{AvailableChecks: map[string]bool{"unknown-check": true}}.Validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "available-checks")
+}
@@ -0,0 +1,140 @@
+package configuration
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+)
+
+// The watcher expects the realm_configuration and authorizations tables to have an updated_at column maintained by the
+// database, added by the migrations/V1__add_updated_at_columns.sql script:
+//
+//	updated_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3)
+const (
+	selectRealmVersionsStmt = `SELECT realm_id, updated_at FROM realm_configuration`
+	selectAuthzVersionStmt  = `SELECT COUNT(*), IFNULL(MAX(updated_at), '') FROM authorizations`
+)
+
+// ChangeWatcher detects the changes made by other replicas by polling the updated_at columns of the realm_configuration
+// and authorizations tables. A realm configuration changes when its updated_at changes or when it is deleted. The
+// authorizations change when their count or their latest updated_at changes
+type ChangeWatcher struct {
+	db       sqltypes.CloudtrustDB
+	listener ChangeListener
+	logger   log.Logger
+	now      func() time.Time
+
+	mutex         sync.Mutex
+	initialized   bool
+	realmVersions map[string]string
+	authzVersion  string
+}
+
+// NewChangeWatcher creates a ChangeWatcher notifying the given listener, usually the Notify method of a ChangeNotifier
+func NewChangeWatcher(db sqltypes.CloudtrustDB, listener ChangeListener, logger log.Logger) *ChangeWatcher {
+	return &ChangeWatcher{
+		db:       db,
+		listener: listener,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// Poll compares the current versions with the ones of the previous poll and notifies the changes. The first poll only
+// records the versions
+func (w *ChangeWatcher) Poll(ctx context.Context) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var realmVersions, err = w.getRealmVersions(ctx)
+	if err != nil {
+		w.logger.Warn(ctx, "msg", "Can't get realm configuration versions", "err", err.Error())
+		return err
+	}
+	authzVersion, err := w.getAuthorizationsVersion(ctx)
+	if err != nil {
+		w.logger.Warn(ctx, "msg", "Can't get authorizations version", "err", err.Error())
+		return err
+	}
+
+	var changes []ChangeEvent
+	if w.initialized {
+		var now = w.now()
+		for _, realmID := range changedRealms(w.realmVersions, realmVersions) {
+			changes = append(changes, ChangeEvent{Kind: ChangeRealmConfiguration, RealmID: realmID, UpdatedAt: now})
+		}
+		if authzVersion != w.authzVersion {
+			changes = append(changes, ChangeEvent{Kind: ChangeAuthorizations, UpdatedAt: now})
+		}
+	}
+	w.initialized = true
+	w.realmVersions = realmVersions
+	w.authzVersion = authzVersion
+
+	for _, change := range changes {
+		w.listener(ctx, change)
+	}
+	return nil
+}
+
+// WatchLoop polls the versions each time the given channel ticks (usually time.NewTicker(interval).C) until the context
+// is done
+func (w *ChangeWatcher) WatchLoop(ctx context.Context, ticks <-chan time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+			_ = w.Poll(ctx)
+		}
+	}
+}
+
+func (w *ChangeWatcher) getRealmVersions(ctx context.Context) (map[string]string, error) {
+	rows, err := w.db.QueryContext(ctx, selectRealmVersionsStmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res = make(map[string]string)
+	for rows.Next() {
+		var realmID, version string
+		if err = rows.Scan(&realmID, &version); err != nil {
+			return nil, err
+		}
+		res[realmID] = version
+	}
+	return res, rows.Err()
+}
+
+func (w *ChangeWatcher) getAuthorizationsVersion(ctx context.Context) (string, error) {
+	var count int64
+	var updatedAt string
+	if err := w.db.QueryRowContext(ctx, selectAuthzVersionStmt).Scan(&count, &updatedAt); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(count, 10) + "/" + updatedAt, nil
+}
+
+// changedRealms returns the sorted identifiers of the realms created, updated or deleted between two polls
+func changedRealms(before, after map[string]string) []string {
+	var res []string
+	for realmID, version := range after {
+		if previous, ok := before[realmID]; !ok || previous != version {
+			res = append(res, realmID)
+		}
+	}
+	for realmID := range before {
+		if _, ok := after[realmID]; !ok {
+			res = append(res, realmID)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
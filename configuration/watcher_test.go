@@ -0,0 +1,111 @@
+package configuration
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/dbtest"
+	"github.com/cloudtrust/common-service/v2/database/migration"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangedRealms(t *testing.T) {
+	var before = map[string]string{"a": "1", "b": "1", "c": "1"}
+	var after = map[string]string{"a": "1", "b": "2", "d": "1"}
+
+	assert.Equal(t, []string{"b", "c", "d"}, changedRealms(before, after))
+	assert.Len(t, changedRealms(before, before), 0)
+}
+
+func TestUpdatedAtMigration(t *testing.T) {
+	var migrations, err = migration.LoadMigrations(os.DirFS("migrations"))
+	assert.Nil(t, err)
+	assert.Len(t, migrations, 1)
+
+	var statements = migration.SplitStatements(migrations[0].Content)
+	assert.Len(t, statements, 2)
+	assert.Contains(t, statements[0], "ALTER TABLE realm_configuration")
+	assert.Contains(t, statements[1], "ALTER TABLE authorizations")
+}
+
+func TestChangeWatcher(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var ctx = context.TODO()
+	var now = time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)
+	var listener = &recordingListener{}
+	var watcher = NewChangeWatcher(db, listener.listen, log.NewNopLogger())
+	watcher.now = func() time.Time { return now }
+
+	var setVersions = func(authzVersion string, realmVersions ...[]any) {
+		db.Reset()
+		db.On("SELECT realm_id, updated_at FROM realm_configuration").WithRows(realmVersions...)
+		db.On("FROM authorizations").WithRows([]any{int64(3), authzVersion})
+	}
+
+	t.Run("First poll only records the versions", func(t *testing.T) {
+		setVersions("2024-03-05 09:00:00.000", []any{"realm-a", "2024-03-05 09:00:00.000"}, []any{"realm-b", "2024-03-05 09:00:00.000"})
+
+		assert.Nil(t, watcher.Poll(ctx))
+		assert.Len(t, listener.events, 0)
+	})
+
+	t.Run("No change", func(t *testing.T) {
+		setVersions("2024-03-05 09:00:00.000", []any{"realm-a", "2024-03-05 09:00:00.000"}, []any{"realm-b", "2024-03-05 09:00:00.000"})
+
+		assert.Nil(t, watcher.Poll(ctx))
+		assert.Len(t, listener.events, 0)
+	})
+
+	t.Run("Realm updated, realm deleted and authorizations updated", func(t *testing.T) {
+		setVersions("2024-03-05 09:30:00.000", []any{"realm-a", "2024-03-05 09:30:00.000"})
+
+		assert.Nil(t, watcher.Poll(ctx))
+		assert.Equal(t, []ChangeEvent{
+			{Kind: ChangeRealmConfiguration, RealmID: "realm-a", UpdatedAt: now},
+			{Kind: ChangeRealmConfiguration, RealmID: "realm-b", UpdatedAt: now},
+			{Kind: ChangeAuthorizations, UpdatedAt: now},
+		}, listener.events)
+	})
+
+	t.Run("Query fails", func(t *testing.T) {
+		var dbErr = errors.New("db error")
+		listener.events = nil
+		db.Reset()
+		db.On("SELECT realm_id").WithError(dbErr)
+
+		assert.Equal(t, dbErr, watcher.Poll(ctx))
+
+		db.Reset()
+		db.On("SELECT realm_id").WithRows([]any{"realm-a", "2024-03-05 09:45:00.000"})
+		db.On("FROM authorizations").WithError(dbErr)
+
+		assert.Equal(t, dbErr, watcher.Poll(ctx))
+		assert.Len(t, listener.events, 0)
+
+		// Versions are left untouched by a failed poll
+		setVersions("2024-03-05 09:30:00.000", []any{"realm-a", "2024-03-05 09:30:00.000"})
+		assert.Nil(t, watcher.Poll(ctx))
+		assert.Len(t, listener.events, 0)
+	})
+
+	t.Run("Watch loop", func(t *testing.T) {
+		setVersions("2024-03-05 10:00:00.000", []any{"realm-a", "2024-03-05 09:30:00.000"})
+		listener.events = nil
+		var ticks = make(chan time.Time)
+		var loopCtx, cancel = context.WithCancel(ctx)
+		var done = make(chan struct{})
+		go func() {
+			watcher.WatchLoop(loopCtx, ticks)
+			close(done)
+		}()
+		ticks <- now
+		ticks <- now
+		cancel()
+		<-done
+		assert.Equal(t, []ChangeEvent{{Kind: ChangeAuthorizations, UpdatedAt: now}}, listener.events)
+	})
+}
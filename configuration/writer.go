@@ -0,0 +1,335 @@
+package configuration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqlretry"
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+)
+
+const (
+	selectConfigForUpdateStmt      = `SELECT configuration FROM realm_configuration WHERE realm_id = ? FOR UPDATE`
+	selectAdminConfigForUpdateStmt = `SELECT admin_configuration FROM realm_configuration WHERE realm_id = ? FOR UPDATE`
+	upsertConfigStmt               = `INSERT INTO realm_configuration (realm_id, configuration) VALUES (?, ?) ON DUPLICATE KEY UPDATE configuration = VALUES(configuration)`
+	upsertAdminConfigStmt          = `INSERT INTO realm_configuration (realm_id, admin_configuration) VALUES (?, ?) ON DUPLICATE KEY UPDATE admin_configuration = VALUES(admin_configuration)`
+	selectContextKeyForUpdateStmt  = `SELECT id, label, identities_realm, customer_realm, configuration, is_register_default FROM context_key_configuration WHERE id = ? FOR UPDATE`
+	selectOtherDefaultKeysStmt     = `SELECT id FROM context_key_configuration WHERE customer_realm = ? AND is_register_default = 1 AND id <> ? FOR UPDATE`
+	unsetDefaultContextKeyStmt     = `UPDATE context_key_configuration SET is_register_default = 0 WHERE id = ?`
+	upsertContextKeyStmt           = `INSERT INTO context_key_configuration (id, label, identities_realm, customer_realm, configuration, is_register_default)
+		VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE label = VALUES(label), identities_realm = VALUES(identities_realm),
+		configuration = VALUES(configuration), is_register_default = VALUES(is_register_default)`
+	deleteContextKeyStmt = `DELETE FROM context_key_configuration WHERE id = ? AND customer_realm = ?`
+)
+
+// Types of the audit events reported by ConfigurationWriterDBModule
+const (
+	EventUpdateRealmConfiguration      = "UPDATE_REALM_CONFIGURATION"
+	EventUpdateRealmAdminConfiguration = "UPDATE_REALM_ADMIN_CONFIGURATION"
+	EventCreateContextKey              = "CREATE_CONTEXT_KEY"
+	EventUpdateContextKey              = "UPDATE_CONTEXT_KEY"
+	EventDeleteContextKey              = "DELETE_CONTEXT_KEY"
+)
+
+var (
+	// ErrContextKeyOfOtherRealm is returned when a context key is updated with a customer realm which is not its own
+	ErrContextKeyOfOtherRealm = errors.New("context key belongs to another customer realm")
+)
+
+// AuditEventsReporter reports the audit events of the configuration changes. It is implemented by the events modules
+// of the database package
+type AuditEventsReporter interface {
+	ReportEvent(ctx context.Context, apiCall string, origin string, values ...string) error
+}
+
+// ConfigurationWriterDBModule updates the realm configurations, the context keys and the authorizations. Each change is
+// made in a transaction, retried on deadlocks and lock wait timeouts, and reported as an audit event
+type ConfigurationWriterDBModule struct {
+	db        sqltypes.CloudtrustDB
+	reporter  AuditEventsReporter
+	origin    string
+	authScope map[string]bool
+	onChange  ChangeListener
+	logger    log.Logger
+}
+
+// NewConfigurationWriterDBModule returns a ConfigurationWriterDBModule. origin is the component reported in the audit
+// events. As for NewConfigurationReaderDBModule, the given actions restrict the authorizations which can be managed
+func NewConfigurationWriterDBModule(db sqltypes.CloudtrustDB, reporter AuditEventsReporter, origin string, logger log.Logger, actions ...[]string) *ConfigurationWriterDBModule {
+	return &ConfigurationWriterDBModule{
+		db:        db,
+		reporter:  reporter,
+		origin:    origin,
+		authScope: newAuthorizationScope(actions...),
+		logger:    logger,
+	}
+}
+
+// OnChange registers the listener notified after each committed change of a realm configuration, of the context keys or
+// of the authorizations, usually the Notify method of a ChangeNotifier
+func (c *ConfigurationWriterDBModule) OnChange(listener ChangeListener) {
+	c.onChange = listener
+}
+
+// contextKeyFields is the representation of a context key used to list its changed fields
+type contextKeyFields struct {
+	Label             string                  `json:"label"`
+	IdentitiesRealm   string                  `json:"identities_realm"`
+	CustomerRealm     string                  `json:"customer_realm"`
+	Config            ContextKeyConfiguration `json:"configuration"`
+	IsRegisterDefault bool                    `json:"is_register_default"`
+}
+
+func newContextKeyFields(key RealmContextKey) contextKeyFields {
+	return contextKeyFields{
+		Label:             key.Label,
+		IdentitiesRealm:   key.IdentitiesRealm,
+		CustomerRealm:     key.CustomerRealm,
+		Config:            key.Config,
+		IsRegisterDefault: key.IsRegisterDefault,
+	}
+}
+
+// UpdateRealmConfiguration validates and creates or replaces the configuration of a realm
+func (c *ConfigurationWriterDBModule) UpdateRealmConfiguration(ctx context.Context, realmID string, config RealmConfiguration) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	var changed []string
+	var err = sqlretry.WithTransaction(ctx, c.db, nil, func(tx sqltypes.Transaction) error {
+		var current RealmConfiguration
+		if err := c.selectJSON(ctx, tx, selectConfigForUpdateStmt, realmID, &current); err != nil {
+			return err
+		}
+		var configJSON, err = json.Marshal(config)
+		if err != nil {
+			return err
+		}
+		if changed, err = changedFields(current, config); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, upsertConfigStmt, realmID, string(configJSON))
+		return err
+	})
+	if err != nil {
+		c.logger.Warn(ctx, "msg", "Can't update realm configuration", "realmID", realmID, "err", err.Error())
+		return err
+	}
+	c.reportEvent(ctx, EventUpdateRealmConfiguration, "realm_id", realmID, "changed_fields", strings.Join(changed, ","))
+	c.notifyChange(ctx, ChangeRealmConfiguration, realmID)
+	return nil
+}
+
+// UpdateRealmAdminConfiguration validates and creates or replaces the admin configuration of a realm
+func (c *ConfigurationWriterDBModule) UpdateRealmAdminConfiguration(ctx context.Context, realmID string, config RealmAdminConfiguration) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	var changed []string
+	var err = sqlretry.WithTransaction(ctx, c.db, nil, func(tx sqltypes.Transaction) error {
+		var current RealmAdminConfiguration
+		if err := c.selectJSON(ctx, tx, selectAdminConfigForUpdateStmt, realmID, &current); err != nil {
+			return err
+		}
+		var configJSON, err = json.Marshal(config)
+		if err != nil {
+			return err
+		}
+		if changed, err = changedFields(current, config); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, upsertAdminConfigStmt, realmID, string(configJSON))
+		return err
+	})
+	if err != nil {
+		c.logger.Warn(ctx, "msg", "Can't update realm admin configuration", "realmID", realmID, "err", err.Error())
+		return err
+	}
+	c.reportEvent(ctx, EventUpdateRealmAdminConfiguration, "realm_id", realmID, "changed_fields", strings.Join(changed, ","))
+	c.notifyChange(ctx, ChangeRealmConfiguration, realmID)
+	return nil
+}
+
+// CreateOrUpdateContextKey creates or replaces a context key. When the key is the register default one of its customer
+// realm, the previous default key of this realm is unset. A key can't be moved to another customer realm: the update of
+// a key of another realm fails with ErrContextKeyOfOtherRealm
+func (c *ConfigurationWriterDBModule) CreateOrUpdateContextKey(ctx context.Context, contextKey RealmContextKey) error {
+	var eventType string
+	var changed []string
+	var unsetDefaults []string
+	var err = sqlretry.WithTransaction(ctx, c.db, nil, func(tx sqltypes.Transaction) error {
+		// The transaction may be retried: the results of a previous attempt are discarded
+		eventType, unsetDefaults = EventUpdateContextKey, nil
+		var current contextKeyFields
+		var existing, err = c.reader().scanContextKeyConfiguration(tx.QueryRowContext(ctx, selectContextKeyForUpdateStmt, contextKey.ID))
+		switch err {
+		case nil:
+			if existing.CustomerRealm != contextKey.CustomerRealm {
+				return ErrContextKeyOfOtherRealm
+			}
+			current = newContextKeyFields(existing)
+		case sql.ErrNoRows:
+			eventType = EventCreateContextKey
+		default:
+			return err
+		}
+		if changed, err = changedFields(current, newContextKeyFields(contextKey)); err != nil {
+			return err
+		}
+
+		if contextKey.IsRegisterDefault {
+			if unsetDefaults, err = c.unsetOtherDefaultKeys(ctx, tx, contextKey); err != nil {
+				return err
+			}
+		}
+
+		configJSON, err := json.Marshal(contextKey.Config)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, upsertContextKeyStmt, contextKey.ID, contextKey.Label, contextKey.IdentitiesRealm,
+			contextKey.CustomerRealm, string(configJSON), contextKey.IsRegisterDefault)
+		return err
+	})
+	if err != nil {
+		c.logger.Warn(ctx, "msg", "Can't update context key", "id", contextKey.ID, "realm", contextKey.CustomerRealm, "err", err.Error())
+		return err
+	}
+	var values = []string{"context_key_id", contextKey.ID, "customer_realm", contextKey.CustomerRealm, "changed_fields", strings.Join(changed, ",")}
+	if len(unsetDefaults) > 0 {
+		values = append(values, "unset_default_context_keys", strings.Join(unsetDefaults, ","))
+	}
+	c.reportEvent(ctx, eventType, values...)
+	c.notifyChange(ctx, ChangeContextKeys, contextKey.CustomerRealm)
+	return nil
+}
+
+func (c *ConfigurationWriterDBModule) unsetOtherDefaultKeys(ctx context.Context, tx sqltypes.Transaction, contextKey RealmContextKey) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, selectOtherDefaultKeysStmt, contextKey.CustomerRealm, contextKey.ID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	_ = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if _, err = tx.ExecContext(ctx, unsetDefaultContextKeyStmt, id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// DeleteContextKey deletes a context key of a customer realm. Returns sql.ErrNoRows if the key does not exist
+func (c *ConfigurationWriterDBModule) DeleteContextKey(ctx context.Context, ctxKeyID string, customerRealm string) error {
+	var err = sqlretry.WithTransaction(ctx, c.db, nil, func(tx sqltypes.Transaction) error {
+		var res, err = tx.ExecContext(ctx, deleteContextKeyStmt, ctxKeyID, customerRealm)
+		if err != nil {
+			return err
+		}
+		count, err := res.RowsAffected()
+		if err == nil && count == 0 {
+			err = sql.ErrNoRows
+		}
+		return err
+	})
+	if err != nil {
+		c.logger.Warn(ctx, "msg", "Can't delete context key", "id", ctxKeyID, "realm", customerRealm, "err", err.Error())
+		return err
+	}
+	c.reportEvent(ctx, EventDeleteContextKey, "context_key_id", ctxKeyID, "customer_realm", customerRealm)
+	c.notifyChange(ctx, ChangeContextKeys, customerRealm)
+	return nil
+}
+
+func (c *ConfigurationWriterDBModule) reader() *ConfigurationReaderDBModule {
+	return &ConfigurationReaderDBModule{db: c.db, logger: c.logger}
+}
+
+// selectJSON decodes the JSON value returned by the given query. The value is left untouched if there is no row or if
+// the column is NULL
+func (c *ConfigurationWriterDBModule) selectJSON(ctx context.Context, tx sqltypes.Transaction, query string, realmID string, value any) error {
+	var configJSON sql.NullString
+	switch err := tx.QueryRowContext(ctx, query, realmID).Scan(&configJSON); err {
+	case nil:
+		if !configJSON.Valid {
+			return nil
+		}
+		return json.Unmarshal([]byte(configJSON.String), value)
+	case sql.ErrNoRows:
+		return nil
+	default:
+		return err
+	}
+}
+
+// reportEvent reports an audit event. The change is already committed: a failure is only logged
+func (c *ConfigurationWriterDBModule) reportEvent(ctx context.Context, eventType string, values ...string) {
+	if c.reporter == nil {
+		return
+	}
+	if err := c.reporter.ReportEvent(ctx, eventType, c.origin, values...); err != nil {
+		c.logger.Error(ctx, "msg", "Can't report configuration change", "type", eventType, "err", err.Error())
+	}
+}
+
+func (c *ConfigurationWriterDBModule) notifyChange(ctx context.Context, kind ChangeKind, realmID string) {
+	if c.onChange != nil {
+		c.onChange(ctx, ChangeEvent{Kind: kind, RealmID: realmID, UpdatedAt: time.Now()})
+	}
+}
+
+// changedFields returns the sorted JSON names of the top-level fields whose values differ between before and after
+func changedFields(before, after any) ([]string, error) {
+	var beforeFields, err = jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for name, value := range afterFields {
+		if !reflect.DeepEqual(beforeFields[name], value) {
+			res = append(res, name)
+		}
+	}
+	for name := range beforeFields {
+		if _, ok := afterFields[name]; !ok {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func jsonFields(value any) (map[string]any, error) {
+	var bytes, err = json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var res map[string]any
+	err = json.Unmarshal(bytes, &res)
+	return res, err
+}
@@ -0,0 +1,328 @@
+package configuration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/cloudtrust/common-service/v2/database/dbtest"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type reportedEvent struct {
+	eventType string
+	origin    string
+	values    []string
+}
+
+// recordingReporter is an AuditEventsReporter recording the reported events
+type recordingReporter struct {
+	events []reportedEvent
+	err    error
+}
+
+func (r *recordingReporter) ReportEvent(_ context.Context, apiCall string, origin string, values ...string) error {
+	r.events = append(r.events, reportedEvent{eventType: apiCall, origin: origin, values: values})
+	return r.err
+}
+
+func TestChangedFields(t *testing.T) {
+	var mode = "trustID"
+	var enabled = true
+
+	var changed, err = changedFields(RealmAdminConfiguration{}, RealmAdminConfiguration{Mode: &mode, SelfRegisterEnabled: &enabled})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mode", "self_register_enabled"}, changed)
+
+	changed, err = changedFields(RealmConfiguration{AllowedBackURLs: []string{"a"}}, RealmConfiguration{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"allowed_back_urls"}, changed)
+
+	changed, err = changedFields(RealmAdminConfiguration{Mode: &mode}, RealmAdminConfiguration{Mode: &mode})
+	assert.Nil(t, err)
+	assert.Len(t, changed, 0)
+}
+
+func TestUpdateRealmConfigurations(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var reporter = &recordingReporter{}
+	var module = NewConfigurationWriterDBModule(db, reporter, "back-office", log.NewNopLogger())
+	var ctx = context.TODO()
+	var realmID = "realm-id"
+	var clientID = "client"
+	var mode = "trustID"
+
+	t.Run("Update configuration", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On("SELECT configuration FROM realm_configuration").WithArgs(realmID).WithRows([]any{`{"default_client_id":"old","barcode_type":null}`})
+		db.On("INSERT INTO realm_configuration").WithArgs(realmID, `{"default_client_id":"client","barcode_type":null}`)
+
+		assert.Nil(t, module.UpdateRealmConfiguration(ctx, realmID, RealmConfiguration{DefaultClientID: &clientID}))
+		assert.True(t, db.Transactions()[0].IsCommitted())
+		assert.Equal(t, []reportedEvent{{eventType: EventUpdateRealmConfiguration, origin: "back-office",
+			values: []string{"realm_id", realmID, "changed_fields", "default_client_id"}}}, reporter.events)
+	})
+
+	t.Run("Create admin configuration", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On("SELECT admin_configuration FROM realm_configuration").WithArgs(realmID)
+		db.On("INSERT INTO realm_configuration")
+
+		assert.Nil(t, module.UpdateRealmAdminConfiguration(ctx, realmID, RealmAdminConfiguration{Mode: &mode}))
+		assert.True(t, db.Transactions()[0].IsCommitted())
+		assert.Equal(t, []string{"realm_id", realmID, "changed_fields", "mode"}, reporter.events[0].values)
+	})
+
+	t.Run("Admin configuration is NULL", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On("SELECT admin_configuration FROM realm_configuration").WithRows([]any{nil})
+		db.On("INSERT INTO realm_configuration")
+
+		assert.Nil(t, module.UpdateRealmAdminConfiguration(ctx, realmID, RealmAdminConfiguration{Mode: &mode}))
+		assert.Len(t, reporter.events, 1)
+	})
+
+	t.Run("Update fails: transaction is rolled back and no event is reported", func(t *testing.T) {
+		var dbErr = errors.New("db error")
+		db.Reset()
+		reporter.events = nil
+		db.On("SELECT configuration FROM realm_configuration")
+		db.On("INSERT INTO realm_configuration").WithError(dbErr)
+
+		assert.Equal(t, dbErr, module.UpdateRealmConfiguration(ctx, realmID, RealmConfiguration{}))
+		assert.True(t, db.Transactions()[0].IsRolledBack())
+		assert.Len(t, reporter.events, 0)
+	})
+
+	t.Run("Invalid stored configuration", func(t *testing.T) {
+		db.Reset()
+		db.On("SELECT admin_configuration FROM realm_configuration").WithRows([]any{"{"})
+
+		assert.NotNil(t, module.UpdateRealmAdminConfiguration(ctx, realmID, RealmAdminConfiguration{}))
+		assert.Len(t, db.StatementsMatching("INSERT"), 0)
+	})
+
+	t.Run("Commit fails", func(t *testing.T) {
+		var dbErr = errors.New("commit error")
+		db.Reset()
+		db.On("SELECT configuration FROM realm_configuration")
+		db.On("INSERT INTO realm_configuration")
+		db.FailCommit(dbErr)
+
+		assert.Equal(t, dbErr, module.UpdateRealmConfiguration(ctx, realmID, RealmConfiguration{}))
+	})
+
+	t.Run("Event can't be reported: change is kept", func(t *testing.T) {
+		db.Reset()
+		reporter.err = errors.New("report error")
+		defer func() { reporter.err = nil }()
+		db.On("SELECT configuration FROM realm_configuration")
+		db.On("INSERT INTO realm_configuration")
+
+		assert.Nil(t, module.UpdateRealmConfiguration(ctx, realmID, RealmConfiguration{}))
+		assert.True(t, db.Transactions()[0].IsCommitted())
+	})
+}
+
+func TestCreateOrUpdateContextKey(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var reporter = &recordingReporter{}
+	var module = NewConfigurationWriterDBModule(db, reporter, "back-office", log.NewNopLogger())
+	var ctx = context.TODO()
+	var uri = "https://idnow"
+	var contextKey = RealmContextKey{
+		ID:              "key-id",
+		Label:           "label",
+		IdentitiesRealm: "identities",
+		CustomerRealm:   "customer",
+		Config:          ContextKeyConfiguration{IdentificationURI: &uri},
+	}
+	var configJSON = `{"identification-uri":"https://idnow","onboarding":null,"accreditation":null,"autovoucher":null}`
+
+	t.Run("Create context key", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On("SELECT id, label, identities_realm, customer_realm, configuration, is_register_default").WithArgs("key-id")
+		db.On("INSERT INTO context_key_configuration").WithArgs("key-id", "label", "identities", "customer", configJSON, false)
+
+		assert.Nil(t, module.CreateOrUpdateContextKey(ctx, contextKey))
+		assert.Len(t, db.StatementsMatching("is_register_default = 1"), 0)
+		assert.Equal(t, EventCreateContextKey, reporter.events[0].eventType)
+		assert.Equal(t, []string{"context_key_id", "key-id", "customer_realm", "customer", "changed_fields",
+			"configuration,customer_realm,identities_realm,label"}, reporter.events[0].values)
+	})
+
+	t.Run("Update context key as register default", func(t *testing.T) {
+		var defaultKey = contextKey
+		defaultKey.IsRegisterDefault = true
+		db.Reset()
+		reporter.events = nil
+		db.On("SELECT id, label, identities_realm, customer_realm, configuration, is_register_default").
+			WithRows([]any{"key-id", "label", "identities", "customer", configJSON, false})
+		db.On("SELECT id FROM context_key_configuration").WithArgs("customer", "key-id").WithRows([]any{"previous-default"})
+		db.On("UPDATE context_key_configuration SET is_register_default = 0").WithArgs("previous-default")
+		db.On("INSERT INTO context_key_configuration").WithArgs("key-id", "label", "identities", "customer", configJSON, true)
+
+		assert.Nil(t, module.CreateOrUpdateContextKey(ctx, defaultKey))
+		assert.True(t, db.Transactions()[0].IsCommitted())
+		assert.Len(t, db.StatementsMatching("UPDATE context_key_configuration"), 1)
+		assert.Equal(t, EventUpdateContextKey, reporter.events[0].eventType)
+		assert.Equal(t, []string{"context_key_id", "key-id", "customer_realm", "customer", "changed_fields", "is_register_default",
+			"unset_default_context_keys", "previous-default"}, reporter.events[0].values)
+	})
+
+	t.Run("Can't unset previous default key", func(t *testing.T) {
+		var defaultKey = contextKey
+		defaultKey.IsRegisterDefault = true
+		var dbErr = errors.New("db error")
+		db.Reset()
+		reporter.events = nil
+		db.On("SELECT id, label, identities_realm, customer_realm, configuration, is_register_default")
+		db.On("SELECT id FROM context_key_configuration").WithRows([]any{"previous-default"})
+		db.On("UPDATE context_key_configuration").WithError(dbErr)
+
+		assert.Equal(t, dbErr, module.CreateOrUpdateContextKey(ctx, defaultKey))
+		assert.True(t, db.Transactions()[0].IsRolledBack())
+		assert.Len(t, db.StatementsMatching("INSERT"), 0)
+		assert.Len(t, reporter.events, 0)
+	})
+
+	t.Run("Key of another customer realm", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On("SELECT id, label, identities_realm, customer_realm, configuration, is_register_default").
+			WithRows([]any{"key-id", "label", "identities", "other-customer", configJSON, false})
+
+		assert.Equal(t, ErrContextKeyOfOtherRealm, module.CreateOrUpdateContextKey(ctx, contextKey))
+		assert.True(t, db.Transactions()[0].IsRolledBack())
+		assert.Len(t, db.StatementsMatching("INSERT"), 0)
+		assert.Len(t, reporter.events, 0)
+	})
+
+	t.Run("Can't read existing key", func(t *testing.T) {
+		var dbErr = errors.New("db error")
+		db.Reset()
+		db.On("SELECT id, label").WithError(dbErr)
+
+		assert.Equal(t, dbErr, module.CreateOrUpdateContextKey(ctx, contextKey))
+	})
+
+	t.Run("Deadlock is retried", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On("SELECT id, label, identities_realm, customer_realm, configuration, is_register_default").Once()
+		db.On("INSERT INTO context_key_configuration").WithError(errors.New("Error 1213 (40001): Deadlock found when trying to get lock")).Once()
+		db.On("SELECT id, label, identities_realm, customer_realm, configuration, is_register_default").
+			WithRows([]any{"key-id", "old-label", "identities", "customer", configJSON, false})
+		db.On("INSERT INTO context_key_configuration")
+
+		assert.Nil(t, module.CreateOrUpdateContextKey(ctx, contextKey))
+		assert.Len(t, db.Transactions(), 2)
+		assert.True(t, db.Transactions()[0].IsRolledBack())
+		assert.True(t, db.Transactions()[1].IsCommitted())
+		// The key was created by the concurrent writer
+		assert.Equal(t, EventUpdateContextKey, reporter.events[0].eventType)
+		assert.Equal(t, []string{"context_key_id", "key-id", "customer_realm", "customer", "changed_fields", "label"}, reporter.events[0].values)
+	})
+}
+
+func TestDeleteContextKey(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var reporter = &recordingReporter{}
+	var module = NewConfigurationWriterDBModule(db, reporter, "back-office", log.NewNopLogger())
+	var ctx = context.TODO()
+
+	t.Run("Delete context key", func(t *testing.T) {
+		db.Reset()
+		db.On("DELETE FROM context_key_configuration").WithArgs("key-id", "customer").WithRowsAffected(1)
+
+		assert.Nil(t, module.DeleteContextKey(ctx, "key-id", "customer"))
+		assert.Equal(t, []reportedEvent{{eventType: EventDeleteContextKey, origin: "back-office",
+			values: []string{"context_key_id", "key-id", "customer_realm", "customer"}}}, reporter.events)
+	})
+
+	t.Run("Unknown context key", func(t *testing.T) {
+		db.Reset()
+		reporter.events = nil
+		db.On("DELETE FROM context_key_configuration").WithRowsAffected(0)
+
+		assert.Equal(t, sql.ErrNoRows, module.DeleteContextKey(ctx, "key-id", "customer"))
+		assert.True(t, db.Transactions()[0].IsRolledBack())
+		assert.Len(t, reporter.events, 0)
+	})
+
+	t.Run("Can't begin transaction", func(t *testing.T) {
+		var dbErr = errors.New("db error")
+		db.Reset()
+		db.FailBeginTx(dbErr)
+
+		assert.Equal(t, dbErr, module.DeleteContextKey(ctx, "key-id", "customer"))
+	})
+}
+
+func TestWriterNotifiesChanges(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var module = NewConfigurationWriterDBModule(db, &recordingReporter{}, "back-office", log.NewNopLogger())
+	var listener = &recordingListener{}
+	var ctx = context.TODO()
+	var otherRealm = "other-realm"
+	module.OnChange(listener.listen)
+
+	db.On("SELECT configuration FROM realm_configuration")
+	db.On("INSERT INTO realm_configuration")
+	db.On("INSERT INTO authorizations")
+
+	assert.Nil(t, module.UpdateRealmConfiguration(ctx, "realm", RealmConfiguration{}))
+	assert.Nil(t, module.CreateAuthorizations(ctx, []Authorization{newAuthorization("realm", "group", "action", nil, nil)}))
+	assert.Nil(t, module.CreateAuthorizations(ctx, []Authorization{
+		newAuthorization("realm", "group", "action", nil, nil),
+		newAuthorization(otherRealm, "group", "action", nil, nil),
+	}))
+
+	assert.Len(t, listener.events, 3)
+	assert.Equal(t, ChangeRealmConfiguration, listener.events[0].Kind)
+	assert.Equal(t, "realm", listener.events[0].RealmID)
+	assert.Equal(t, ChangeAuthorizations, listener.events[1].Kind)
+	assert.Equal(t, "realm", listener.events[1].RealmID)
+	assert.Equal(t, "", listener.events[2].RealmID)
+
+	t.Run("Context key changes", func(t *testing.T) {
+		listener.events = nil
+		db.On("SELECT id, label")
+		db.On("INSERT INTO context_key_configuration")
+		db.On("DELETE FROM context_key_configuration").WithRowsAffected(1)
+
+		assert.Nil(t, module.CreateOrUpdateContextKey(ctx, RealmContextKey{ID: "key-id", CustomerRealm: "customer"}))
+		assert.Nil(t, module.DeleteContextKey(ctx, "key-id", "customer"))
+		assert.Len(t, listener.events, 2)
+		for _, event := range listener.events {
+			assert.Equal(t, ChangeContextKeys, event.Kind)
+			assert.Equal(t, "customer", event.RealmID)
+		}
+	})
+
+	t.Run("Failed change is not notified", func(t *testing.T) {
+		listener.events = nil
+		db.Reset()
+		db.FailBeginTx(errors.New("db error"))
+
+		assert.NotNil(t, module.UpdateRealmConfiguration(ctx, "realm", RealmConfiguration{}))
+		assert.Len(t, listener.events, 0)
+	})
+}
+
+func TestUpdateInvalidRealmConfigurations(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var module = NewConfigurationWriterDBModule(db, &recordingReporter{}, "back-office", log.NewNopLogger())
+	var ctx = context.TODO()
+	var invalid = "invalid"
+
+	assert.NotNil(t, module.UpdateRealmConfiguration(ctx, "realm", RealmConfiguration{DefaultRedirectURI: &invalid}))
+	assert.NotNil(t, module.UpdateRealmAdminConfiguration(ctx, "realm", RealmAdminConfiguration{Mode: &invalid}))
+	assert.Len(t, db.Transactions(), 0)
+}
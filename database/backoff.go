@@ -0,0 +1,6 @@
+package database
+
+import "github.com/cloudtrust/common-service/v2/database/sqlretry"
+
+// BackoffPolicy defines an exponential backoff. See sqlretry.BackoffPolicy
+type BackoffPolicy = sqlretry.BackoffPolicy
@@ -2,45 +2,25 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"errors"
 	"fmt"
-
-	"regexp"
-	"strconv"
+	"io/fs"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	cs "github.com/cloudtrust/common-service/v2"
+	"github.com/cloudtrust/common-service/v2/database/migration"
 	"github.com/cloudtrust/common-service/v2/database/sqltypes"
 	"github.com/cloudtrust/common-service/v2/log"
 )
 
-// Define an internal structure to manage DB versions
-type dbVersion struct {
-	major int
-	minor int
-}
-
-func newDbVersion(version string) (*dbVersion, error) {
-	var r = regexp.MustCompile(`^(\d+)\.(\d+)$`)
-	var match = r.FindStringSubmatch(version)
-	if match == nil {
-		return nil, fmt.Errorf("version %s does not match the required format", version)
-	}
-	// We don't test the Atoi errors as version matches the regexp
-	// Major/Minor version numbers are stored in groups 1 and 2. Group 0 is the whole matched valued
-	var maj, _ = strconv.Atoi(match[1])
-	var min, _ = strconv.Atoi(match[2])
-	return &dbVersion{
-		major: maj,
-		minor: min,
-	}, nil
-}
-
-func (v *dbVersion) matchesRequired(required *dbVersion) bool {
-	return !(v.major < required.major || (v.major == required.major && v.minor < required.minor))
-}
+const (
+	selectFlywayVersionsStmt = `SELECT version FROM flyway_schema_history WHERE version IS NOT NULL AND success = 1`
+)
 
 type basicCloudtrustDB struct {
 	dbConn            *sql.DB
@@ -67,14 +47,30 @@ func (db *basicCloudtrustDB) QueryRow(query string, args ...any) sqltypes.SQLRow
 	return db.dbConn.QueryRow(query, args...)
 }
 
+func (db *basicCloudtrustDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.dbConn.ExecContext(ctx, query, args...)
+}
+
+func (db *basicCloudtrustDB) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	return db.dbConn.QueryContext(ctx, query, args...)
+}
+
+func (db *basicCloudtrustDB) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	return db.dbConn.QueryRowContext(ctx, query, args...)
+}
+
 func (db *basicCloudtrustDB) Ping() error {
+	return db.PingContext(context.Background())
+}
+
+func (db *basicCloudtrustDB) PingContext(ctx context.Context) error {
 	if db.pingTimeoutMillis > 0 {
-		var ctxTimeout, cancelTimeout = context.WithTimeout(context.Background(), time.Millisecond*db.pingTimeoutMillis)
+		var ctxTimeout, cancelTimeout = context.WithTimeout(ctx, time.Millisecond*db.pingTimeoutMillis)
 		defer cancelTimeout()
 
 		return db.dbConn.PingContext(ctxTimeout)
 	}
-	return db.dbConn.Ping()
+	return db.dbConn.PingContext(ctx)
 }
 
 func (db *basicCloudtrustDB) Close() error {
@@ -87,25 +83,48 @@ func (db *basicCloudtrustDB) Stats() sql.DBStats {
 
 // DbConfig Db configuration parameters
 type DbConfig struct {
-	Enabled           bool   `mapstructure:"enabled"`
-	HostPort          string `mapstructure:"host-port"`
-	Username          string `mapstructure:"username"`
-	Password          string `mapstructure:"password"`
-	Database          string `mapstructure:"database"`
-	Protocol          string `mapstructure:"protocol"`
+	Enabled  bool   `mapstructure:"enabled"`
+	HostPort string `mapstructure:"host-port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// PasswordFile is a file containing the password (mounted secret). When provided, it is read each time a connection
+	// is opened and overrides Password
+	PasswordFile string `mapstructure:"password-file"`
+	Database     string `mapstructure:"database"`
+	Protocol     string `mapstructure:"protocol"`
+	// Parameters are the driver parameters (param1=value1&...&paramN=valueN). Values are written as is, without URL
+	// escaping: they are escaped when the connection string is built
 	Parameters        string `mapstructure:"parameters"`
 	MaxOpenConns      int    `mapstructure:"max-open-conns"`
 	MaxIdleConns      int    `mapstructure:"max-idle-conns"`
 	ConnMaxLifetime   int    `mapstructure:"conn-max-lifetime"`
 	MigrationEnabled  bool   `mapstructure:"migration"`
 	MigrationVersion  string `mapstructure:"migration-version"`
+	MigrationDryRun   bool   `mapstructure:"migration-dry-run"`
 	ConnectionCheck   bool   `mapstructure:"connection-check"`
 	PingTimeoutMillis int    `mapstructure:"ping-timeout-ms"`
+	// ReplicaHostPorts are the read replicas. When provided, read-only queries are sent to these replicas
+	ReplicaHostPorts []string `mapstructure:"replica-host-ports"`
+	// TLSCAFile, TLSCertFile and TLSKeyFile are PEM files used to verify the server and to authenticate the client. They
+	// are read each time a connection is opened
+	TLSCAFile   string `mapstructure:"tls-ca-file"`
+	TLSCertFile string `mapstructure:"tls-cert-file"`
+	TLSKeyFile  string `mapstructure:"tls-key-file"`
+	// RegisterTLSConfig registers a TLS configuration in the MySQL driver (usually mysql.RegisterTLSConfig). Required when
+	// TLS files are configured. Set by Registry.OpenFromConfig from RegistryOptions.RegisterTLSConfig
+	RegisterTLSConfig func(name string, config *tls.Config) error `mapstructure:"-"`
+	// Migrations are the versioned SQL scripts (V<version>__<description>.sql) applied when MigrationEnabled is true.
+	// When nil, the schema version is only checked against MigrationVersion. Set by Registry.OpenFromConfig from
+	// RegistryOptions.Migrations
+	Migrations fs.FS `mapstructure:"-"`
+	// Logger reports the applied migrations. Optional
+	Logger log.Logger `mapstructure:"-"`
 }
 
 // ConfigureDbDefaultForKey configure default database parameters for a given prefix
 // Parameters are built with the given prefix, then a dot symbol, then one of these suffixes:
-// host-port, username, password, database, protocol, max-open-conns, max-idle-conns, conn-max-lifetime
+// host-port, username, password, password-file, database, protocol, max-open-conns, max-idle-conns, conn-max-lifetime,
+// replica-host-ports, tls-ca-file, tls-cert-file, tls-key-file
 // If a parameter exists only named with the given prefix and if its value if false, the database connection
 // will be a Noop one
 func ConfigureDbDefaultForKey(v cs.Configuration, prefix, envUser, envPasswd string) {
@@ -121,8 +140,14 @@ func ConfigureDbDefaultForKey(v cs.Configuration, prefix, envUser, envPasswd str
 	v.SetDefault(prefix+".conn-max-lifetime", 3600)
 	v.SetDefault(prefix+".migration", false)
 	v.SetDefault(prefix+".migration-version", "")
+	v.SetDefault(prefix+".migration-dry-run", false)
 	v.SetDefault(prefix+".connection-check", true)
 	v.SetDefault(prefix+".ping-timeout-ms", 1500)
+	v.SetDefault(prefix+".replica-host-ports", []string{})
+	v.SetDefault(prefix+".password-file", "")
+	v.SetDefault(prefix+".tls-ca-file", "")
+	v.SetDefault(prefix+".tls-cert-file", "")
+	v.SetDefault(prefix+".tls-key-file", "")
 
 	_ = v.BindEnv(prefix+".username", envUser)
 	_ = v.BindEnv(prefix+".password", envPasswd)
@@ -130,7 +155,8 @@ func ConfigureDbDefaultForKey(v cs.Configuration, prefix, envUser, envPasswd str
 
 // ConfigureDbDefault configure default database parameters for a given prefix
 // Parameters are built with the given prefix, then a dash symbol, then one of these suffixes:
-// host-port, username, password, database, protocol, max-open-conns, max-idle-conns, conn-max-lifetime
+// host-port, username, password, password-file, database, protocol, max-open-conns, max-idle-conns, conn-max-lifetime,
+// replica-host-ports, tls-ca-file, tls-cert-file, tls-key-file
 // If a parameter exists only named with the given prefix and if its value if false, the database connection
 // will be a Noop one
 func ConfigureDbDefault(v cs.Configuration, prefix, envUser, envPasswd string) {
@@ -146,8 +172,14 @@ func ConfigureDbDefault(v cs.Configuration, prefix, envUser, envPasswd string) {
 	v.SetDefault(prefix+"-conn-max-lifetime", 3600)
 	v.SetDefault(prefix+"-migration", false)
 	v.SetDefault(prefix+"-migration-version", "")
+	v.SetDefault(prefix+"-migration-dry-run", false)
 	v.SetDefault(prefix+"-connection-check", true)
 	v.SetDefault(prefix+"-ping-timeout-ms", 1500)
+	v.SetDefault(prefix+"-replica-host-ports", []string{})
+	v.SetDefault(prefix+"-password-file", "")
+	v.SetDefault(prefix+"-tls-ca-file", "")
+	v.SetDefault(prefix+"-tls-cert-file", "")
+	v.SetDefault(prefix+"-tls-key-file", "")
 
 	_ = v.BindEnv(prefix+"-username", envUser)
 	_ = v.BindEnv(prefix+"-password", envPasswd)
@@ -176,42 +208,96 @@ func GetDbConfigExt(v cs.Configuration, prefix string, noop bool) *DbConfig {
 		cfg.ConnMaxLifetime = v.GetInt(prefix + "-conn-max-lifetime")
 		cfg.MigrationEnabled = v.GetBool(prefix + "-migration")
 		cfg.MigrationVersion = v.GetString(prefix + "-migration-version")
+		cfg.MigrationDryRun = v.GetBool(prefix + "-migration-dry-run")
 		cfg.ConnectionCheck = v.GetBool(prefix + "-connection-check")
 		cfg.PingTimeoutMillis = v.GetInt(prefix + "-ping-timeout-ms")
+		cfg.ReplicaHostPorts = v.GetStringSlice(prefix + "-replica-host-ports")
+		cfg.PasswordFile = v.GetString(prefix + "-password-file")
+		cfg.TLSCAFile = v.GetString(prefix + "-tls-ca-file")
+		cfg.TLSCertFile = v.GetString(prefix + "-tls-cert-file")
+		cfg.TLSKeyFile = v.GetString(prefix + "-tls-key-file")
 	}
 
 	return &cfg
 }
 
-func (cfg *DbConfig) getDbConnectionString() string {
-	var separ = ""
-	if len(cfg.Parameters) > 0 {
-		separ = "?"
-	}
-	return fmt.Sprintf("%s:%s@%s(%s)/%s%s%s", cfg.Username, cfg.Password, cfg.Protocol, cfg.HostPort, cfg.Database, separ, cfg.Parameters)
-}
-
 // OpenDatabase gets an access to a database
 // If cfg.Noop is true, a Noop access will be provided
+// If read replicas are configured, read-only queries will be routed to them
+// The password file and the TLS files are read each time the database is opened: a ReconnectableCloudtrustDB uses the
+// rotated secrets when it reconnects
 func (cfg *DbConfig) OpenDatabase() (sqltypes.CloudtrustDB, error) {
 	if !cfg.Enabled {
 		return &NoopDB{}, nil
 	}
 
-	sqlConn, err := sql.Open("mysql", cfg.getDbConnectionString())
+	dbConn, err := cfg.openPrimaryDatabase()
+	if err != nil || len(cfg.ReplicaHostPorts) == 0 {
+		return dbConn, err
+	}
+
+	var replicas []sqltypes.CloudtrustDB
+	for _, hostPort := range cfg.ReplicaHostPorts {
+		var replica, err = cfg.openReplicaDatabase(hostPort)
+		if err != nil {
+			_ = dbConn.Close()
+			for _, opened := range replicas {
+				_ = opened.Close()
+			}
+			return nil, err
+		}
+		replicas = append(replicas, replica)
+	}
+	return NewRoutingCloudtrustDB(dbConn, replicas), nil
+}
+
+func (cfg *DbConfig) setPoolParameters(sqlConn *sql.DB) {
+	// the config of the DB should have a max_connections > SetMaxOpenConns
+	sqlConn.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlConn.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlConn.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
+}
+
+// openReplicaDatabase opens a read replica. Schema migration is only checked on the primary database and an unreachable
+// replica does not prevent the service from starting: it will just be ignored until it becomes reachable
+func (cfg *DbConfig) openReplicaDatabase(hostPort string) (sqltypes.CloudtrustDB, error) {
+	var replicaCfg = *cfg
+	replicaCfg.HostPort = hostPort
+
+	dsn, err := replicaCfg.getDbConnectionString()
+	if err != nil {
+		return nil, err
+	}
+	sqlConn, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.setPoolParameters(sqlConn)
+	return &basicCloudtrustDB{dbConn: sqlConn, pingTimeoutMillis: time.Duration(cfg.PingTimeoutMillis)}, nil
+}
+
+func (cfg *DbConfig) openPrimaryDatabase() (sqltypes.CloudtrustDB, error) {
+	dsn, err := cfg.getDbConnectionString()
+	if err != nil {
+		return nil, err
+	}
+	sqlConn, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, err
 	}
 	dbConn := &basicCloudtrustDB{dbConn: sqlConn, pingTimeoutMillis: time.Duration(cfg.PingTimeoutMillis)}
 
-	// DB migration version
-	// checking that the flyway_schema_history has the minimum imposed migration version
+	// DB migration
+	// applying the embedded migration scripts if any, then checking that the flyway_schema_history has the minimum imposed migration version
 	if cfg.MigrationEnabled {
-		if cfg.MigrationVersion == "" {
+		if cfg.MigrationVersion == "" && cfg.Migrations == nil {
 			// DB schema versioning is enabled but no minimum version was given
 			return nil, errors.New("Check of database schema is enabled, but no minimum version provided")
 		}
-		err = cfg.checkMigrationVersion(dbConn)
+		err = cfg.migrateDatabase(dbConn)
+		if err == nil && cfg.MigrationVersion != "" {
+			err = cfg.checkMigrationVersion(dbConn)
+		}
 		if err != nil {
 			_ = dbConn.Close()
 			dbConn = nil
@@ -221,50 +307,170 @@ func (cfg *DbConfig) OpenDatabase() (sqltypes.CloudtrustDB, error) {
 		err = dbConn.Ping()
 	}
 
-	// the config of the DB should have a max_connections > SetMaxOpenConns
 	if err == nil {
-		sqlConn.SetMaxOpenConns(cfg.MaxOpenConns)
-		sqlConn.SetMaxIdleConns(cfg.MaxIdleConns)
-		sqlConn.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
+		cfg.setPoolParameters(sqlConn)
 	}
 
 	return dbConn, err
 }
 
+func (cfg *DbConfig) migrateDatabase(conn sqltypes.CloudtrustDB) error {
+	if cfg.Migrations == nil {
+		return nil
+	}
+	var migrator = migration.NewMigrator(conn, cfg.Migrations, migration.Options{DryRun: cfg.MigrationDryRun}, cfg.Logger)
+	pending, err := migrator.Migrate(context.Background())
+	if err == nil && cfg.MigrationDryRun && len(pending) > 0 {
+		var scripts []string
+		for _, m := range pending {
+			scripts = append(scripts, m.Script)
+		}
+		err = fmt.Errorf("Database schema not up-to-date (pending migrations: %s)", strings.Join(scripts, ", "))
+	}
+	return err
+}
+
+// checkMigrationVersion checks that the flyway_schema_history has the minimum imposed migration version.
+// Versions can have any number of segments separated by dots or underscores (3, 2.14, 2_14_1). Repeatable migrations
+// (without version) and failed migrations are ignored. Baseline rows are considered as the version they define
 func (cfg *DbConfig) checkMigrationVersion(conn sqltypes.CloudtrustDB) error {
-	var flywayVersion string
-	row := conn.QueryRow(`SELECT version FROM flyway_schema_history ORDER BY installed_rank DESC LIMIT 1`)
-	err := row.Scan(&flywayVersion)
+	requiredVersion, err := migration.ParseVersion(cfg.MigrationVersion)
+	if err != nil {
+		return err
+	}
 
-	//flyway version and db migration version must match the format x.y where x and y are integers
-	var requiredDbVersion, flywayDbVersion *dbVersion
-	if err == nil {
-		requiredDbVersion, err = newDbVersion(cfg.MigrationVersion)
+	currentVersion, err := getFlywayVersion(conn)
+	if err != nil {
+		return err
 	}
-	if err == nil {
-		flywayDbVersion, err = newDbVersion(flywayVersion)
+
+	// it is required for the last script version of the flyway is to be "bigger" than the required version
+	if currentVersion.Compare(requiredVersion) < 0 {
+		var current = currentVersion.String()
+		if current == "" {
+			current = "none"
+		}
+		var pending, loadErr = cfg.describePendingMigrations(currentVersion, requiredVersion)
+		err = fmt.Errorf("Database schema not up-to-date (current: %s, required: %s, %s)", current, cfg.MigrationVersion, pending)
+		if loadErr != nil {
+			err = errors.Join(err, fmt.Errorf("can't load migration scripts: %w", loadErr))
+		}
+		return err
 	}
+	return nil
+}
 
-	// compare the two versions of the type x.y (major.minor)
+func getFlywayVersion(conn sqltypes.CloudtrustDB) (migration.Version, error) {
+	rows, err := conn.Query(selectFlywayVersionsStmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	// it is required for the last script version of the flyway is to be "bigger" than the required version
-	if err == nil && !flywayDbVersion.matchesRequired(requiredDbVersion) {
-		err = fmt.Errorf("Database schema not up-to-date (current: %s, required: %s)", flywayVersion, cfg.MigrationVersion)
+	var current migration.Version
+	for rows.Next() {
+		var value string
+		if err = rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		version, err := migration.ParseVersion(value)
+		if err != nil {
+			return nil, err
+		}
+		if version.Compare(current) > 0 {
+			current = version
+		}
 	}
+	return current, rows.Err()
+}
 
-	return err
+// describePendingMigrations lists the known migration scripts which are expected to bring the database from its current
+// version to the required one. Without migration scripts, only the range of the pending versions can be described
+func (cfg *DbConfig) describePendingMigrations(current, required migration.Version) (string, error) {
+	if cfg.Migrations == nil {
+		var from = "the first version"
+		if len(current) > 0 {
+			from = "version " + current.String()
+		}
+		return fmt.Sprintf("pending migrations: versions after %s up to %s, scripts not provided", from, required.String()), nil
+	}
+	migrations, err := migration.LoadMigrations(cfg.Migrations)
+	if err != nil {
+		return "pending migrations unknown", err
+	}
+	var scripts []string
+	for _, m := range migrations {
+		if m.Version.Compare(current) > 0 && m.Version.Compare(required) <= 0 {
+			scripts = append(scripts, m.Script)
+		}
+	}
+	if len(scripts) == 0 {
+		return "no migration script available", nil
+	}
+	return "pending migrations: " + strings.Join(scripts, ", "), nil
+}
+
+// Circuit breaker states of a ReconnectableCloudtrustDB
+const (
+	// CircuitClosed: the database is reachable
+	CircuitClosed = "closed"
+	// CircuitOpen: the database is known to be down. Calls fail fast until the next reconnection attempt
+	CircuitOpen = "open"
+	// CircuitHalfOpen: a reconnection attempt is in progress
+	CircuitHalfOpen = "half-open"
+)
+
+var (
+	// ErrCircuitOpen is returned by a ReconnectableCloudtrustDB while the database is known to be down
+	ErrCircuitOpen = errors.New("database unavailable: circuit breaker is open")
+
+	circuitStates = []string{CircuitClosed, CircuitOpen, CircuitHalfOpen}
+)
+
+// Indexes of circuitStates
+const (
+	circuitClosed int32 = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// DefaultReconnectBackoff returns the backoff policy used between reconnection attempts by default
+func DefaultReconnectBackoff() BackoffPolicy {
+	return BackoffPolicy{
+		InitialInterval: 500 * time.Millisecond,
+		MaxInterval:     30 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+	}
 }
 
 // ReconnectableCloudtrustDB implements an auto-reconnect mechanism
+// When the connection is lost, a single background loop tries to reopen it with an exponential backoff. Meanwhile, the
+// circuit breaker is open and calls fail fast with ErrCircuitOpen
+// Calls hold a read lock on the connection while they use it: the connection is only closed or replaced once no other
+// goroutine is using it
+// Once closed, the database is never reopened: every call fails with sql.ErrConnDone
 type ReconnectableCloudtrustDB struct {
 	dbConnFactory sqltypes.CloudtrustDBFactory
 	connection    sqltypes.CloudtrustDB
-	mutex         *sync.Mutex
+	mutex         *sync.RWMutex
 	logger        log.Logger
+	backoff       BackoffPolicy
+	circuit       atomic.Int32
+	checking      atomic.Bool
+	stopReconnect chan struct{}
+	reconnections atomic.Int64
+	closed        atomic.Bool
 }
 
 // NewReconnectableCloudtrustDB opens a connection to a database. This connection will be renewed if necessary
 func NewReconnectableCloudtrustDB(dbConnFactory sqltypes.CloudtrustDBFactory, logger log.Logger) (sqltypes.CloudtrustDB, error) {
+	return NewReconnectableCloudtrustDBExt(dbConnFactory, logger, DefaultReconnectBackoff())
+}
+
+// NewReconnectableCloudtrustDBExt is an extension of NewReconnectableCloudtrustDB used to specify the backoff policy
+// applied between reconnection attempts
+func NewReconnectableCloudtrustDBExt(dbConnFactory sqltypes.CloudtrustDBFactory, logger log.Logger, backoff BackoffPolicy) (sqltypes.CloudtrustDB, error) {
 	dbConn, err := dbConnFactory.OpenDatabase()
 	if err != nil {
 		return nil, err
@@ -272,76 +478,184 @@ func NewReconnectableCloudtrustDB(dbConnFactory sqltypes.CloudtrustDBFactory, lo
 	return &ReconnectableCloudtrustDB{
 		dbConnFactory: dbConnFactory,
 		connection:    dbConn,
-		mutex:         &sync.Mutex{},
+		mutex:         &sync.RWMutex{},
 		logger:        logger,
+		backoff:       backoff,
 	}, nil
 }
 
-func (rcdb *ReconnectableCloudtrustDB) getActiveConnection() (sqltypes.CloudtrustDB, error) {
-	rcdb.logger.Debug(context.TODO(), "msg", "'getActiveConnection() called'")
-	var err error
-	if rcdb.connection == nil {
+// CircuitState returns the current state of the circuit breaker: CircuitClosed, CircuitOpen or CircuitHalfOpen
+func (rcdb *ReconnectableCloudtrustDB) CircuitState() string {
+	return circuitStates[rcdb.circuit.Load()]
+}
+
+// Reconnections returns the number of times the connection has been restored since the creation of the database
+func (rcdb *ReconnectableCloudtrustDB) Reconnections() int64 {
+	return rcdb.reconnections.Load()
+}
+
+func (rcdb *ReconnectableCloudtrustDB) setCircuitState(state int32) {
+	rcdb.circuit.Store(state)
+}
+
+// acquireConnection returns the active connection, opening it if needed. On success, the read lock is held and must be
+// released with releaseConnection once the connection is not used anymore
+func (rcdb *ReconnectableCloudtrustDB) acquireConnection() (sqltypes.CloudtrustDB, error) {
+	rcdb.logger.Debug(context.TODO(), "msg", "'acquireConnection() called'")
+	for {
+		if rcdb.closed.Load() {
+			return nil, sql.ErrConnDone
+		}
+		if rcdb.circuit.Load() != circuitClosed {
+			return nil, ErrCircuitOpen
+		}
+
+		rcdb.mutex.RLock()
+		if rcdb.connection != nil {
+			return rcdb.connection, nil
+		}
+		rcdb.mutex.RUnlock()
+
+		var err error
 		rcdb.mutex.Lock()
-		// Ensure connection has not already been reopened by another thread
-		if rcdb.connection == nil {
+		// Ensure connection has not already been reopened by another thread nor closed
+		if rcdb.connection == nil && rcdb.circuit.Load() == circuitClosed && !rcdb.closed.Load() {
 			rcdb.logger.Debug(context.TODO(), "msg", "OpenDatabase() triggered")
 			rcdb.connection, err = rcdb.dbConnFactory.OpenDatabase()
 		}
 		rcdb.mutex.Unlock()
+
+		if err != nil {
+			rcdb.tripCircuit()
+			return nil, err
+		}
 	}
+}
+
+func (rcdb *ReconnectableCloudtrustDB) releaseConnection() {
+	rcdb.mutex.RUnlock()
+}
+
+// withConnection executes the given function with the active connection then checks the returned error
+func (rcdb *ReconnectableCloudtrustDB) withConnection(fn func(dbConn sqltypes.CloudtrustDB) error) error {
+	dbConn, err := rcdb.acquireConnection()
+	if err != nil {
+		return err
+	}
+	err = fn(dbConn)
+	rcdb.releaseConnection()
 
-	return rcdb.connection, err
+	rcdb.checkError(dbConn, err)
+	return err
 }
 
-func (rcdb *ReconnectableCloudtrustDB) resetConnection(reconnect bool) error {
+// resetConnection closes the given connection if it is still the active one
+func (rcdb *ReconnectableCloudtrustDB) resetConnection(dbConn sqltypes.CloudtrustDB, reconnect bool) error {
 	rcdb.logger.Debug(context.TODO(), "msg", "'resetConnection() called'")
 	var err error
 
-	if rcdb.connection != nil {
-		rcdb.mutex.Lock()
-		if rcdb.connection != nil {
-			rcdb.logger.Debug(context.TODO(), "msg", "Close() triggered")
-			err = rcdb.connection.Close()
-			rcdb.connection = nil
-			if reconnect {
-				// Reconnect later
-				defer rcdb.asyncReconnect()
-			}
-		}
+	rcdb.mutex.Lock()
+	// The connection may already have been reset (and even reopened) by another thread
+	if rcdb.connection == nil || rcdb.connection != dbConn {
 		rcdb.mutex.Unlock()
+		return nil
+	}
+	rcdb.logger.Debug(context.TODO(), "msg", "Close() triggered")
+	err = rcdb.connection.Close()
+	rcdb.connection = nil
+	rcdb.mutex.Unlock()
+
+	if reconnect {
+		// Reconnect later
+		rcdb.tripCircuit()
 	}
-
 	return err
 }
 
-func (rcdb *ReconnectableCloudtrustDB) asyncReconnect() {
-	rcdb.logger.Debug(context.TODO(), "msg", "'asyncReconnect() called'")
-	// Try to reconnect in asynchronously
-	go rcdb.getActiveConnection()
+// tripCircuit opens the circuit breaker and starts the reconnection loop if it is not already running
+func (rcdb *ReconnectableCloudtrustDB) tripCircuit() {
+	rcdb.mutex.Lock()
+	defer rcdb.mutex.Unlock()
+
+	if rcdb.closed.Load() {
+		return
+	}
+	rcdb.setCircuitState(circuitOpen)
+	if rcdb.stopReconnect == nil {
+		rcdb.logger.Warn(context.TODO(), "msg", "Database connection lost. Circuit breaker is open")
+		rcdb.stopReconnect = make(chan struct{})
+		go rcdb.reconnectLoop(rcdb.stopReconnect)
+	}
 }
 
-func (rcdb *ReconnectableCloudtrustDB) checkError(err error) {
-	connection := rcdb.connection
-	if err != nil && connection != nil {
-		switch err {
-		case sql.ErrNoRows:
+func (rcdb *ReconnectableCloudtrustDB) reconnectLoop(stop <-chan struct{}) {
+	for attempt := 0; ; attempt++ {
+		var timer = time.NewTimer(rcdb.backoff.Interval(attempt))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		rcdb.setCircuitState(circuitHalfOpen)
+		rcdb.logger.Debug(context.TODO(), "msg", "OpenDatabase() triggered", "attempt", attempt+1)
+		dbConn, err := rcdb.dbConnFactory.OpenDatabase()
+
+		rcdb.mutex.Lock()
+		select {
+		case <-stop:
+			// Close() has been called during the reconnection attempt
+			rcdb.mutex.Unlock()
+			if err == nil {
+				_ = dbConn.Close()
+			}
 			return
+		default:
 		}
-		if connection.Ping() != nil {
-			_ = rcdb.resetConnection(true)
+		if err == nil {
+			rcdb.connection = dbConn
+			rcdb.stopReconnect = nil
+			rcdb.reconnections.Add(1)
+			rcdb.setCircuitState(circuitClosed)
+			rcdb.mutex.Unlock()
+			rcdb.logger.Info(context.TODO(), "msg", "Database connection restored. Circuit breaker is closed", "attempts", attempt+1)
+			return
 		}
+		rcdb.setCircuitState(circuitOpen)
+		rcdb.mutex.Unlock()
+		rcdb.logger.Warn(context.TODO(), "msg", "Can't reconnect to database", "attempt", attempt+1, "err", err.Error())
+	}
+}
+
+// checkError checks if the connection used by a failed call is still valid. The connection is reset if it is not
+func (rcdb *ReconnectableCloudtrustDB) checkError(dbConn sqltypes.CloudtrustDB, err error) {
+	if err == nil {
+		return
+	}
+	switch err {
+	case sql.ErrNoRows, context.Canceled, context.DeadlineExceeded:
+		return
+	}
+	// Only one goroutine checks the connection at a time: concurrent failures do not ping the database again
+	if !rcdb.checking.CompareAndSwap(false, true) {
+		return
+	}
+	defer rcdb.checking.Store(false)
+	if dbConn.Ping() != nil {
+		_ = rcdb.resetConnection(dbConn, true)
 	}
 }
 
 // BeginTx creates a transaction
 func (rcdb *ReconnectableCloudtrustDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (sqltypes.Transaction, error) {
-	rcdb.logger.Debug(context.TODO(), "msg", "'BeginTx() called'")
-	dbConn, err := rcdb.getActiveConnection()
-	if err != nil {
-		return nil, err
-	}
+	rcdb.logger.Debug(ctx, "msg", "'BeginTx() called'")
 	var tx sqltypes.Transaction
-	tx, err = dbConn.BeginTx(ctx, opts)
+	var err = rcdb.withConnection(func(dbConn sqltypes.CloudtrustDB) error {
+		var err error
+		tx, err = dbConn.BeginTx(ctx, opts)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -351,70 +665,134 @@ func (rcdb *ReconnectableCloudtrustDB) BeginTx(ctx context.Context, opts *sql.Tx
 // Exec an SQL query
 func (rcdb *ReconnectableCloudtrustDB) Exec(query string, args ...any) (sql.Result, error) {
 	rcdb.logger.Debug(context.TODO(), "msg", "'Exec() called'")
-	dbConn, err := rcdb.getActiveConnection()
-	if err != nil {
-		return nil, err
-	}
-
 	var res sql.Result
-	res, err = dbConn.Exec(query, args...)
-	rcdb.checkError(err)
-
+	var err = rcdb.withConnection(func(dbConn sqltypes.CloudtrustDB) error {
+		var err error
+		res, err = dbConn.Exec(query, args...)
+		return err
+	})
 	return res, err
 }
 
 // Query a multiple-rows SQL result
 func (rcdb *ReconnectableCloudtrustDB) Query(query string, args ...any) (sqltypes.SQLRows, error) {
 	rcdb.logger.Debug(context.TODO(), "msg", "'Query() called'")
-	dbConn, err := rcdb.getActiveConnection()
-	if err != nil {
-		return nil, err
-	}
-
 	var res sqltypes.SQLRows
-	res, err = dbConn.Query(query, args...)
-	rcdb.checkError(err)
-
+	var err = rcdb.withConnection(func(dbConn sqltypes.CloudtrustDB) error {
+		var err error
+		res, err = dbConn.Query(query, args...)
+		return err
+	})
 	return res, err
 }
 
 // QueryRow a single-row SQL result
 func (rcdb *ReconnectableCloudtrustDB) QueryRow(query string, args ...any) sqltypes.SQLRow {
 	rcdb.logger.Debug(context.TODO(), "msg", "'QueryRow() called'")
-	dbConn, err := rcdb.getActiveConnection()
+	dbConn, err := rcdb.acquireConnection()
 	if err != nil {
 		return sqltypes.NewSQLRowError(err)
 	}
+	defer rcdb.releaseConnection()
 	return dbConn.QueryRow(query, args...)
 }
 
+// ExecContext executes an SQL query using the given context
+func (rcdb *ReconnectableCloudtrustDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	rcdb.logger.Debug(ctx, "msg", "'ExecContext() called'")
+	var res sql.Result
+	var err = rcdb.withConnection(func(dbConn sqltypes.CloudtrustDB) error {
+		var err error
+		res, err = dbConn.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+// QueryContext queries a multiple-rows SQL result using the given context
+func (rcdb *ReconnectableCloudtrustDB) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	rcdb.logger.Debug(ctx, "msg", "'QueryContext() called'")
+	var res sqltypes.SQLRows
+	var err = rcdb.withConnection(func(dbConn sqltypes.CloudtrustDB) error {
+		var err error
+		res, err = dbConn.QueryContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+// QueryRowContext queries a single-row SQL result using the given context
+func (rcdb *ReconnectableCloudtrustDB) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	rcdb.logger.Debug(ctx, "msg", "'QueryRowContext() called'")
+	dbConn, err := rcdb.acquireConnection()
+	if err != nil {
+		return sqltypes.NewSQLRowError(err)
+	}
+	defer rcdb.releaseConnection()
+	return dbConn.QueryRowContext(ctx, query, args...)
+}
+
 // Ping check the connection with the database
 func (rcdb *ReconnectableCloudtrustDB) Ping() error {
 	rcdb.logger.Debug(context.TODO(), "msg", "'Ping() called'")
-	dbConn, err := rcdb.getActiveConnection()
+	dbConn, err := rcdb.acquireConnection()
 	if err != nil {
 		return err
 	}
 	err = dbConn.Ping()
+	rcdb.releaseConnection()
+	if err != nil {
+		_ = rcdb.resetConnection(dbConn, true)
+	}
+	return err
+}
+
+// PingContext check the connection with the database using the given context
+func (rcdb *ReconnectableCloudtrustDB) PingContext(ctx context.Context) error {
+	rcdb.logger.Debug(ctx, "msg", "'PingContext() called'")
+	dbConn, err := rcdb.acquireConnection()
 	if err != nil {
-		_ = rcdb.resetConnection(true)
+		return err
+	}
+	err = dbConn.PingContext(ctx)
+	rcdb.releaseConnection()
+	if err != nil && ctx.Err() == nil {
+		_ = rcdb.resetConnection(dbConn, true)
 	}
 	return err
 }
 
-// Close the connection with the database
+// Close the connection with the database. A running reconnection loop is stopped and the database can't be used anymore
 func (rcdb *ReconnectableCloudtrustDB) Close() error {
 	rcdb.logger.Debug(context.TODO(), "msg", "'Close() called'")
-	return rcdb.resetConnection(false)
+	rcdb.mutex.Lock()
+	defer rcdb.mutex.Unlock()
+
+	rcdb.closed.Store(true)
+
+	if rcdb.stopReconnect != nil {
+		close(rcdb.stopReconnect)
+		rcdb.stopReconnect = nil
+	}
+	rcdb.setCircuitState(circuitClosed)
+
+	var err error
+	if rcdb.connection != nil {
+		err = rcdb.connection.Close()
+		rcdb.connection = nil
+	}
+	return err
 }
 
-// Stats returns database statistics
+// Stats returns the statistics of the current connection. The connection is not opened if there is none: statistics
+// are then empty
 func (rcdb *ReconnectableCloudtrustDB) Stats() sql.DBStats {
 	rcdb.logger.Debug(context.TODO(), "msg", "'Stats() called'")
-	dbConn, err := rcdb.getActiveConnection()
-	if err != nil {
+	rcdb.mutex.RLock()
+	defer rcdb.mutex.RUnlock()
+
+	if rcdb.connection == nil {
 		return sql.DBStats{}
 	}
-
-	return dbConn.Stats()
+	return rcdb.connection.Stats()
 }
@@ -1,12 +1,19 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	cs "github.com/cloudtrust/common-service/v2"
 	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/healthcheck"
 	"github.com/cloudtrust/common-service/v2/log"
 
 	"github.com/cloudtrust/common-service/v2/database/mock"
@@ -14,25 +21,6 @@ import (
 	"go.uber.org/mock/gomock"
 )
 
-func TestDbVersion(t *testing.T) {
-	var err error
-
-	for _, invalidVersion := range []string{"", "1.0.1", "A.b"} {
-		_, err = newDbVersion(invalidVersion)
-		assert.NotNil(t, err)
-	}
-
-	var v1, v2 *dbVersion
-	v1, err = newDbVersion("1.1")
-	assert.Nil(t, err)
-
-	var matchTests = map[string]bool{"0.9": false, "1.0": false, "1.1": true, "1.5": true, "2.0": true}
-	for k, v := range matchTests {
-		v2, _ = newDbVersion(k)
-		assert.Equal(t, v, v2.matchesRequired(v1))
-	}
-}
-
 func TestGetDbConnectionString(t *testing.T) {
 	var conf = DbConfig{
 		Username: "user",
@@ -41,10 +29,14 @@ func TestGetDbConnectionString(t *testing.T) {
 		HostPort: "1234",
 		Database: "db",
 	}
-	assert.Equal(t, "user:pass@proto(1234)/db", conf.getDbConnectionString())
+	var dsn, err = conf.getDbConnectionString()
+	assert.Nil(t, err)
+	assert.Equal(t, "user:pass@proto(1234)/db", dsn)
 
 	conf.Parameters = "params"
-	assert.Equal(t, "user:pass@proto(1234)/db?params", conf.getDbConnectionString())
+	dsn, err = conf.getDbConnectionString()
+	assert.Nil(t, err)
+	assert.Equal(t, "user:pass@proto(1234)/db?params", dsn)
 }
 
 func TestConfigureDbDefaultForKey(t *testing.T) {
@@ -57,7 +49,7 @@ func TestConfigureDbDefaultForKey(t *testing.T) {
 	var envPass = "the env password"
 
 	mockConf.EXPECT().SetDefault(prefix+".enabled", gomock.Any()).Times(1)
-	for _, suffix := range []string{".host-port", ".username", ".password", ".database", ".protocol", ".parameters", ".max-open-conns", ".max-idle-conns", ".conn-max-lifetime", ".migration", ".migration-version", ".connection-check", ".ping-timeout-ms"} {
+	for _, suffix := range []string{".host-port", ".username", ".password", ".database", ".protocol", ".parameters", ".max-open-conns", ".max-idle-conns", ".conn-max-lifetime", ".migration", ".migration-version", ".migration-dry-run", ".connection-check", ".ping-timeout-ms", ".replica-host-ports", ".password-file", ".tls-ca-file", ".tls-cert-file", ".tls-key-file"} {
 		mockConf.EXPECT().SetDefault(prefix+suffix, gomock.Any()).Times(1)
 	}
 	mockConf.EXPECT().BindEnv(prefix+".username", envUser).Times(1)
@@ -76,7 +68,7 @@ func TestConfigureDbDefault(t *testing.T) {
 	var envPass = "the env password"
 
 	mockConf.EXPECT().SetDefault(prefix+"-enabled", gomock.Any()).Times(1)
-	for _, suffix := range []string{"-host-port", "-username", "-password", "-database", "-protocol", "-parameters", "-max-open-conns", "-max-idle-conns", "-conn-max-lifetime", "-migration", "-migration-version", "-connection-check", "-ping-timeout-ms"} {
+	for _, suffix := range []string{"-host-port", "-username", "-password", "-database", "-protocol", "-parameters", "-max-open-conns", "-max-idle-conns", "-conn-max-lifetime", "-migration", "-migration-version", "-migration-dry-run", "-connection-check", "-ping-timeout-ms", "-replica-host-ports", "-password-file", "-tls-ca-file", "-tls-cert-file", "-tls-key-file"} {
 		mockConf.EXPECT().SetDefault(prefix+suffix, gomock.Any()).Times(1)
 	}
 	mockConf.EXPECT().BindEnv(prefix+"-username", envUser).Times(1)
@@ -92,7 +84,7 @@ func TestGetDbConfig(t *testing.T) {
 
 	var prefix = "mydb"
 
-	for _, suffix := range []string{"-host-port", "-username", "-password", "-database", "-protocol", "-parameters"} {
+	for _, suffix := range []string{"-host-port", "-username", "-password", "-database", "-protocol", "-parameters", "-password-file", "-tls-ca-file", "-tls-cert-file", "-tls-key-file"} {
 		mockConf.EXPECT().GetString(prefix + suffix).Return("value" + suffix).Times(1)
 	}
 	for _, suffix := range []string{"-max-open-conns", "-max-idle-conns", "-conn-max-lifetime", "-ping-timeout-ms"} {
@@ -100,55 +92,131 @@ func TestGetDbConfig(t *testing.T) {
 	}
 	mockConf.EXPECT().GetBool(prefix + "-enabled").Return(true).Times(1)
 	mockConf.EXPECT().GetBool(prefix + "-migration").Return(false).Times(1)
+	mockConf.EXPECT().GetBool(prefix + "-migration-dry-run").Return(false).Times(1)
 	mockConf.EXPECT().GetBool(prefix + "-connection-check").Return(true).Times(1)
 	mockConf.EXPECT().GetString(prefix + "-migration-version").Return("1.0").Times(1)
+	mockConf.EXPECT().GetStringSlice(prefix + "-replica-host-ports").Return([]string{"replica1:3306", "replica2:3306"}).Times(1)
 
 	var cfg = GetDbConfig(mockConf, prefix)
 	assert.Equal(t, "value-host-port", cfg.HostPort)
+	assert.Equal(t, []string{"replica1:3306", "replica2:3306"}, cfg.ReplicaHostPorts)
+	assert.Equal(t, "value-password-file", cfg.PasswordFile)
+	assert.Equal(t, "value-tls-ca-file", cfg.TLSCAFile)
 }
 
+var testBackoff = BackoffPolicy{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, Multiplier: 1}
+
 func TestCheckMigrationVersion(t *testing.T) {
 	var mockCtrl = gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	var mockDB = mock.NewCloudtrustDB(mockCtrl)
-	var row = mock.NewSQLRow(mockCtrl)
+	var rows = mock.NewSQLRows(mockCtrl)
 	var dbConf = DbConfig{MigrationVersion: "1.5"}
 
-	{
-		// Can't check version: SQL query error
-		var expectedError = errors.New("SQL query failed")
-		var errRow = sqltypes.NewSQLRowError(expectedError)
-		mockDB.EXPECT().QueryRow(gomock.Any()).Return(errRow)
-		assert.Equal(t, expectedError, dbConf.checkMigrationVersion(mockDB))
+	var mockVersions = func(versions ...string) {
+		mockDB.EXPECT().Query(selectFlywayVersionsStmt).Return(rows, nil)
+		for _, version := range versions {
+			var value = version
+			rows.EXPECT().Next().Return(true)
+			rows.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+				*(dest[0].(*string)) = value
+				return nil
+			})
+		}
+		rows.EXPECT().Next().Return(false)
+		rows.EXPECT().Err().Return(nil)
+		rows.EXPECT().Close().Return(nil)
 	}
 
-	{
-		// Current version is higher than the minimum version requirement
-		var version = "1.6"
-		mockDB.EXPECT().QueryRow(gomock.Any()).Return(row)
-		row.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
-			var ptrVersion = dest[0].(*string)
-			*ptrVersion = version
+	t.Run("Invalid required version", func(t *testing.T) {
+		var dbConf = DbConfig{MigrationVersion: "A.b"}
+		assert.NotNil(t, dbConf.checkMigrationVersion(mockDB))
+	})
+	t.Run("Can't check version: SQL query error", func(t *testing.T) {
+		var expectedError = errors.New("SQL query failed")
+		mockDB.EXPECT().Query(selectFlywayVersionsStmt).Return(nil, expectedError)
+		assert.Equal(t, expectedError, dbConf.checkMigrationVersion(mockDB))
+	})
+	t.Run("Invalid version in flyway_schema_history", func(t *testing.T) {
+		mockDB.EXPECT().Query(selectFlywayVersionsStmt).Return(rows, nil)
+		rows.EXPECT().Next().Return(true)
+		rows.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+			*(dest[0].(*string)) = "A.b"
 			return nil
 		})
+		rows.EXPECT().Close().Return(nil)
+		assert.NotNil(t, dbConf.checkMigrationVersion(mockDB))
+	})
+	t.Run("Current version is higher than the minimum version requirement", func(t *testing.T) {
+		mockVersions("1.0", "1.6", "1.2")
+		assert.Nil(t, dbConf.checkMigrationVersion(mockDB))
+	})
+	t.Run("Multi-segment versions", func(t *testing.T) {
+		var dbConf = DbConfig{MigrationVersion: "2.14.1"}
+		mockVersions("1", "2.14", "2_14_1")
 		assert.Nil(t, dbConf.checkMigrationVersion(mockDB))
-	}
 
-	{
-		// Current version is higher than the minimum version requirement
-		var row = mock.NewSQLRow(mockCtrl)
-		var version = "1.3"
-		mockDB.EXPECT().QueryRow(gomock.Any()).Return(row)
-		row.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
-			var ptrVersion = dest[0].(*string)
-			*ptrVersion = version
-			return nil
-		})
+		mockVersions("3")
+		assert.Nil(t, dbConf.checkMigrationVersion(mockDB))
+
+		mockVersions("2.14", "2.9.9")
+		assert.NotNil(t, dbConf.checkMigrationVersion(mockDB))
+	})
+	t.Run("Current version is lower than the minimum version requirement", func(t *testing.T) {
+		mockVersions("1.3")
 		var err = dbConf.checkMigrationVersion(mockDB)
 		assert.NotNil(t, err)
 		assert.True(t, strings.Contains(err.Error(), "not up-to-date"))
-	}
+		assert.Contains(t, err.Error(), "(current: 1.3, required: 1.5, pending migrations: versions after version 1.3 up to 1.5, scripts not provided)")
+	})
+	t.Run("Empty flyway_schema_history", func(t *testing.T) {
+		mockVersions()
+		var err = dbConf.checkMigrationVersion(mockDB)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "current: none")
+		assert.Contains(t, err.Error(), "versions after the first version up to 1.5")
+	})
+	t.Run("Pending migrations are reported", func(t *testing.T) {
+		var dbConf = DbConfig{MigrationVersion: "1.5", Migrations: fstest.MapFS{
+			"V1_3__a.sql": {}, "V1_4__b.sql": {}, "V1_5__c.sql": {}, "V1_6__d.sql": {},
+		}}
+		mockVersions("1.3")
+		var err = dbConf.checkMigrationVersion(mockDB)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "pending migrations: V1_4__b.sql, V1_5__c.sql)")
+	})
+	t.Run("No migration script available", func(t *testing.T) {
+		var dbConf = DbConfig{MigrationVersion: "1.5", Migrations: fstest.MapFS{"V1_3__a.sql": {}}}
+		mockVersions("1.3")
+		var err = dbConf.checkMigrationVersion(mockDB)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "no migration script available")
+	})
+	t.Run("Migration scripts can't be loaded", func(t *testing.T) {
+		var dbConf = DbConfig{MigrationVersion: "1.5", Migrations: fstest.MapFS{"V1__a.sql": {}, "V1_0__b.sql": {}}}
+		mockVersions("1.3")
+		var err = dbConf.checkMigrationVersion(mockDB)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "not up-to-date (current: 1.3, required: 1.5, pending migrations unknown)")
+		assert.Contains(t, err.Error(), "can't load migration scripts")
+	})
+}
+
+func TestMigrateDatabase(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockDB = mock.NewCloudtrustDB(mockCtrl)
+
+	t.Run("No migration scripts", func(t *testing.T) {
+		var dbConf = DbConfig{MigrationEnabled: true, MigrationVersion: "1.0"}
+		assert.Nil(t, dbConf.migrateDatabase(mockDB))
+	})
+	t.Run("Invalid migration scripts", func(t *testing.T) {
+		var dbConf = DbConfig{MigrationEnabled: true, Migrations: fstest.MapFS{"V1__a.sql": {}, "V1_0__b.sql": {}}}
+		assert.NotNil(t, dbConf.migrateDatabase(mockDB))
+	})
 }
 
 func TestReconnectableCloudtrustDB(t *testing.T) {
@@ -168,8 +236,13 @@ func TestReconnectableCloudtrustDB(t *testing.T) {
 	})
 
 	mockDBFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
-	db, err := NewReconnectableCloudtrustDB(mockDBFactory, noopLogger)
+	db, err := NewReconnectableCloudtrustDBExt(mockDBFactory, noopLogger, testBackoff)
 	assert.Nil(t, err)
+	var waitReconnection = func() {
+		assert.Eventually(t, func() bool {
+			return db.(*ReconnectableCloudtrustDB).CircuitState() == CircuitClosed
+		}, time.Second, time.Millisecond)
+	}
 
 	t.Run("Exec success", func(t *testing.T) {
 		mockDB.EXPECT().Exec(gomock.Any()).Return(nil, nil)
@@ -189,6 +262,7 @@ func TestReconnectableCloudtrustDB(t *testing.T) {
 		mockDBFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
 		_, err := db.Exec("request")
 		assert.NotNil(t, err)
+		waitReconnection()
 	})
 
 	t.Run("Query success", func(t *testing.T) {
@@ -209,6 +283,7 @@ func TestReconnectableCloudtrustDB(t *testing.T) {
 		mockDBFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
 		_, err := db.Query("request")
 		assert.NotNil(t, err)
+		waitReconnection()
 	})
 
 	t.Run("QueryRow success", func(t *testing.T) {
@@ -218,6 +293,41 @@ func TestReconnectableCloudtrustDB(t *testing.T) {
 		assert.Equal(t, sqlRow, row)
 	})
 
+	t.Run("ExecContext success", func(t *testing.T) {
+		var ctx = context.WithValue(context.TODO(), cs.CtContextCorrelationID, "corr-id")
+		mockDB.EXPECT().ExecContext(ctx, "request").Return(nil, nil)
+		_, err := db.ExecContext(ctx, "request")
+		assert.Nil(t, err)
+	})
+	t.Run("ExecContext failure... Ping still ok", func(t *testing.T) {
+		mockDB.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(nil, expectedError)
+		mockDB.EXPECT().Ping().Return(nil)
+		_, err := db.ExecContext(context.TODO(), "request")
+		assert.NotNil(t, err)
+	})
+	t.Run("QueryContext success", func(t *testing.T) {
+		var ctx = context.WithValue(context.TODO(), cs.CtContextCorrelationID, "corr-id")
+		mockDB.EXPECT().QueryContext(ctx, "request").Return(nil, nil)
+		_, err := db.QueryContext(ctx, "request")
+		assert.Nil(t, err)
+	})
+	t.Run("QueryContext failure... Ping fails too...", func(t *testing.T) {
+		mockDB.EXPECT().QueryContext(gomock.Any(), gomock.Any()).Return(nil, expectedError)
+		mockDB.EXPECT().Ping().Return(expectedError)
+		mockDB.EXPECT().Close()
+		mockDBFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
+		_, err := db.QueryContext(context.TODO(), "request")
+		assert.NotNil(t, err)
+		waitReconnection()
+	})
+	t.Run("QueryRowContext success", func(t *testing.T) {
+		var ctx = context.WithValue(context.TODO(), cs.CtContextCorrelationID, "corr-id")
+		var sqlRow = sqltypes.NewSQLRowError(errors.New(""))
+		mockDB.EXPECT().QueryRowContext(ctx, "request").Return(sqlRow)
+		row := db.QueryRowContext(ctx, "request")
+		assert.Equal(t, sqlRow, row)
+	})
+
 	t.Run("Ping success", func(t *testing.T) {
 		mockDB.EXPECT().Ping().Return(nil)
 		assert.Nil(t, db.Ping())
@@ -227,12 +337,220 @@ func TestReconnectableCloudtrustDB(t *testing.T) {
 		mockDB.EXPECT().Close()
 		mockDBFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
 		assert.NotNil(t, db.Ping())
+		waitReconnection()
+	})
+	t.Run("PingContext success", func(t *testing.T) {
+		mockDB.EXPECT().PingContext(gomock.Any()).Return(nil)
+		assert.Nil(t, db.PingContext(context.TODO()))
 	})
+	t.Run("PingContext cancelled by caller", func(t *testing.T) {
+		var ctx, cancel = context.WithCancel(context.TODO())
+		cancel()
+		mockDB.EXPECT().PingContext(ctx).Return(context.Canceled)
+		assert.NotNil(t, db.PingContext(ctx))
+	})
+	t.Run("PingContext failure", func(t *testing.T) {
+		mockDB.EXPECT().PingContext(gomock.Any()).Return(expectedError)
+		mockDB.EXPECT().Close()
+		mockDBFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
+		assert.NotNil(t, db.PingContext(context.TODO()))
+		waitReconnection()
+	})
+
+	t.Run("Circuit open: calls fail fast until reconnection succeeds", func(t *testing.T) {
+		var reconnected = make(chan struct{})
+		mockDB.EXPECT().Exec(gomock.Any()).Return(nil, expectedError)
+		mockDB.EXPECT().Ping().Return(expectedError)
+		mockDB.EXPECT().Close()
+		gomock.InOrder(
+			mockDBFactory.EXPECT().OpenDatabase().Return(nil, expectedError).Times(2),
+			mockDBFactory.EXPECT().OpenDatabase().DoAndReturn(func() (sqltypes.CloudtrustDB, error) {
+				<-reconnected
+				return mockDB, nil
+			}),
+		)
+		_, err := db.Exec("request")
+		assert.Equal(t, expectedError, err)
+
+		_, err = db.Query("request")
+		assert.Equal(t, ErrCircuitOpen, err)
+		assert.Equal(t, ErrCircuitOpen, db.Ping())
+		assert.Equal(t, ErrCircuitOpen, db.QueryRow("request").Scan())
+		assert.NotEqual(t, CircuitClosed, db.(*ReconnectableCloudtrustDB).CircuitState())
+
+		close(reconnected)
+		waitReconnection()
+	})
+	t.Run("Context cancelled by caller does not ping the database", func(t *testing.T) {
+		mockDB.EXPECT().QueryContext(gomock.Any(), gomock.Any()).Return(nil, context.Canceled)
+		_, err := db.QueryContext(context.TODO(), "request")
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("Close stops reconnection", func(t *testing.T) {
+		mockDB.EXPECT().Ping().Return(expectedError)
+		mockDB.EXPECT().Close()
+		mockDBFactory.EXPECT().OpenDatabase().Return(nil, expectedError).AnyTimes()
+		assert.NotNil(t, db.Ping())
 
-	{
 		db.Close()
+		assert.Equal(t, CircuitClosed, db.(*ReconnectableCloudtrustDB).CircuitState())
+	})
+}
+
+func TestReconnectableCloudtrustDBCircuitStates(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockDB = mock.NewCloudtrustDB(mockCtrl)
+	var mockDBFactory = mock.NewCloudtrustDBFactory(mockCtrl)
+	var expectedError = errors.New("error")
+
+	mockDBFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
+	db, _ := NewReconnectableCloudtrustDB(mockDBFactory, log.NewNopLogger())
+	var rcdb = db.(*ReconnectableCloudtrustDB)
+	assert.Equal(t, CircuitClosed, rcdb.CircuitState())
+	assert.Implements(t, (*healthcheck.CircuitBreakerDatabase)(nil), rcdb)
+
+	// Slow down reconnection to observe the intermediate states
+	rcdb.backoff = BackoffPolicy{InitialInterval: time.Hour}
+	mockDB.EXPECT().Ping().Return(expectedError)
+	mockDB.EXPECT().Close()
+	assert.NotNil(t, db.Ping())
+	assert.Equal(t, CircuitOpen, rcdb.CircuitState())
+
+	rcdb.setCircuitState(circuitHalfOpen)
+	assert.Equal(t, CircuitHalfOpen, rcdb.CircuitState())
+
+	db.Close()
+	assert.Equal(t, CircuitClosed, rcdb.CircuitState())
+}
+
+func TestReconnectableCloudtrustDBClosed(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockDB = mock.NewCloudtrustDB(mockCtrl)
+	var mockDBFactory = mock.NewCloudtrustDBFactory(mockCtrl)
+	var ctx = context.TODO()
+
+	mockDBFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
+	db, _ := NewReconnectableCloudtrustDBExt(mockDBFactory, log.NewNopLogger(), testBackoff)
+
+	t.Run("Stats of the current connection", func(t *testing.T) {
+		mockDB.EXPECT().Stats().Return(sql.DBStats{OpenConnections: 3})
+		assert.Equal(t, 3, db.Stats().OpenConnections)
+	})
+
+	mockDB.EXPECT().Close()
+	assert.Nil(t, db.Close())
+
+	// OpenDatabase is not expected anymore: the database must not be reopened
+	t.Run("Calls fail once closed", func(t *testing.T) {
+		assert.Equal(t, sql.ErrConnDone, db.Ping())
+		_, err := db.Exec("UPDATE t SET c=1")
+		assert.Equal(t, sql.ErrConnDone, err)
+		_, err = db.QueryContext(ctx, "SELECT 1")
+		assert.Equal(t, sql.ErrConnDone, err)
+		_, err = db.BeginTx(ctx, nil)
+		assert.Equal(t, sql.ErrConnDone, err)
+	})
+	t.Run("Stats don't reopen the database", func(t *testing.T) {
+		assert.Equal(t, sql.DBStats{}, db.Stats())
+	})
+}
+
+// stressDB is a connection which fails every failEvery calls and reports calls made after it has been closed
+type stressDB struct {
+	sqltypes.CloudtrustDB
+	calls          atomic.Int32
+	failEvery      int32
+	closed         atomic.Bool
+	usedAfterClose *atomic.Int32
+}
+
+func (db *stressDB) use() error {
+	if db.closed.Load() {
+		db.usedAfterClose.Add(1)
+	}
+	if db.calls.Add(1)%db.failEvery == 0 {
+		return errors.New("connection lost")
 	}
+	return nil
+}
+
+func (db *stressDB) Exec(query string, args ...any) (sql.Result, error) {
+	return nil, db.use()
+}
 
-	// Wait for asynchronous reconnections
-	time.Sleep(time.Second / 10)
+func (db *stressDB) Query(query string, args ...any) (sqltypes.SQLRows, error) {
+	return nil, db.use()
+}
+
+func (db *stressDB) QueryRow(query string, args ...any) sqltypes.SQLRow {
+	return sqltypes.NewSQLRowError(db.use())
+}
+
+func (db *stressDB) Ping() error {
+	return db.use()
+}
+
+func (db *stressDB) Stats() sql.DBStats {
+	_ = db.use()
+	return sql.DBStats{}
+}
+
+func (db *stressDB) Close() error {
+	db.closed.Store(true)
+	return nil
+}
+
+func TestReconnectableCloudtrustDBConcurrency(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockDBFactory = mock.NewCloudtrustDBFactory(mockCtrl)
+	var usedAfterClose atomic.Int32
+	var opened atomic.Int32
+	mockDBFactory.EXPECT().OpenDatabase().DoAndReturn(func() (sqltypes.CloudtrustDB, error) {
+		opened.Add(1)
+		return &stressDB{failEvery: 7, usedAfterClose: &usedAfterClose}, nil
+	}).AnyTimes()
+
+	db, err := NewReconnectableCloudtrustDBExt(mockDBFactory, log.NewNopLogger(), testBackoff)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				switch (i + j) % 5 {
+				case 0:
+					_, _ = db.Exec("request")
+				case 1:
+					_, _ = db.Query("request")
+				case 2:
+					_ = db.QueryRow("request").Scan()
+				case 3:
+					_ = db.Ping()
+				default:
+					_ = db.Stats()
+					_ = db.(*ReconnectableCloudtrustDB).CircuitState()
+				}
+				// Let the reconnection loop run while requests are in progress
+				time.Sleep(10 * time.Microsecond)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(0), usedAfterClose.Load())
+	assert.Eventually(t, func() bool {
+		return db.(*ReconnectableCloudtrustDB).CircuitState() == CircuitClosed
+	}, time.Second, time.Millisecond)
+	assert.Greater(t, opened.Load(), int32(1))
+	assert.Nil(t, db.Close())
+	assert.Equal(t, CircuitClosed, db.(*ReconnectableCloudtrustDB).CircuitState())
 }
@@ -0,0 +1,453 @@
+// Package dbtest provides an in-memory sqltypes.CloudtrustDB returning canned results, to be used in unit tests
+// instead of hand-wiring mock expectations
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+)
+
+var (
+	// ErrUnexpectedQuery is returned when no canned result matches an executed statement
+	ErrUnexpectedQuery = errors.New("unexpected query")
+)
+
+// Statement is a statement executed on the fake database
+type Statement struct {
+	Query string
+	Args  []any
+	// Tx is the number of the transaction the statement has been executed in (starting at 1). 0 outside transactions
+	Tx int
+}
+
+// Result is a canned result returned for the statements matching a pattern
+type Result struct {
+	pattern      *regexp.Regexp
+	args         []any
+	once         bool
+	rows         [][]any
+	err          error
+	rowsAffected int64
+	lastInsertID int64
+}
+
+// WithArgs restricts the result to the statements executed with the given arguments
+func (r *Result) WithArgs(args ...any) *Result {
+	r.args = args
+	return r
+}
+
+// WithRows defines the rows returned by Query and QueryRow. Each row contains one value per column
+func (r *Result) WithRows(rows ...[]any) *Result {
+	r.rows = rows
+	return r
+}
+
+// WithError makes the matching statements fail with the given error
+func (r *Result) WithError(err error) *Result {
+	r.err = err
+	return r
+}
+
+// WithRowsAffected defines the number of rows affected returned by Exec
+func (r *Result) WithRowsAffected(count int64) *Result {
+	r.rowsAffected = count
+	return r
+}
+
+// WithLastInsertID defines the last insert ID returned by Exec
+func (r *Result) WithLastInsertID(id int64) *Result {
+	r.lastInsertID = id
+	return r
+}
+
+// Once makes the result usable only once. Registering several results for the same pattern with Once returns them in
+// registration order
+func (r *Result) Once() *Result {
+	r.once = true
+	return r
+}
+
+func (r *Result) matches(query string, args []any) bool {
+	if !r.pattern.MatchString(query) {
+		return false
+	}
+	return r.args == nil || reflect.DeepEqual(r.args, args)
+}
+
+// LastInsertId implements sql.Result
+func (r *Result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+// RowsAffected implements sql.Result
+func (r *Result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// FakeDB is an in-memory sqltypes.CloudtrustDB. Statements are matched against the registered results and recorded
+type FakeDB struct {
+	mutex        sync.Mutex
+	results      []*Result
+	statements   []Statement
+	transactions []*FakeTx
+	beginTxErr   error
+	commitErr    error
+	pingErr      error
+	stats        sql.DBStats
+	closed       bool
+}
+
+// NewFakeDB creates an empty fake database
+func NewFakeDB() *FakeDB {
+	return &FakeDB{}
+}
+
+// On registers a result for the statements matching the given regular expression. Whitespaces of the statements are
+// normalized (indentation and line breaks are replaced by a single space) before being matched. When several results
+// match, the first registered one is used
+func (db *FakeDB) On(pattern string) *Result {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var res = &Result{pattern: regexp.MustCompile(pattern)}
+	db.results = append(db.results, res)
+	return res
+}
+
+// FailBeginTx makes BeginTx fail with the given error. Use nil to restore the default behavior
+func (db *FakeDB) FailBeginTx(err error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.beginTxErr = err
+}
+
+// FailCommit makes Commit fail with the given error. Use nil to restore the default behavior
+func (db *FakeDB) FailCommit(err error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.commitErr = err
+}
+
+// FailPing makes Ping fail with the given error. Use nil to restore the default behavior
+func (db *FakeDB) FailPing(err error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.pingErr = err
+}
+
+// SetStats defines the statistics returned by Stats
+func (db *FakeDB) SetStats(stats sql.DBStats) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.stats = stats
+}
+
+// Statements returns the executed statements, in execution order
+func (db *FakeDB) Statements() []Statement {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return append([]Statement{}, db.statements...)
+}
+
+// StatementsMatching returns the executed statements matching the given regular expression
+func (db *FakeDB) StatementsMatching(pattern string) []Statement {
+	var re = regexp.MustCompile(pattern)
+	var res []Statement
+	for _, statement := range db.Statements() {
+		if re.MatchString(statement.Query) {
+			res = append(res, statement)
+		}
+	}
+	return res
+}
+
+// Transactions returns the transactions created with BeginTx
+func (db *FakeDB) Transactions() []*FakeTx {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return append([]*FakeTx{}, db.transactions...)
+}
+
+// IsClosed tells whether Close has been called
+func (db *FakeDB) IsClosed() bool {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.closed
+}
+
+// Reset removes the registered results and the recorded statements and transactions
+func (db *FakeDB) Reset() {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.results = nil
+	db.statements = nil
+	db.transactions = nil
+	db.beginTxErr = nil
+	db.commitErr = nil
+	db.pingErr = nil
+}
+
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// execute records a statement and returns the matching result
+func (db *FakeDB) execute(tx int, query string, args []any) (*Result, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	query = normalize(query)
+	db.statements = append(db.statements, Statement{Query: query, Args: args, Tx: tx})
+	for i, res := range db.results {
+		if res.matches(query, args) {
+			if res.once {
+				db.results = append(db.results[:i:i], db.results[i+1:]...)
+			}
+			return res, res.err
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnexpectedQuery, query)
+}
+
+func (db *FakeDB) exec(tx int, query string, args []any) (sql.Result, error) {
+	var res, err = db.execute(tx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (db *FakeDB) query(tx int, query string, args []any) (sqltypes.SQLRows, error) {
+	var res, err = db.execute(tx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{rows: res.rows, pos: -1}, nil
+}
+
+func (db *FakeDB) queryRow(tx int, query string, args []any) sqltypes.SQLRow {
+	var res, err = db.execute(tx, query, args)
+	if err != nil {
+		return sqltypes.NewSQLRowError(err)
+	}
+	if len(res.rows) == 0 {
+		return sqltypes.NewSQLRowError(sql.ErrNoRows)
+	}
+	return &row{values: res.rows[0]}
+}
+
+// BeginTx creates a transaction
+func (db *FakeDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (sqltypes.Transaction, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.beginTxErr != nil {
+		return nil, db.beginTxErr
+	}
+	var tx = &FakeTx{db: db, id: len(db.transactions) + 1, Options: opts}
+	db.transactions = append(db.transactions, tx)
+	return tx, nil
+}
+
+// Exec an SQL query
+func (db *FakeDB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.exec(0, query, args)
+}
+
+// Query a multiple-rows SQL result
+func (db *FakeDB) Query(query string, args ...any) (sqltypes.SQLRows, error) {
+	return db.query(0, query, args)
+}
+
+// QueryRow a single-row SQL result
+func (db *FakeDB) QueryRow(query string, args ...any) sqltypes.SQLRow {
+	return db.queryRow(0, query, args)
+}
+
+// ExecContext executes an SQL query using the given context
+func (db *FakeDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return db.exec(0, query, args)
+}
+
+// QueryContext queries a multiple-rows SQL result using the given context
+func (db *FakeDB) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return db.query(0, query, args)
+}
+
+// QueryRowContext queries a single-row SQL result using the given context
+func (db *FakeDB) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	if err := ctx.Err(); err != nil {
+		return sqltypes.NewSQLRowError(err)
+	}
+	return db.queryRow(0, query, args)
+}
+
+// Ping returns the error defined with FailPing
+func (db *FakeDB) Ping() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.pingErr
+}
+
+// PingContext returns the error defined with FailPing
+func (db *FakeDB) PingContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return db.Ping()
+}
+
+// Close marks the database as closed
+func (db *FakeDB) Close() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.closed = true
+	return nil
+}
+
+// Stats returns the statistics defined with SetStats
+func (db *FakeDB) Stats() sql.DBStats {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.stats
+}
+
+// FakeTx is a transaction of a FakeDB
+type FakeTx struct {
+	db         *FakeDB
+	id         int
+	mutex      sync.Mutex
+	committed  bool
+	rolledBack bool
+	// Options are the options given to BeginTx
+	Options *sql.TxOptions
+}
+
+// ID returns the number of the transaction, as recorded in Statement.Tx
+func (tx *FakeTx) ID() int {
+	return tx.id
+}
+
+// IsCommitted tells whether the transaction has been committed
+func (tx *FakeTx) IsCommitted() bool {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	return tx.committed
+}
+
+// IsRolledBack tells whether the transaction has been rolled back, explicitly or by Close
+func (tx *FakeTx) IsRolledBack() bool {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	return tx.rolledBack
+}
+
+func (tx *FakeTx) checkActive() error {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if tx.committed || tx.rolledBack {
+		return sql.ErrTxDone
+	}
+	return nil
+}
+
+// Commit the transaction. Fails with the error defined with FakeDB.FailCommit, in which case the transaction is rolled back
+func (tx *FakeTx) Commit() error {
+	if err := tx.checkActive(); err != nil {
+		return err
+	}
+	tx.db.mutex.Lock()
+	var err = tx.db.commitErr
+	tx.db.mutex.Unlock()
+
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if err != nil {
+		tx.rolledBack = true
+		return err
+	}
+	tx.committed = true
+	return nil
+}
+
+// Rollback the transaction
+func (tx *FakeTx) Rollback() error {
+	if err := tx.checkActive(); err != nil {
+		return err
+	}
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	tx.rolledBack = true
+	return nil
+}
+
+// Close rolls back the transaction if it has not been committed or rolled back
+func (tx *FakeTx) Close() error {
+	if tx.checkActive() != nil {
+		return nil
+	}
+	return tx.Rollback()
+}
+
+// Exec an SQL query in the transaction
+func (tx *FakeTx) Exec(query string, args ...any) (sql.Result, error) {
+	if err := tx.checkActive(); err != nil {
+		return nil, err
+	}
+	return tx.db.exec(tx.id, query, args)
+}
+
+// Query a multiple-rows SQL result in the transaction
+func (tx *FakeTx) Query(query string, args ...any) (sqltypes.SQLRows, error) {
+	if err := tx.checkActive(); err != nil {
+		return nil, err
+	}
+	return tx.db.query(tx.id, query, args)
+}
+
+// QueryRow a single-row SQL result in the transaction
+func (tx *FakeTx) QueryRow(query string, args ...any) sqltypes.SQLRow {
+	if err := tx.checkActive(); err != nil {
+		return sqltypes.NewSQLRowError(err)
+	}
+	return tx.db.queryRow(tx.id, query, args)
+}
+
+// ExecContext executes an SQL query in the transaction using the given context
+func (tx *FakeTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return tx.Exec(query, args...)
+}
+
+// QueryContext queries a multiple-rows SQL result in the transaction using the given context
+func (tx *FakeTx) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return tx.Query(query, args...)
+}
+
+// QueryRowContext queries a single-row SQL result in the transaction using the given context
+func (tx *FakeTx) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	if err := ctx.Err(); err != nil {
+		return sqltypes.NewSQLRowError(err)
+	}
+	return tx.QueryRow(query, args...)
+}
@@ -0,0 +1,205 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ sqltypes.CloudtrustDB = &FakeDB{}
+var _ sqltypes.Transaction = &FakeTx{}
+
+func TestFakeDBQueries(t *testing.T) {
+	var db = NewFakeDB()
+	var ctx = context.TODO()
+	var anyError = errors.New("any error")
+
+	db.On(`^SELECT id, label, enabled FROM items WHERE realm=\?$`).WithArgs("realm").WithRows(
+		[]any{int64(1), "first", int64(1)},
+		[]any{int64(2), []byte("second"), int64(0)},
+	)
+	db.On(`^UPDATE items`).WithRowsAffected(3)
+	db.On(`^INSERT INTO items`).WithLastInsertID(12).Once()
+	db.On(`^INSERT INTO items`).WithError(anyError)
+
+	t.Run("Query iterates over the rows", func(t *testing.T) {
+		rows, err := db.QueryContext(ctx, "SELECT id, label, enabled\n\t\tFROM items\n\t\tWHERE realm=?", "realm")
+		assert.Nil(t, err)
+		defer rows.Close()
+
+		var ids []int
+		var labels []string
+		var enabled []bool
+		for rows.Next() {
+			var id int
+			var label string
+			var isEnabled bool
+			assert.Nil(t, rows.Scan(&id, &label, &isEnabled))
+			ids = append(ids, id)
+			labels = append(labels, label)
+			enabled = append(enabled, isEnabled)
+		}
+		assert.Nil(t, rows.Err())
+		assert.Equal(t, []int{1, 2}, ids)
+		assert.Equal(t, []string{"first", "second"}, labels)
+		assert.Equal(t, []bool{true, false}, enabled)
+	})
+	t.Run("QueryRow scans the first row", func(t *testing.T) {
+		var id sql.NullInt64
+		var label *string
+		var enabled any
+		assert.Nil(t, db.QueryRow("SELECT id, label, enabled FROM items WHERE realm=?", "realm").Scan(&id, &label, &enabled))
+		assert.Equal(t, sql.NullInt64{Int64: 1, Valid: true}, id)
+		assert.Equal(t, "first", *label)
+		assert.Equal(t, int64(1), enabled)
+	})
+	t.Run("Arguments do not match", func(t *testing.T) {
+		var err = db.QueryRow("SELECT id, label, enabled FROM items WHERE realm=?", "other").Scan()
+		assert.True(t, errors.Is(err, ErrUnexpectedQuery))
+	})
+	t.Run("Wrong number of destinations", func(t *testing.T) {
+		var id int
+		assert.NotNil(t, db.QueryRow("SELECT id, label, enabled FROM items WHERE realm=?", "realm").Scan(&id))
+	})
+	t.Run("Exec", func(t *testing.T) {
+		res, err := db.Exec("UPDATE items SET enabled=0")
+		assert.Nil(t, err)
+		count, _ := res.RowsAffected()
+		assert.Equal(t, int64(3), count)
+
+		res, err = db.ExecContext(ctx, "INSERT INTO items (label) VALUES (?)", "third")
+		assert.Nil(t, err)
+		id, _ := res.LastInsertId()
+		assert.Equal(t, int64(12), id)
+
+		// The first result could be used only once
+		_, err = db.ExecContext(ctx, "INSERT INTO items (label) VALUES (?)", "fourth")
+		assert.Equal(t, anyError, err)
+	})
+	t.Run("Statements are recorded", func(t *testing.T) {
+		var inserts = db.StatementsMatching("^INSERT")
+		assert.Len(t, inserts, 2)
+		assert.Equal(t, "INSERT INTO items (label) VALUES (?)", inserts[0].Query)
+		assert.Equal(t, []any{"third"}, inserts[0].Args)
+		assert.Equal(t, 0, inserts[0].Tx)
+		assert.Len(t, db.Statements(), 7)
+	})
+	t.Run("Unexpected query", func(t *testing.T) {
+		_, err := db.Query("DELETE FROM items")
+		assert.True(t, errors.Is(err, ErrUnexpectedQuery))
+	})
+	t.Run("Cancelled context", func(t *testing.T) {
+		var cancelled, cancel = context.WithCancel(ctx)
+		cancel()
+		_, err := db.ExecContext(cancelled, "UPDATE items SET enabled=0")
+		assert.Equal(t, context.Canceled, err)
+	})
+	t.Run("Reset", func(t *testing.T) {
+		db.Reset()
+		assert.Len(t, db.Statements(), 0)
+		_, err := db.Exec("UPDATE items SET enabled=0")
+		assert.True(t, errors.Is(err, ErrUnexpectedQuery))
+	})
+}
+
+func TestFakeDBTransactions(t *testing.T) {
+	var db = NewFakeDB()
+	var ctx = context.TODO()
+	var anyError = errors.New("any error")
+	db.On(`^UPDATE`)
+
+	t.Run("Commit", func(t *testing.T) {
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+		assert.Nil(t, err)
+		_, err = tx.ExecContext(ctx, "UPDATE items SET enabled=1")
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Commit())
+		assert.Nil(t, tx.Close())
+
+		_, err = tx.Exec("UPDATE items SET enabled=1")
+		assert.Equal(t, sql.ErrTxDone, err)
+
+		var fakeTx = db.Transactions()[0]
+		assert.True(t, fakeTx.IsCommitted())
+		assert.False(t, fakeTx.IsRolledBack())
+		assert.Equal(t, sql.LevelSerializable, fakeTx.Options.Isolation)
+		assert.Equal(t, fakeTx.ID(), db.Statements()[0].Tx)
+	})
+	t.Run("Close rolls back", func(t *testing.T) {
+		tx, _ := db.BeginTx(ctx, nil)
+		assert.Nil(t, tx.Close())
+		assert.True(t, db.Transactions()[1].IsRolledBack())
+		assert.Equal(t, sql.ErrTxDone, tx.Rollback())
+	})
+	t.Run("Commit fails", func(t *testing.T) {
+		db.FailCommit(anyError)
+		defer db.FailCommit(nil)
+		tx, _ := db.BeginTx(ctx, nil)
+		assert.Equal(t, anyError, tx.Commit())
+		assert.True(t, db.Transactions()[2].IsRolledBack())
+	})
+	t.Run("BeginTx fails", func(t *testing.T) {
+		db.FailBeginTx(anyError)
+		defer db.FailBeginTx(nil)
+		_, err := db.BeginTx(ctx, nil)
+		assert.Equal(t, anyError, err)
+	})
+}
+
+func TestFakeDBMisc(t *testing.T) {
+	var db = NewFakeDB()
+	var anyError = errors.New("any error")
+
+	assert.Nil(t, db.Ping())
+	db.FailPing(anyError)
+	assert.Equal(t, anyError, db.PingContext(context.TODO()))
+
+	db.SetStats(sql.DBStats{OpenConnections: 4})
+	assert.Equal(t, 4, db.Stats().OpenConnections)
+
+	assert.False(t, db.IsClosed())
+	assert.Nil(t, db.Close())
+	assert.True(t, db.IsClosed())
+}
+
+func TestRowsScan(t *testing.T) {
+	var now = time.Now()
+	var rows = NewRows(
+		[]any{"42", int64(7), 1.5, nil, now, "true"},
+	)
+
+	assert.NotNil(t, rows.Scan())
+	assert.True(t, rows.Next())
+
+	var (
+		fromString int
+		toFloat    float64
+		toString   string
+		null       *int
+		date       time.Time
+		flag       bool
+	)
+	assert.Nil(t, rows.Scan(&fromString, &toFloat, &toString, &null, &date, &flag))
+	assert.Equal(t, 42, fromString)
+	assert.Equal(t, 7.0, toFloat)
+	assert.Equal(t, "1.5", toString)
+	assert.Nil(t, null)
+	assert.Equal(t, now, date)
+	assert.True(t, flag)
+
+	var notNullable int
+	var ignored any
+	assert.NotNil(t, rows.Scan(&notNullable, &ignored, &ignored, &notNullable, &ignored, &ignored))
+	assert.NotNil(t, rows.Scan(ignored, &ignored, &ignored, &ignored, &ignored, &ignored))
+
+	assert.False(t, rows.Next())
+	assert.False(t, rows.NextResultSet())
+	assert.Nil(t, rows.Close())
+	assert.True(t, rows.IsClosed())
+	assert.NotNil(t, rows.Scan())
+}
@@ -0,0 +1,200 @@
+package dbtest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Rows is an in-memory sqltypes.SQLRows
+type Rows struct {
+	rows   [][]any
+	pos    int
+	closed bool
+}
+
+// NewRows creates SQLRows iterating over the given rows. Each row contains one value per column
+func NewRows(rows ...[]any) *Rows {
+	return &Rows{rows: rows, pos: -1}
+}
+
+// Next prepares the next row to be read with Scan
+func (r *Rows) Next() bool {
+	if r.closed || r.pos+1 >= len(r.rows) {
+		return false
+	}
+	r.pos++
+	return true
+}
+
+// NextResultSet always returns false: only one result set is supported
+func (r *Rows) NextResultSet() bool {
+	return false
+}
+
+// Err always returns nil
+func (r *Rows) Err() error {
+	return nil
+}
+
+// Scan copies the values of the current row into the given destinations
+func (r *Rows) Scan(dest ...any) error {
+	if r.closed {
+		return errors.New("sql: Rows are closed")
+	}
+	if r.pos < 0 || r.pos >= len(r.rows) {
+		return errors.New("sql: Scan called without calling Next")
+	}
+	return scanValues(r.rows[r.pos], dest)
+}
+
+// Close closes the rows
+func (r *Rows) Close() error {
+	r.closed = true
+	return nil
+}
+
+// IsClosed tells whether Close has been called
+func (r *Rows) IsClosed() bool {
+	return r.closed
+}
+
+type row struct {
+	values []any
+}
+
+func (r *row) Scan(dest ...any) error {
+	return scanValues(r.values, dest)
+}
+
+func scanValues(values []any, dest []any) error {
+	if len(values) != len(dest) {
+		return fmt.Errorf("sql: expected %d destination arguments in Scan, not %d", len(values), len(dest))
+	}
+	for i, value := range values {
+		if err := assign(dest[i], value); err != nil {
+			return fmt.Errorf("sql: Scan error on column index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// assign copies a value into a destination given to Scan, with conversions similar to the ones of database/sql
+func assign(dest any, value any) error {
+	if scanner, ok := dest.(sql.Scanner); ok {
+		return scanner.Scan(driverValue(value))
+	}
+
+	var target = reflect.ValueOf(dest)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return errors.New("destination not a pointer")
+	}
+	return assignValue(target.Elem(), value)
+}
+
+func assignValue(target reflect.Value, value any) error {
+	if value == nil {
+		switch target.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		return fmt.Errorf("converting NULL to %s is unsupported", target.Type())
+	}
+
+	var source = reflect.ValueOf(value)
+	switch {
+	case source.Type().AssignableTo(target.Type()):
+		target.Set(source)
+		return nil
+	case target.Kind() == reflect.Pointer:
+		var elem = reflect.New(target.Type().Elem())
+		if err := assignValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	case target.Kind() == reflect.String:
+		// database/sql converts any scalar to a string
+		switch v := value.(type) {
+		case []byte:
+			target.SetString(string(v))
+		case time.Time:
+			target.SetString(v.Format(time.RFC3339Nano))
+		default:
+			target.SetString(fmt.Sprint(value))
+		}
+		return nil
+	case target.Kind() == reflect.Bool && (source.CanInt() || source.CanUint()):
+		// Booleans are usually stored as TINYINT(1)
+		target.SetBool(!source.IsZero())
+		return nil
+	case source.Kind() == reflect.String && (isNumeric(target.Kind()) || target.Kind() == reflect.Bool):
+		return assignString(target, source.String())
+	case isNumeric(source.Kind()) && isNumeric(target.Kind()), source.Type().ConvertibleTo(target.Type()) && source.Kind() != reflect.String:
+		target.Set(source.Convert(target.Type()))
+		return nil
+	}
+	return fmt.Errorf("unsupported Scan, storing %T into type %s", value, target.Type())
+}
+
+func assignString(target reflect.Value, value string) error {
+	switch {
+	case target.CanInt():
+		var v, err = strconv.ParseInt(value, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetInt(v)
+	case target.CanUint():
+		var v, err = strconv.ParseUint(value, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetUint(v)
+	case target.CanFloat():
+		var v, err = strconv.ParseFloat(value, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetFloat(v)
+	default:
+		var v, err = strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		target.SetBool(v)
+	}
+	return nil
+}
+
+func isNumeric(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// driverValue converts a value to one of the types a driver gives to sql.Scanner implementations
+func driverValue(value any) any {
+	if value == nil {
+		return nil
+	}
+	var v = reflect.ValueOf(value)
+	switch {
+	case v.CanInt():
+		return v.Int()
+	case v.CanUint():
+		return int64(v.Uint())
+	case v.CanFloat():
+		return v.Float()
+	case v.Kind() == reflect.String:
+		return v.String()
+	}
+	return value
+}
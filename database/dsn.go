@@ -0,0 +1,150 @@
+package database
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const tlsParameter = "tls"
+
+var (
+	// ErrInvalidDbConfig is returned when a connection string can't be built from a DbConfig
+	ErrInvalidDbConfig = errors.New("invalid database configuration")
+)
+
+// getDbConnectionString builds the connection string in the format of the MySQL driver:
+// username[:password]@protocol(address)/dbname[?param1=value1&...&paramN=valueN]
+// The password and the TLS files are read each time the string is built, so that rotated secrets are taken into account
+// when the connection is reopened
+func (cfg *DbConfig) getDbConnectionString() (string, error) {
+	if strings.Contains(cfg.Username, ":") {
+		return "", fmt.Errorf("%w: username can't contain ':'", ErrInvalidDbConfig)
+	}
+	password, err := cfg.getPassword()
+	if err != nil {
+		return "", err
+	}
+	params, err := cfg.getParameters()
+	if err != nil {
+		return "", err
+	}
+
+	// The driver splits the string on the last '/' and on the last '@' before it: the password is written as is, the
+	// database name and the parameter values are escaped
+	var dsn strings.Builder
+	dsn.WriteString(cfg.Username)
+	if password != "" {
+		dsn.WriteString(":")
+		dsn.WriteString(password)
+	}
+	dsn.WriteString("@")
+	dsn.WriteString(cfg.Protocol)
+	dsn.WriteString("(")
+	dsn.WriteString(cfg.HostPort)
+	dsn.WriteString(")/")
+	dsn.WriteString(url.PathEscape(cfg.Database))
+	if len(params) > 0 {
+		dsn.WriteString("?")
+		dsn.WriteString(strings.Join(params, "&"))
+	}
+	return dsn.String(), nil
+}
+
+// getPassword returns the content of the password file if any, the configured password otherwise
+func (cfg *DbConfig) getPassword() (string, error) {
+	if cfg.PasswordFile == "" {
+		return cfg.Password, nil
+	}
+	content, err := os.ReadFile(cfg.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("can't read database password file: %w", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// getParameters escapes the values of the configured parameters and adds the TLS parameter when TLS files are
+// configured. Values are taken literally: a '%' is escaped like any other character
+func (cfg *DbConfig) getParameters() ([]string, error) {
+	var tlsName, err = cfg.registerTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var params []string
+	for _, param := range strings.Split(cfg.Parameters, "&") {
+		if param == "" {
+			continue
+		}
+		var key, value, hasValue = strings.Cut(param, "=")
+		if tlsName != "" && key == tlsParameter {
+			continue
+		}
+		if !hasValue {
+			params = append(params, key)
+			continue
+		}
+		params = append(params, key+"="+url.QueryEscape(value))
+	}
+	if tlsName != "" {
+		params = append(params, tlsParameter+"="+url.QueryEscape(tlsName))
+	}
+	return params, nil
+}
+
+func (cfg *DbConfig) hasTLSFiles() bool {
+	return cfg.TLSCAFile != "" || cfg.TLSCertFile != "" || cfg.TLSKeyFile != ""
+}
+
+// registerTLSConfig loads the TLS files and registers the resulting configuration with RegisterTLSConfig. It returns
+// the name of the registered configuration, or an empty string if no TLS file is configured
+func (cfg *DbConfig) registerTLSConfig() (string, error) {
+	if !cfg.hasTLSFiles() {
+		return "", nil
+	}
+	if cfg.RegisterTLSConfig == nil {
+		return "", fmt.Errorf("%w: TLS files are configured but RegisterTLSConfig is not set", ErrInvalidDbConfig)
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return "", fmt.Errorf("%w: tls-cert-file and tls-key-file must be configured together", ErrInvalidDbConfig)
+	}
+
+	var tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if host, _, err := net.SplitHostPort(cfg.HostPort); err == nil {
+		tlsConfig.ServerName = host
+	} else {
+		tlsConfig.ServerName = cfg.HostPort
+	}
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return "", fmt.Errorf("can't read database CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return "", fmt.Errorf("%w: no certificate found in %s", ErrInvalidDbConfig, cfg.TLSCAFile)
+		}
+	}
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return "", fmt.Errorf("can't load database client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// The name only depends on the configuration so that reloading the files replaces the registered configuration
+	var hash = sha256.Sum256([]byte(strings.Join([]string{cfg.HostPort, cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile}, "|")))
+	var name = "cloudtrust-" + hex.EncodeToString(hash[:8])
+	if err := cfg.RegisterTLSConfig(name, tlsConfig); err != nil {
+		return "", fmt.Errorf("can't register database TLS configuration: %w", err)
+	}
+	return name, nil
+}
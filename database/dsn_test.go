@@ -0,0 +1,149 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	var template = x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "db.local"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	var certFile = filepath.Join(dir, "cert.pem")
+	var keyFile = filepath.Join(dir, "key.pem")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func TestDbConnectionStringEscaping(t *testing.T) {
+	var conf = DbConfig{
+		Username:   "user",
+		Password:   "p@ss/w:rd?",
+		Protocol:   "tcp",
+		HostPort:   "db.local:3306",
+		Database:   "my/db",
+		Parameters: "parseTime=true&loc=Europe/Zurich&time_zone='+00:00'&interpolateParams&charset=%41",
+	}
+
+	t.Run("Values are escaped", func(t *testing.T) {
+		var dsn, err = conf.getDbConnectionString()
+		assert.Nil(t, err)
+		assert.Equal(t, "user:p@ss/w:rd?@tcp(db.local:3306)/my%2Fdb?parseTime=true&loc=Europe%2FZurich&time_zone=%27%2B00%3A00%27&interpolateParams&charset=%2541", dsn)
+	})
+	t.Run("Username can't contain a colon", func(t *testing.T) {
+		var invalid = conf
+		invalid.Username = "us:er"
+		var _, err = invalid.getDbConnectionString()
+		assert.ErrorIs(t, err, ErrInvalidDbConfig)
+	})
+}
+
+func TestDbConnectionStringPasswordFile(t *testing.T) {
+	var passwordFile = filepath.Join(t.TempDir(), "password")
+	var conf = DbConfig{Username: "user", Password: "ignored", PasswordFile: passwordFile, Protocol: "tcp", HostPort: "db:3306", Database: "db"}
+
+	t.Run("Missing file", func(t *testing.T) {
+		var _, err = conf.getDbConnectionString()
+		assert.NotNil(t, err)
+	})
+	t.Run("Password is read from the file", func(t *testing.T) {
+		assert.Nil(t, os.WriteFile(passwordFile, []byte("secret\n"), 0600))
+		var dsn, err = conf.getDbConnectionString()
+		assert.Nil(t, err)
+		assert.Equal(t, "user:secret@tcp(db:3306)/db", dsn)
+	})
+	t.Run("Rotated password is read again", func(t *testing.T) {
+		assert.Nil(t, os.WriteFile(passwordFile, []byte("rotated"), 0600))
+		var dsn, err = conf.getDbConnectionString()
+		assert.Nil(t, err)
+		assert.Equal(t, "user:rotated@tcp(db:3306)/db", dsn)
+	})
+}
+
+func TestDbConnectionStringTLS(t *testing.T) {
+	var certFile, keyFile = writeTestCertificate(t, t.TempDir())
+	var registered = map[string]*tls.Config{}
+	var register = func(name string, config *tls.Config) error {
+		registered[name] = config
+		return nil
+	}
+	var conf = DbConfig{
+		Username:    "user",
+		Password:    "pass",
+		Protocol:    "tcp",
+		HostPort:    "db.local:3306",
+		Database:    "db",
+		Parameters:  "tls=skip-verify&parseTime=true",
+		TLSCAFile:   certFile,
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+	}
+
+	t.Run("RegisterTLSConfig is required", func(t *testing.T) {
+		var _, err = conf.getDbConnectionString()
+		assert.ErrorIs(t, err, ErrInvalidDbConfig)
+	})
+
+	conf.RegisterTLSConfig = register
+
+	t.Run("TLS configuration is registered", func(t *testing.T) {
+		var dsn, err = conf.getDbConnectionString()
+		assert.Nil(t, err)
+		assert.Len(t, registered, 1)
+		for name, config := range registered {
+			assert.Equal(t, "user:pass@tcp(db.local:3306)/db?parseTime=true&tls="+name, dsn)
+			assert.Equal(t, "db.local", config.ServerName)
+			assert.NotNil(t, config.RootCAs)
+			assert.Len(t, config.Certificates, 1)
+		}
+
+		// Same name when the files are reloaded
+		_, err = conf.getDbConnectionString()
+		assert.Nil(t, err)
+		assert.Len(t, registered, 1)
+	})
+	t.Run("Certificate without key", func(t *testing.T) {
+		var invalid = conf
+		invalid.TLSKeyFile = ""
+		var _, err = invalid.getDbConnectionString()
+		assert.ErrorIs(t, err, ErrInvalidDbConfig)
+	})
+	t.Run("Invalid CA file", func(t *testing.T) {
+		var invalid = conf
+		invalid.TLSCAFile = keyFile
+		var _, err = invalid.getDbConnectionString()
+		assert.ErrorIs(t, err, ErrInvalidDbConfig)
+	})
+	t.Run("Registration fails", func(t *testing.T) {
+		var registerErr = errors.New("register error")
+		var invalid = conf
+		invalid.RegisterTLSConfig = func(string, *tls.Config) error { return registerErr }
+		var _, err = invalid.getDbConnectionString()
+		assert.ErrorIs(t, err, registerErr)
+	})
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidAuditEvent is returned when an audit event built with NewAuditEvent is not valid
+	ErrInvalidAuditEvent = errors.New("invalid audit event")
+)
+
+// AuditEventBuilder builds the details of an audit event as expected by EventStorer.Store
+type AuditEventBuilder struct {
+	details map[string]string
+	err     error
+}
+
+// NewAuditEvent starts building an audit event of the given ct_event_type. The audit time is set to the current time
+func NewAuditEvent(ctEventType string) *AuditEventBuilder {
+	return &AuditEventBuilder{
+		details: map[string]string{
+			CtEventType:      ctEventType,
+			CtEventAuditTime: time.Now().UTC().Format(AuditTimeFormat),
+		},
+	}
+}
+
+func (b *AuditEventBuilder) set(key string, value string) *AuditEventBuilder {
+	if value != "" {
+		b.details[key] = value
+	}
+	return b
+}
+
+func (b *AuditEventBuilder) fail(format string, args ...any) *AuditEventBuilder {
+	if b.err == nil {
+		b.err = fmt.Errorf("%w: %s", ErrInvalidAuditEvent, fmt.Sprintf(format, args...))
+	}
+	return b
+}
+
+// AuditTime overrides the time of the event
+func (b *AuditEventBuilder) AuditTime(auditTime time.Time) *AuditEventBuilder {
+	return b.set(CtEventAuditTime, auditTime.UTC().Format(AuditTimeFormat))
+}
+
+// Origin sets the component which initiated the event
+func (b *AuditEventBuilder) Origin(origin string) *AuditEventBuilder {
+	return b.set(CtEventOrigin, origin)
+}
+
+// TargetUser sets the user impacted by the action
+func (b *AuditEventBuilder) TargetUser(realmName, userID, username string) *AuditEventBuilder {
+	return b.set(CtEventTargetRealmName, realmName).set(CtEventTargetUserID, userID).set(CtEventTargetUsername, username)
+}
+
+// Agent sets the user performing the action
+func (b *AuditEventBuilder) Agent(realmName, userID, username string) *AuditEventBuilder {
+	return b.set(CtEventAgentRealmName, realmName).set(CtEventAgentUserID, userID).set(CtEventAgentUsername, username)
+}
+
+// AgentFromContext sets the user performing the action from the values of the context
+func (b *AuditEventBuilder) AgentFromContext(ctx context.Context) *AuditEventBuilder {
+	var event = ReportEventDetails{details: b.details}
+	event.AddAgentDetails(ctx)
+	return b
+}
+
+// KeycloakEvent sets the Keycloak event and operation types
+func (b *AuditEventBuilder) KeycloakEvent(eventType, operationType string) *AuditEventBuilder {
+	return b.set(CtEventKcEventType, eventType).set(CtEventKcOperationType, operationType)
+}
+
+// Client sets the client ID
+func (b *AuditEventBuilder) Client(clientID string) *AuditEventBuilder {
+	return b.set(CtEventClientID, clientID)
+}
+
+// Group sets the group impacted by the action
+func (b *AuditEventBuilder) Group(groupID, groupName string) *AuditEventBuilder {
+	return b.set(CtEventGroupID, groupID).set(CtEventGroupName, groupName)
+}
+
+// Role sets the role impacted by the action
+func (b *AuditEventBuilder) Role(roleID, roleName string) *AuditEventBuilder {
+	return b.set(CtEventRoleID, roleID).set(CtEventRoleName, roleName)
+}
+
+// IsReservedDetailKey checks if the given key is the one of an audit table column, which can't be used as a detail
+func IsReservedDetailKey(key string) bool {
+	return isInArray(ctEventColumns, key)
+}
+
+// Detail adds an information stored in the additional_info column. Keys of the audit table columns are reserved: they
+// must be set with the dedicated methods
+func (b *AuditEventBuilder) Detail(key, value string) *AuditEventBuilder {
+	switch {
+	case key == "":
+		return b.fail("empty detail key")
+	case IsReservedDetailKey(key):
+		return b.fail("reserved key %s can't be used as a detail", key)
+	}
+	b.details[key] = value
+	return b
+}
+
+// Build checks the event and returns its details
+func (b *AuditEventBuilder) Build() (map[string]string, error) {
+	switch {
+	case b.err != nil:
+		return nil, b.err
+	case b.details[CtEventType] == "":
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidAuditEvent, CtEventType)
+	case b.details[CtEventOrigin] == "":
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidAuditEvent, CtEventOrigin)
+	}
+	var res = make(map[string]string, len(b.details))
+	for key, value := range b.details {
+		res[key] = value
+	}
+	return res, nil
+}
+
+// Report builds the event and stores it
+func (b *AuditEventBuilder) Report(ctx context.Context, storer EventStorer) error {
+	var details, err = b.Build()
+	if err != nil {
+		return err
+	}
+	return storer.Store(ctx, details)
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	cs "github.com/cloudtrust/common-service/v2"
+	"github.com/cloudtrust/common-service/v2/database/dbtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditEventBuilder(t *testing.T) {
+	var auditTime = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("Valid event", func(t *testing.T) {
+		var ctx = context.WithValue(context.TODO(), cs.CtContextUsername, "agent")
+		details, err := NewAuditEvent("UPDATE_USER").
+			Origin("back-office").
+			AuditTime(auditTime).
+			AgentFromContext(ctx).
+			TargetUser("realm", "user-id", "").
+			KeycloakEvent("", "UPDATE").
+			Client("client").
+			Group("group-id", "group").
+			Role("role-id", "").
+			Detail("reason", "requested").
+			Build()
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{
+			CtEventType:            "UPDATE_USER",
+			CtEventOrigin:          "back-office",
+			CtEventAuditTime:       "2024-03-01 10:00:00.000",
+			CtEventAgentUsername:   "agent",
+			CtEventTargetRealmName: "realm",
+			CtEventTargetUserID:    "user-id",
+			CtEventKcOperationType: "UPDATE",
+			CtEventClientID:        "client",
+			CtEventGroupID:         "group-id",
+			CtEventGroupName:       "group",
+			CtEventRoleID:          "role-id",
+			"reason":               "requested",
+		}, details)
+	})
+	t.Run("Explicit agent", func(t *testing.T) {
+		details, err := NewAuditEvent("LOGIN").Origin("back-office").Agent("master", "agent-id", "agent").Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "master", details[CtEventAgentRealmName])
+		assert.Equal(t, "agent-id", details[CtEventAgentUserID])
+		assert.Equal(t, "agent", details[CtEventAgentUsername])
+	})
+	t.Run("Invalid events", func(t *testing.T) {
+		for name, builder := range map[string]*AuditEventBuilder{
+			"missing type":   NewAuditEvent("").Origin("back-office"),
+			"missing origin": NewAuditEvent("LOGIN"),
+			"reserved key":   NewAuditEvent("LOGIN").Origin("back-office").Detail(CtEventTargetRealmName, "realm"),
+			"empty key":      NewAuditEvent("LOGIN").Origin("back-office").Detail("", "value"),
+		} {
+			_, err := builder.Build()
+			assert.True(t, errors.Is(err, ErrInvalidAuditEvent), name)
+		}
+	})
+	t.Run("Report", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		db.On("^INSERT INTO audit")
+		var module = NewEventsDBModule(db)
+
+		assert.Nil(t, NewAuditEvent("LOGIN").Origin("back-office").Detail("ip", "127.0.0.1").Report(context.TODO(), module))
+		assert.Len(t, db.Statements(), 1)
+		assert.Equal(t, `{"ip":"127.0.0.1"}`, db.Statements()[0].Args[12])
+
+		var err = NewAuditEvent("LOGIN").Report(context.TODO(), module)
+		assert.True(t, errors.Is(err, ErrInvalidAuditEvent))
+		assert.Len(t, db.Statements(), 1)
+	})
+}
@@ -10,8 +10,7 @@ import (
 )
 
 const (
-	timeFormat  = "2006-01-02 15:04:05.000"
-	insertEvent = `INSERT INTO audit (
+	insertEventHeader = `INSERT INTO audit (
 		audit_time,
 		origin,
 		realm_name,
@@ -24,38 +23,54 @@ const (
 		kc_event_type,
 		kc_operation_type,
 		client_id,
-		additional_info) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`
+		additional_info)
+		VALUES `
+	insertEventValues = `(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	insertEvent       = insertEventHeader + insertEventValues
 )
 
-// Defines event information constants
+// AuditTimeFormat is the format of the audit_time column
+const AuditTimeFormat = "2006-01-02 15:04:05.000"
+
+// Defines event information constants. They are named as in the events package: the target of an event is stored in
+// the realm_name, user_id and username columns
 const (
 	CtEventType            = "ct_event_type"
 	CtEventAgentUsername   = "agent_username"
 	CtEventAgentRealmName  = "agent_realm_name"
-	CtEventUserID          = "user_id"
+	CtEventTargetUserID    = "user_id"
 	CtEventGroupID         = "group_id"
 	CtEventGroupName       = "group_name"
 	CtEventRoleID          = "role_id"
 	CtEventRoleName        = "role_name"
 	CtEventOrigin          = "origin"
 	CtEventAuditTime       = "audit_time"
-	CtEventRealmName       = "realm_name"
+	CtEventTargetRealmName = "realm_name"
 	CtEventAgentUserID     = "agent_user_id"
-	CtEventUsername        = "username"
+	CtEventTargetUsername  = "username"
 	CtEventKcEventType     = "kc_event_type"
 	CtEventKcOperationType = "kc_operation_type"
 	CtEventClientID        = "client_id"
 	CtEventAdditionalInfo  = "additional_info"
 )
 
+// Former names of the event target constants
+const (
+	// Deprecated: use CtEventTargetUserID
+	CtEventUserID = CtEventTargetUserID
+	// Deprecated: use CtEventTargetUsername
+	CtEventUsername = CtEventTargetUsername
+	// Deprecated: use CtEventTargetRealmName
+	CtEventRealmName = CtEventTargetRealmName
+)
+
 var ctEventColumns = []string{
-	CtEventType, CtEventAgentUsername, CtEventAgentRealmName, CtEventUserID, CtEventOrigin, CtEventAuditTime, CtEventRealmName,
-	CtEventAgentUserID, CtEventUsername, CtEventKcEventType, CtEventKcOperationType, CtEventClientID, CtEventAdditionalInfo}
+	CtEventType, CtEventAgentUsername, CtEventAgentRealmName, CtEventTargetUserID, CtEventOrigin, CtEventAuditTime, CtEventTargetRealmName,
+	CtEventAgentUserID, CtEventTargetUsername, CtEventKcEventType, CtEventKcOperationType, CtEventClientID, CtEventAdditionalInfo}
 
 // EventsDBModule is the interface of the audit events module.
 type EventsDBModule interface {
+	AuditSearcher
 	Store(context.Context, map[string]string) error
 	ReportEvent(ctx context.Context, apiCall string, origin string, values ...string) error
 }
@@ -101,10 +116,24 @@ func NewEventsDBModule(db sqltypes.CloudtrustDB) EventsDBModule {
 func (cm *eventsDBModule) Store(_ context.Context, m map[string]string) error {
 	// if ctEventType is not "", then record the events in MariaDB
 	// otherwise, do nothing
-	if m[CtEventType] == "" {
+	var values, ok = auditValues(m)
+	if !ok {
 		return nil
 	}
 
+	//store the event in the DB
+	_, err := cm.db.Exec(insertEvent, values...)
+
+	return err
+}
+
+// auditValues returns the values of the audit table columns, in the order of insertEvent. Returns false if the event
+// has no ct_event_type and should not be stored
+func auditValues(m map[string]string) ([]any, bool) {
+	if m[CtEventType] == "" {
+		return nil, false
+	}
+
 	// the event was already formatted according to the DB structure already at the component level
 
 	//auditTime - time of the event
@@ -112,7 +141,7 @@ func (cm *eventsDBModule) Store(_ context.Context, m map[string]string) error {
 	// origin - the component that initiated the event
 	origin := m[CtEventOrigin]
 	// realmName - realm name of the user that is impacted by the action
-	realmName := m[CtEventRealmName]
+	realmName := m[CtEventTargetRealmName]
 	//agentUserID - userId of who is performing an action
 	agentUserID := m[CtEventAgentUserID]
 	//agentUsername - username of who is performing an action
@@ -120,9 +149,9 @@ func (cm *eventsDBModule) Store(_ context.Context, m map[string]string) error {
 	//agentRealmName - realm of who is performing an action
 	agentRealmName := m[CtEventAgentRealmName]
 	//userID - ID of the user that is impacted by the action
-	userID := m[CtEventUserID]
+	userID := m[CtEventTargetUserID]
 	//username - username of the user that is impacted by the action
-	username := m[CtEventUsername]
+	username := m[CtEventTargetUsername]
 	// ctEventType that  is established before at the component level
 	ctEventType := m[CtEventType]
 	// kcEventType corresponds to keycloak event type
@@ -145,12 +174,9 @@ func (cm *eventsDBModule) Store(_ context.Context, m map[string]string) error {
 		}
 	}
 
-	//store the event in the DB
-	_, err := cm.db.Exec(insertEvent, auditTime, origin, checkNull(realmName), checkNull(agentUserID), checkNull(agentUsername),
+	return []any{auditTime, origin, checkNull(realmName), checkNull(agentUserID), checkNull(agentUsername),
 		checkNull(agentRealmName), checkNull(userID), checkNull(username), checkNull(ctEventType), checkNull(kcEventType),
-		checkNull(kcOperationType), checkNull(clientID), checkNull(additionalInfo))
-
-	return err
+		checkNull(kcOperationType), checkNull(clientID), checkNull(additionalInfo)}, true
 }
 
 // ReportEvent Report the event into the specified eventStorer
@@ -177,12 +203,13 @@ func CreateEvent(apiCall string, origin string) ReportEventDetails {
 	event.details = make(map[string]string)
 	event.details[CtEventType] = apiCall
 	event.details[CtEventOrigin] = origin
-	event.details[CtEventAuditTime] = time.Now().UTC().Format(timeFormat)
+	event.details[CtEventAuditTime] = time.Now().UTC().Format(AuditTimeFormat)
 
 	return event
 }
 
-// AddEventValues enhance the event with more information
+// AddEventValues enhance the event with more information. An odd number of values drops the last one: NewAuditEvent
+// provides a checked alternative
 func (er *ReportEventDetails) AddEventValues(values ...string) {
 	//add information to the event
 	noTuples := len(values)
@@ -191,17 +218,33 @@ func (er *ReportEventDetails) AddEventValues(values ...string) {
 	}
 }
 
-// AddAgentDetails add details from the context
+// AddAgentDetails adds the details of the user performing the action (agent_user_id, agent_username and
+// agent_realm_name) from the context. The impacted user is set with AddTargetDetails
+//
+// Migration note: previous versions wrongly stored the user ID of the context in user_id (the target user column). Callers
+// which relied on it to identify the impacted user must now call AddTargetDetails
 func (er *ReportEventDetails) AddAgentDetails(ctx context.Context) {
 	var mapper = map[cs.CtContext]string{
 		cs.CtContextUsername: CtEventAgentUsername,
-		cs.CtContextUserID:   CtEventUserID,
+		cs.CtContextUserID:   CtEventAgentUserID,
 		cs.CtContextRealm:    CtEventAgentRealmName,
 	}
 	for keyFrom, keyTo := range mapper {
-		var value = ctx.Value(keyFrom)
-		if value != nil {
-			er.details[keyTo] = value.(string)
+		if value, ok := ctx.Value(keyFrom).(string); ok {
+			er.details[keyTo] = value
+		}
+	}
+}
+
+// AddTargetDetails adds the details of the user impacted by the action. Empty values are ignored
+func (er *ReportEventDetails) AddTargetDetails(realmName, userID, username string) {
+	for key, value := range map[string]string{
+		CtEventTargetRealmName: realmName,
+		CtEventTargetUserID:    userID,
+		CtEventTargetUsername:  username,
+	} {
+		if value != "" {
+			er.details[key] = value
 		}
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	cs "github.com/cloudtrust/common-service/v2"
+	"github.com/cloudtrust/common-service/v2/database/dbtest"
 	"github.com/cloudtrust/common-service/v2/database/mock"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
@@ -42,3 +43,37 @@ func TestEventsDBModule(t *testing.T) {
 		assert.Nil(t, err)
 	}
 }
+
+func TestReportEventDetails(t *testing.T) {
+	var ctx = context.WithValue(context.Background(), cs.CtContextUsername, "agent")
+	ctx = context.WithValue(ctx, cs.CtContextUserID, "agent-id")
+	ctx = context.WithValue(ctx, cs.CtContextRealm, "master")
+
+	t.Run("Agent details", func(t *testing.T) {
+		var event = CreateEvent("UPDATE_USER", "back-office")
+		event.AddAgentDetails(ctx)
+		assert.Equal(t, "agent", event.details[CtEventAgentUsername])
+		assert.Equal(t, "agent-id", event.details[CtEventAgentUserID])
+		assert.Equal(t, "master", event.details[CtEventAgentRealmName])
+		assert.NotContains(t, event.details, CtEventTargetUserID)
+		assert.NotContains(t, event.details, CtEventTargetRealmName)
+	})
+	t.Run("Target details", func(t *testing.T) {
+		var event = CreateEvent("UPDATE_USER", "back-office")
+		event.AddTargetDetails("realm", "user-id", "")
+		assert.Equal(t, "realm", event.details[CtEventTargetRealmName])
+		assert.Equal(t, "user-id", event.details[CtEventTargetUserID])
+		assert.NotContains(t, event.details, CtEventTargetUsername)
+	})
+	t.Run("Stored columns", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		db.On("^INSERT INTO audit")
+		assert.Nil(t, NewEventsDBModule(db).ReportEvent(ctx, "UPDATE_USER", "back-office", CtEventTargetUserID, "user-id"))
+
+		var args = db.Statements()[0].Args
+		assert.Equal(t, "agent-id", args[3])
+		assert.Equal(t, "agent", args[4])
+		assert.Equal(t, "master", args[5])
+		assert.Equal(t, "user-id", args[6])
+	})
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+)
+
+const (
+	selectEvents = `SELECT id, audit_time, origin, realm_name, agent_user_id, agent_username, agent_realm_name, user_id, username,
+		ct_event_type, kc_event_type, kc_operation_type, client_id, additional_info
+		FROM audit`
+
+	// DefaultAuditSearchLimit is the number of events returned by Search when AuditQuery.Limit is not set
+	DefaultAuditSearchLimit = 100
+	// MaxAuditSearchLimit is the maximum number of events returned by Search
+	MaxAuditSearchLimit = 1000
+)
+
+var (
+	// ErrInvalidCursor is returned by Search when the given cursor has not been produced by a previous search
+	ErrInvalidCursor = errors.New("invalid audit search cursor")
+)
+
+// AuditSearcher searches the events of the audit table. The table must have a unique auto-incremented id column, used
+// to order the events stored at the same time
+type AuditSearcher interface {
+	Search(ctx context.Context, query AuditQuery) (AuditPage, error)
+}
+
+// NewAuditSearcher creates an audit events searcher, for the components which search the events without storing them.
+// The modules returned by NewEventsDBModule can also search the events
+func NewAuditSearcher(db sqltypes.CloudtrustDB) AuditSearcher {
+	return &eventsDBModule{db: db}
+}
+
+// AuditQuery defines the filters of an audit events search. Empty filters are ignored
+type AuditQuery struct {
+	RealmName      string
+	AgentUserID    string
+	AgentUsername  string
+	AgentRealmName string
+	UserID         string
+	Username       string
+	CtEventTypes   []string
+	KcEventTypes   []string
+	Origin         string
+	// From is inclusive, To is exclusive
+	From *time.Time
+	To   *time.Time
+	// Limit is the maximum number of returned events. Defaults to DefaultAuditSearchLimit
+	Limit int
+	// Cursor is the NextCursor of the previous page. Empty for the first page
+	Cursor string
+}
+
+// AuditEvent is an event of the audit table
+type AuditEvent struct {
+	ID              int64             `json:"id"`
+	AuditTime       time.Time         `json:"audit_time"`
+	Origin          string            `json:"origin,omitempty"`
+	RealmName       string            `json:"realm_name,omitempty"`
+	AgentUserID     string            `json:"agent_user_id,omitempty"`
+	AgentUsername   string            `json:"agent_username,omitempty"`
+	AgentRealmName  string            `json:"agent_realm_name,omitempty"`
+	UserID          string            `json:"user_id,omitempty"`
+	Username        string            `json:"username,omitempty"`
+	CtEventType     string            `json:"ct_event_type,omitempty"`
+	KcEventType     string            `json:"kc_event_type,omitempty"`
+	KcOperationType string            `json:"kc_operation_type,omitempty"`
+	ClientID        string            `json:"client_id,omitempty"`
+	AdditionalInfo  map[string]string `json:"additional_info,omitempty"`
+}
+
+// AuditPage is a page of audit events, from the most recent to the oldest one
+type AuditPage struct {
+	Events []AuditEvent
+	// NextCursor is used to get the next page. Empty when there is no more event
+	NextCursor string
+}
+
+// auditCursor identifies the last returned event by its audit time and its id, which breaks the ties between events
+// stored at the same time
+type auditCursor struct {
+	auditTime time.Time
+	id        int64
+}
+
+func (c auditCursor) encode() string {
+	var value = c.auditTime.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(c.id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func decodeAuditCursor(value string) (auditCursor, error) {
+	var res auditCursor
+	var decoded, err = base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return res, ErrInvalidCursor
+	}
+	var parts = strings.Split(string(decoded), "|")
+	if len(parts) != 2 {
+		return res, ErrInvalidCursor
+	}
+	if res.auditTime, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return res, ErrInvalidCursor
+	}
+	if res.id, err = strconv.ParseInt(parts[1], 10, 64); err != nil || res.id < 0 {
+		return res, ErrInvalidCursor
+	}
+	return res, nil
+}
+
+// parseAuditTime parses audit_time as returned by the driver: DATETIME format, or RFC3339 when parseTime is enabled
+func parseAuditTime(value string) (time.Time, error) {
+	if res, err := time.Parse("2006-01-02 15:04:05.999999999", value); err == nil {
+		return res, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+type sqlFilter struct {
+	conditions []string
+	args       []any
+}
+
+func (f *sqlFilter) equals(column string, value string) {
+	if value != "" {
+		f.conditions = append(f.conditions, column+"=?")
+		f.args = append(f.args, value)
+	}
+}
+
+func (f *sqlFilter) in(column string, values []string) {
+	if len(values) > 0 {
+		f.conditions = append(f.conditions, column+" IN (?"+strings.Repeat(",?", len(values)-1)+")")
+		for _, value := range values {
+			f.args = append(f.args, value)
+		}
+	}
+}
+
+func (f *sqlFilter) compare(column string, operator string, value *time.Time) {
+	if value != nil {
+		f.conditions = append(f.conditions, column+operator+"?")
+		f.args = append(f.args, value.UTC().Format(AuditTimeFormat))
+	}
+}
+
+func (f *sqlFilter) where() string {
+	if len(f.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conditions, " AND ")
+}
+
+// after selects the events older than the cursor: (audit_time, id) < (cursor time, cursor id)
+func (f *sqlFilter) after(cursor auditCursor) {
+	var auditTime = cursor.auditTime.UTC().Format(AuditTimeFormat)
+	f.conditions = append(f.conditions, "(audit_time<? OR (audit_time=? AND id<?))")
+	f.args = append(f.args, auditTime, auditTime, cursor.id)
+}
+
+// Search returns the audit events matching the given query, from the most recent to the oldest one. Pagination relies on
+// (audit_time, id) (keyset pagination): pages remain consistent when new events are stored between two calls
+func (cm *eventsDBModule) Search(ctx context.Context, query AuditQuery) (AuditPage, error) {
+	var limit = query.Limit
+	if limit <= 0 {
+		limit = DefaultAuditSearchLimit
+	} else if limit > MaxAuditSearchLimit {
+		limit = MaxAuditSearchLimit
+	}
+
+	var filter sqlFilter
+	filter.equals(CtEventTargetRealmName, query.RealmName)
+	filter.equals(CtEventAgentUserID, query.AgentUserID)
+	filter.equals(CtEventAgentUsername, query.AgentUsername)
+	filter.equals(CtEventAgentRealmName, query.AgentRealmName)
+	filter.equals(CtEventTargetUserID, query.UserID)
+	filter.equals(CtEventTargetUsername, query.Username)
+	filter.in(CtEventType, query.CtEventTypes)
+	filter.in(CtEventKcEventType, query.KcEventTypes)
+	filter.equals(CtEventOrigin, query.Origin)
+	filter.compare(CtEventAuditTime, ">=", query.From)
+	filter.compare(CtEventAuditTime, "<", query.To)
+
+	if query.Cursor != "" {
+		var cursor, err = decodeAuditCursor(query.Cursor)
+		if err != nil {
+			return AuditPage{}, err
+		}
+		filter.after(cursor)
+	}
+
+	// One more event is requested to know if there is a next page
+	var stmt = selectEvents + filter.where() + " ORDER BY audit_time DESC, id DESC LIMIT ?"
+	var args = append(filter.args, limit+1)
+
+	rows, err := cm.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return AuditPage{}, err
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var event, err = scanAuditEvent(rows)
+		if err != nil {
+			return AuditPage{}, err
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return AuditPage{}, err
+	}
+
+	var page = AuditPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		var last = page.Events[limit-1]
+		page.NextCursor = auditCursor{auditTime: last.AuditTime, id: last.ID}.encode()
+	}
+	return page, nil
+}
+
+func scanAuditEvent(rows interface{ Scan(dest ...any) error }) (AuditEvent, error) {
+	var (
+		event          AuditEvent
+		auditTime      string
+		origin         sql.NullString
+		realmName      sql.NullString
+		agentUserID    sql.NullString
+		agentUsername  sql.NullString
+		agentRealmName sql.NullString
+		userID         sql.NullString
+		username       sql.NullString
+		ctEventType    sql.NullString
+		kcEventType    sql.NullString
+		kcOperation    sql.NullString
+		clientID       sql.NullString
+		additionalInfo sql.NullString
+	)
+	var err = rows.Scan(&event.ID, &auditTime, &origin, &realmName, &agentUserID, &agentUsername, &agentRealmName, &userID, &username,
+		&ctEventType, &kcEventType, &kcOperation, &clientID, &additionalInfo)
+	if err != nil {
+		return event, err
+	}
+	if event.AuditTime, err = parseAuditTime(auditTime); err != nil {
+		return event, err
+	}
+	event.Origin = origin.String
+	event.RealmName = realmName.String
+	event.AgentUserID = agentUserID.String
+	event.AgentUsername = agentUsername.String
+	event.AgentRealmName = agentRealmName.String
+	event.UserID = userID.String
+	event.Username = username.String
+	event.CtEventType = ctEventType.String
+	event.KcEventType = kcEventType.String
+	event.KcOperationType = kcOperation.String
+	event.ClientID = clientID.String
+	if additionalInfo.String != "" {
+		if err = json.Unmarshal([]byte(additionalInfo.String), &event.AdditionalInfo); err != nil {
+			return event, fmt.Errorf("can't decode additional_info: %w", err)
+		}
+	}
+	return event, nil
+}
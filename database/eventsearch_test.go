@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/dbtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func auditRow(id int64, auditTime string, ctEventType string, additionalInfo any) []any {
+	return []any{id, auditTime, "back-office", "realm", "agent-id", "agent", "master", "user-id", nil, ctEventType, nil, nil, nil, additionalInfo}
+}
+
+func TestAuditCursor(t *testing.T) {
+	var cursor = auditCursor{auditTime: time.Date(2024, 3, 1, 10, 0, 0, 123000000, time.UTC), id: 42}
+	decoded, err := decodeAuditCursor(cursor.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	for _, invalid := range []string{"!!", "bm9waXBl", "eHx5", "MjAyNC0wMy0wMVQxMDowMDowMFp8LTE"} {
+		_, err = decodeAuditCursor(invalid)
+		assert.Equal(t, ErrInvalidCursor, err, invalid)
+	}
+}
+
+func TestEventsDBModuleSearch(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	db.On("^SELECT .* FROM audit ORDER BY audit_time DESC, id DESC").WithRows(auditRow(7, "2024-03-01 10:00:00.500", "LOGIN", "{}"))
+
+	page, err := NewEventsDBModule(db).Search(context.TODO(), AuditQuery{})
+	assert.Nil(t, err)
+	assert.Len(t, page.Events, 1)
+	assert.Equal(t, int64(7), page.Events[0].ID)
+}
+
+func TestSearchEvents(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var searcher = NewAuditSearcher(db)
+	var ctx = context.TODO()
+	var from = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Filters", func(t *testing.T) {
+		db.On("^SELECT .* FROM audit WHERE").Once().WithRows(
+			auditRow(7, "2024-03-01 10:00:00.500", "LOGIN", `{"ip":"127.0.0.1"}`),
+		)
+		page, err := searcher.Search(ctx, AuditQuery{
+			RealmName:    "realm",
+			AgentUserID:  "agent-id",
+			UserID:       "user-id",
+			CtEventTypes: []string{"LOGIN", "LOGOUT"},
+			KcEventTypes: []string{"LOGIN_ERROR"},
+			Origin:       "back-office",
+			From:         &from,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "", page.NextCursor)
+		assert.Equal(t, []AuditEvent{{
+			ID:             7,
+			AuditTime:      time.Date(2024, 3, 1, 10, 0, 0, 500000000, time.UTC),
+			Origin:         "back-office",
+			RealmName:      "realm",
+			AgentUserID:    "agent-id",
+			AgentUsername:  "agent",
+			AgentRealmName: "master",
+			UserID:         "user-id",
+			CtEventType:    "LOGIN",
+			AdditionalInfo: map[string]string{"ip": "127.0.0.1"},
+		}}, page.Events)
+
+		var statement = db.Statements()[0]
+		assert.Contains(t, statement.Query, "WHERE realm_name=? AND agent_user_id=? AND user_id=? AND ct_event_type IN (?,?) AND kc_event_type IN (?) AND origin=? AND audit_time>=? ORDER BY audit_time DESC, id DESC LIMIT ?")
+		assert.Equal(t, []any{"realm", "agent-id", "user-id", "LOGIN", "LOGOUT", "LOGIN_ERROR", "back-office", "2024-03-01 00:00:00.000", 101}, statement.Args)
+	})
+	t.Run("Keyset pagination", func(t *testing.T) {
+		db.Reset()
+		db.On("FROM audit ORDER BY").Once().WithRows(
+			auditRow(12, "2024-03-01 10:00:03.000", "A", nil),
+			auditRow(11, "2024-03-01 10:00:02.000", "B", nil),
+			auditRow(10, "2024-03-01 10:00:02.000", "C", nil),
+		)
+		page, err := searcher.Search(ctx, AuditQuery{Limit: 2})
+		assert.Nil(t, err)
+		assert.Len(t, page.Events, 2)
+		assert.NotEqual(t, "", page.NextCursor)
+		assert.Equal(t, []any{3}, db.Statements()[0].Args)
+
+		// Second page: events older than the last one, or stored at the same time with a lower id
+		db.On(`WHERE \(audit_time<\? OR \(audit_time=\? AND id<\?\)\) ORDER BY audit_time DESC, id DESC LIMIT \?$`).Once().WithRows(
+			auditRow(10, "2024-03-01 10:00:02.000", "C", nil),
+			auditRow(9, "2024-03-01 10:00:02.000", "D", nil),
+		)
+		page, err = searcher.Search(ctx, AuditQuery{Limit: 1, Cursor: page.NextCursor})
+		assert.Nil(t, err)
+		assert.Len(t, page.Events, 1)
+		assert.Equal(t, "C", page.Events[0].CtEventType)
+		assert.Equal(t, []any{"2024-03-01 10:00:02.000", "2024-03-01 10:00:02.000", int64(11), 2}, db.Statements()[1].Args)
+
+		// Third page
+		db.On("WHERE \\(audit_time<").Once().WithRows(
+			auditRow(9, "2024-03-01 10:00:02.000", "D", nil),
+		)
+		page, err = searcher.Search(ctx, AuditQuery{Limit: 1, Cursor: page.NextCursor})
+		assert.Nil(t, err)
+		assert.Equal(t, "", page.NextCursor)
+		assert.Equal(t, []any{"2024-03-01 10:00:02.000", "2024-03-01 10:00:02.000", int64(10), 2}, db.Statements()[2].Args)
+	})
+	t.Run("Maximum limit", func(t *testing.T) {
+		db.Reset()
+		db.On("FROM audit").Once()
+		_, err := searcher.Search(ctx, AuditQuery{Limit: 5000})
+		assert.Nil(t, err)
+		assert.Equal(t, []any{MaxAuditSearchLimit + 1}, db.Statements()[0].Args)
+	})
+	t.Run("Invalid cursor", func(t *testing.T) {
+		_, err := searcher.Search(ctx, AuditQuery{Cursor: "invalid"})
+		assert.Equal(t, ErrInvalidCursor, err)
+	})
+	t.Run("Query fails", func(t *testing.T) {
+		var anyError = errors.New("any error")
+		db.On("FROM audit").Once().WithError(anyError)
+		_, err := searcher.Search(ctx, AuditQuery{})
+		assert.Equal(t, anyError, err)
+	})
+	t.Run("Invalid additional info", func(t *testing.T) {
+		db.On("FROM audit").Once().WithRows(auditRow(1, "2024-03-01 10:00:00.000", "A", "{not json"))
+		_, err := searcher.Search(ctx, AuditQuery{})
+		assert.NotNil(t, err)
+	})
+	t.Run("Invalid audit time", func(t *testing.T) {
+		db.On("FROM audit").Once().WithRows(auditRow(1, "yesterday", "A", nil))
+		_, err := searcher.Search(ctx, AuditQuery{})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,280 @@
+package database
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+)
+
+// The integrity mode requires two additional columns in the audit table and a table storing the head of the chain:
+//
+//	ALTER TABLE audit ADD COLUMN integrity_seq BIGINT NULL, ADD COLUMN integrity_hash CHAR(64) NULL,
+//	  ADD UNIQUE INDEX audit_integrity_seq_idx (integrity_seq);
+//	CREATE TABLE audit_integrity_head (id TINYINT NOT NULL PRIMARY KEY, integrity_seq BIGINT NOT NULL,
+//	  integrity_hash CHAR(64) NOT NULL);
+//
+// integrity_seq orders the chain and integrity_hash is the HMAC-SHA256 of the previous hash, the sequence number and the
+// canonical form of the event. audit_time must be stored with a millisecond precision (DATETIME(3)). The head is the
+// last link of the chain: it is updated with each event so that the deletion of the newest events can be detected
+const (
+	selectLastChainLink = `SELECT integrity_seq, integrity_hash FROM audit WHERE integrity_seq IS NOT NULL
+		ORDER BY integrity_seq DESC LIMIT 1 FOR UPDATE`
+	insertChainedEvent = `INSERT INTO audit (
+		audit_time,
+		origin,
+		realm_name,
+		agent_user_id,
+		agent_username,
+		agent_realm_name,
+		user_id,
+		username,
+		ct_event_type,
+		kc_event_type,
+		kc_operation_type,
+		client_id,
+		additional_info,
+		integrity_seq,
+		integrity_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	upsertChainHead = `INSERT INTO audit_integrity_head (id, integrity_seq, integrity_hash) VALUES (1, ?, ?)
+		ON DUPLICATE KEY UPDATE integrity_seq = VALUES(integrity_seq), integrity_hash = VALUES(integrity_hash)`
+	selectChainHead   = `SELECT integrity_seq, integrity_hash FROM audit_integrity_head WHERE id = 1`
+	selectChainBounds = `SELECT MIN(integrity_seq), MAX(integrity_seq) FROM audit
+		WHERE integrity_seq IS NOT NULL AND audit_time>=? AND audit_time<?`
+	selectChainLinkHash = `SELECT integrity_hash FROM audit WHERE integrity_seq=?`
+	selectChainedEvents = `SELECT audit_time, origin, realm_name, agent_user_id, agent_username, agent_realm_name, user_id, username,
+		ct_event_type, kc_event_type, kc_operation_type, client_id, additional_info, integrity_seq, integrity_hash
+		FROM audit WHERE integrity_seq BETWEEN ? AND ? ORDER BY integrity_seq`
+)
+
+// mysqlErrDuplicateEntry is returned when a unique index (integrity_seq) already contains the inserted value
+const mysqlErrDuplicateEntry uint16 = 1062
+
+// Reasons of a broken link in the audit events chain
+const (
+	BrokenLinkHashMismatch = "hash mismatch"
+	BrokenLinkMissingEvent = "missing event"
+	BrokenLinkHeadMismatch = "head mismatch"
+)
+
+// BrokenLink identifies the first event of the chain which can't be verified
+type BrokenLink struct {
+	Seq       int64
+	AuditTime time.Time
+	Reason    string
+}
+
+// ChainAnchor is a link of the chain known to be valid, usually the last event deleted by a purge. It allows the
+// verification of a chain whose first events have been deleted
+type ChainAnchor struct {
+	Seq  int64
+	Hash string
+}
+
+// ChainReport is the result of an audit events chain verification
+type ChainReport struct {
+	// Verified is the number of events whose link has been verified
+	Verified int64
+	// FirstBroken is nil when the whole chain is valid
+	FirstBroken *BrokenLink
+}
+
+// IntegrityEventsDBModule is an EventsDBModule chaining the stored events: each event is stored with an HMAC computed
+// over its canonical form and the hash of the previous event, so that any update or deletion can be detected
+type IntegrityEventsDBModule struct {
+	*eventsDBModule
+	key []byte
+}
+
+// NewIntegrityEventsDBModule creates an events module storing tamper-evident events. The key is the HMAC secret
+func NewIntegrityEventsDBModule(db sqltypes.CloudtrustDB, key []byte) *IntegrityEventsDBModule {
+	return &IntegrityEventsDBModule{
+		eventsDBModule: &eventsDBModule{db: db},
+		key:            key,
+	}
+}
+
+// Store inserts the event with its chain link. The last link is locked to serialize concurrent writers
+// When the chain is empty, there is no link to lock: concurrent writers compute the same sequence number and all but one
+// fail on the unique index of integrity_seq. They are retried and then chain their event to the inserted one
+func (im *IntegrityEventsDBModule) Store(ctx context.Context, m map[string]string) error {
+	var values, ok = auditValues(m)
+	if !ok {
+		return nil
+	}
+
+	var opts = TransactionOptions{RetryPolicy: DefaultRetryPolicy()}
+	opts.RetryPolicy.RetryableErrorCodes = append(opts.RetryPolicy.RetryableErrorCodes, mysqlErrDuplicateEntry)
+	return WithTransaction(ctx, im.db, &opts, func(tx sqltypes.Transaction) error {
+		var lastSeq int64
+		var lastHash string
+		var err = tx.QueryRowContext(ctx, selectLastChainLink).Scan(&lastSeq, &lastHash)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		var seq = lastSeq + 1
+		var hash = im.chainHash(lastHash, seq, values)
+		if _, err = tx.ExecContext(ctx, insertChainedEvent, append(values, seq, hash)...); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, upsertChainHead, seq, hash)
+		return err
+	})
+}
+
+// ReportEvent stores an event with its chain link
+func (im *IntegrityEventsDBModule) ReportEvent(ctx context.Context, apiCall string, origin string, values ...string) error {
+	event := CreateEvent(apiCall, origin)
+	event.AddAgentDetails(ctx)
+	event.AddEventValues(values...)
+	return im.Store(ctx, event.details)
+}
+
+// chainHash computes the link of an event. values are the audit columns in the order of insertEvent
+func (im *IntegrityEventsDBModule) chainHash(previousHash string, seq int64, values []any) string {
+	var canonical = make([]any, len(values))
+	copy(canonical, values)
+	// audit_time is canonicalized as it is read back from the database
+	if auditTime, ok := values[0].(string); ok {
+		if parsed, err := parseAuditTime(auditTime); err == nil {
+			canonical[0] = parsed.Format(AuditTimeFormat)
+		}
+	}
+	var canonicalBytes, _ = json.Marshal(canonical)
+
+	var mac = hmac.New(sha256.New, im.key)
+	mac.Write([]byte(previousHash))
+	mac.Write([]byte("|" + strconv.FormatInt(seq, 10) + "|"))
+	mac.Write(canonicalBytes)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyChain verifies the links of the events stored between from (inclusive) and to (exclusive) and reports the first
+// broken one. The event preceding the first one of the range must exist, unless it is the given anchor (nil if no event
+// has been purged). The last event of the range must be followed by another event or be the head of the chain
+func (im *IntegrityEventsDBModule) VerifyChain(ctx context.Context, from, to time.Time, anchor *ChainAnchor) (ChainReport, error) {
+	var report ChainReport
+
+	var minSeq, maxSeq sql.NullInt64
+	var err = im.db.QueryRowContext(ctx, selectChainBounds, from.UTC().Format(AuditTimeFormat), to.UTC().Format(AuditTimeFormat)).Scan(&minSeq, &maxSeq)
+	if err != nil || !minSeq.Valid {
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+		return report, err
+	}
+
+	// previousHash stays nil if the preceding event is missing
+	var previousHash *string
+	switch {
+	case minSeq.Int64 == 1:
+		var empty string
+		previousHash = &empty
+	case anchor != nil && anchor.Seq == minSeq.Int64-1:
+		previousHash = &anchor.Hash
+	default:
+		if previousHash, err = im.linkHash(ctx, minSeq.Int64-1); err != nil {
+			return report, err
+		}
+	}
+
+	rows, err := im.db.QueryContext(ctx, selectChainedEvents, minSeq.Int64, maxSeq.Int64)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	var expectedSeq = minSeq.Int64
+	var lastTime time.Time
+	for rows.Next() {
+		var auditTime string
+		var columns = make([]sql.NullString, 12)
+		var seq int64
+		var hash string
+		var dest = []any{&auditTime}
+		for i := range columns {
+			dest = append(dest, &columns[i])
+		}
+		dest = append(dest, &seq, &hash)
+		if err = rows.Scan(dest...); err != nil {
+			return report, err
+		}
+
+		var eventTime, _ = parseAuditTime(auditTime)
+		if previousHash == nil {
+			report.FirstBroken = &BrokenLink{Seq: seq - 1, AuditTime: eventTime, Reason: BrokenLinkMissingEvent}
+			return report, nil
+		}
+		if seq != expectedSeq {
+			report.FirstBroken = &BrokenLink{Seq: expectedSeq, AuditTime: eventTime, Reason: BrokenLinkMissingEvent}
+			return report, nil
+		}
+
+		var values = []any{auditTime}
+		for _, column := range columns {
+			if column.Valid {
+				values = append(values, column.String)
+			} else {
+				values = append(values, nil)
+			}
+		}
+		if !hmac.Equal([]byte(hash), []byte(im.chainHash(*previousHash, seq, values))) {
+			report.FirstBroken = &BrokenLink{Seq: seq, AuditTime: eventTime, Reason: BrokenLinkHashMismatch}
+			return report, nil
+		}
+		report.Verified++
+		previousHash = &hash
+		lastTime = eventTime
+		expectedSeq++
+	}
+	if err = rows.Err(); err != nil || previousHash == nil {
+		return report, err
+	}
+
+	report.FirstBroken, err = im.verifyTail(ctx, expectedSeq-1, *previousHash, lastTime)
+	return report, err
+}
+
+// verifyTail checks that the last verified event is followed by another one or is the head of the chain
+func (im *IntegrityEventsDBModule) verifyTail(ctx context.Context, lastSeq int64, lastHash string, lastTime time.Time) (*BrokenLink, error) {
+	var headSeq int64
+	var headHash string
+	var err = im.db.QueryRowContext(ctx, selectChainHead).Scan(&headSeq, &headHash)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	switch {
+	case lastSeq == headSeq && hmac.Equal([]byte(lastHash), []byte(headHash)):
+		return nil, nil
+	case lastSeq < headSeq:
+		var nextHash, err = im.linkHash(ctx, lastSeq+1)
+		if err != nil || nextHash != nil {
+			return nil, err
+		}
+		return &BrokenLink{Seq: lastSeq + 1, AuditTime: lastTime, Reason: BrokenLinkMissingEvent}, nil
+	default:
+		return &BrokenLink{Seq: lastSeq, AuditTime: lastTime, Reason: BrokenLinkHeadMismatch}, nil
+	}
+}
+
+// linkHash returns the hash of the event with the given sequence number, or nil if it does not exist
+func (im *IntegrityEventsDBModule) linkHash(ctx context.Context, seq int64) (*string, error) {
+	var hash string
+	switch err := im.db.QueryRowContext(ctx, selectChainLinkHash, seq).Scan(&hash); err {
+	case nil:
+		return &hash, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
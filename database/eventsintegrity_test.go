@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/dbtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// storeChainedEvents stores the given number of events and returns the inserted rows, as they would be read back
+func storeChainedEvents(t *testing.T, module *IntegrityEventsDBModule, db *dbtest.FakeDB, count int) [][]any {
+	var rows [][]any
+	for i := 0; i < count; i++ {
+		db.Reset()
+		if len(rows) > 0 {
+			var last = rows[len(rows)-1]
+			db.On("^SELECT integrity_seq, integrity_hash FROM audit").WithRows([]any{last[13], last[14]})
+		} else {
+			db.On("^SELECT integrity_seq, integrity_hash FROM audit")
+		}
+		db.On("^INSERT INTO audit \\(")
+		db.On("^INSERT INTO audit_integrity_head")
+
+		assert.Nil(t, module.Store(context.TODO(), map[string]string{
+			CtEventType:         "LOGIN",
+			CtEventOrigin:       "back-office",
+			CtEventAuditTime:    fmt.Sprintf("2024-03-01 10:00:%02d.000", i),
+			CtEventTargetUserID: "user-id",
+			"ip":                "127.0.0.1",
+		}))
+		var inserts = db.StatementsMatching("^INSERT INTO audit \\(")
+		assert.Len(t, inserts, 1)
+		assert.Equal(t, []any{inserts[0].Args[13], inserts[0].Args[14]}, db.StatementsMatching("^INSERT INTO audit_integrity_head")[0].Args)
+		assert.Equal(t, 1, db.Transactions()[0].ID())
+		assert.True(t, db.Transactions()[0].IsCommitted())
+		rows = append(rows, inserts[0].Args)
+	}
+	return rows
+}
+
+func TestIntegrityEventsDBModule(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var module = NewIntegrityEventsDBModule(db, []byte("secret"))
+	var ctx = context.TODO()
+	var from = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var to = from.Add(24 * time.Hour)
+
+	var rows = storeChainedEvents(t, module, db, 3)
+	var allRows = rows
+	assert.Equal(t, int64(1), rows[0][13])
+	assert.Equal(t, int64(3), rows[2][13])
+	assert.NotEqual(t, rows[0][14], rows[1][14])
+
+	// expectVerification defines the chain bounds from the given rows. The preceding and the following events exist when
+	// they are in allRows, the head is the given one
+	var expectVerification = func(head []any, rows ...[]any) {
+		var first, last = rows[0][13].(int64), rows[len(rows)-1][13].(int64)
+		db.Reset()
+		db.On("^SELECT MIN").WithArgs("2024-03-01 00:00:00.000", "2024-03-02 00:00:00.000").WithRows([]any{first, last})
+		for _, row := range allRows {
+			if seq := row[13].(int64); seq == first-1 || seq == last+1 {
+				db.On("^SELECT integrity_hash FROM audit WHERE integrity_seq=").WithArgs(seq).WithRows([]any{row[14]})
+			}
+		}
+		db.On("^SELECT integrity_hash FROM audit WHERE integrity_seq=")
+		db.On("WHERE integrity_seq BETWEEN").WithArgs(first, last).WithRows(rows...)
+		if head != nil {
+			db.On("^SELECT integrity_seq, integrity_hash FROM audit_integrity_head").WithRows(head)
+		} else {
+			db.On("^SELECT integrity_seq, integrity_hash FROM audit_integrity_head")
+		}
+	}
+	var tampered = func(row []any, column int, value any) []any {
+		var res = append([]any{}, row...)
+		res[column] = value
+		return res
+	}
+	var head = []any{rows[2][13], rows[2][14]}
+
+	t.Run("Valid chain", func(t *testing.T) {
+		expectVerification(head, rows...)
+		report, err := module.VerifyChain(ctx, from, to, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, ChainReport{Verified: 3}, report)
+	})
+	t.Run("Range followed by other events", func(t *testing.T) {
+		expectVerification(head, rows[:2]...)
+		report, err := module.VerifyChain(ctx, from, to, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, ChainReport{Verified: 2}, report)
+	})
+	t.Run("Purged events with anchor", func(t *testing.T) {
+		allRows = rows[1:]
+		defer func() { allRows = rows }()
+		expectVerification(head, rows[1:]...)
+		report, err := module.VerifyChain(ctx, from, to, &ChainAnchor{Seq: 1, Hash: rows[0][14].(string)})
+		assert.Nil(t, err)
+		assert.Equal(t, ChainReport{Verified: 2}, report)
+	})
+	t.Run("Head of the chain deleted", func(t *testing.T) {
+		allRows = rows[1:]
+		defer func() { allRows = rows }()
+		expectVerification(head, rows[1:]...)
+		report, err := module.VerifyChain(ctx, from, to, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), report.Verified)
+		assert.Equal(t, &BrokenLink{Seq: 1, AuditTime: time.Date(2024, 3, 1, 10, 0, 1, 0, time.UTC), Reason: BrokenLinkMissingEvent}, report.FirstBroken)
+
+		// The anchor must be the preceding event
+		allRows = rows[2:]
+		expectVerification(head, rows[2:]...)
+		report, err = module.VerifyChain(ctx, from, to, &ChainAnchor{Seq: 1, Hash: rows[0][14].(string)})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), report.FirstBroken.Seq)
+		assert.Equal(t, BrokenLinkMissingEvent, report.FirstBroken.Reason)
+	})
+	t.Run("Newest events deleted", func(t *testing.T) {
+		allRows = rows[:1]
+		defer func() { allRows = rows }()
+		expectVerification(head, rows[:1]...)
+		report, err := module.VerifyChain(ctx, from, to, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), report.Verified)
+		assert.Equal(t, &BrokenLink{Seq: 2, AuditTime: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), Reason: BrokenLinkMissingEvent}, report.FirstBroken)
+	})
+	t.Run("Head does not match", func(t *testing.T) {
+		for _, head := range [][]any{nil, {rows[1][13], rows[1][14]}, {rows[2][13], rows[1][14]}} {
+			expectVerification(head, rows...)
+			report, err := module.VerifyChain(ctx, from, to, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(3), report.Verified)
+			assert.Equal(t, &BrokenLink{Seq: 3, AuditTime: time.Date(2024, 3, 1, 10, 0, 2, 0, time.UTC), Reason: BrokenLinkHeadMismatch}, report.FirstBroken)
+		}
+	})
+	t.Run("Updated event", func(t *testing.T) {
+		expectVerification(head, rows[0], tampered(rows[1], 6, "other-user"), rows[2])
+		report, err := module.VerifyChain(ctx, from, to, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), report.Verified)
+		assert.Equal(t, int64(2), report.FirstBroken.Seq)
+		assert.Equal(t, BrokenLinkHashMismatch, report.FirstBroken.Reason)
+		assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 1, 0, time.UTC), report.FirstBroken.AuditTime)
+	})
+	t.Run("Deleted event", func(t *testing.T) {
+		expectVerification(head, rows[0], rows[2])
+		report, err := module.VerifyChain(ctx, from, to, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, BrokenLinkMissingEvent, report.FirstBroken.Reason)
+		assert.Equal(t, int64(2), report.FirstBroken.Seq)
+	})
+	t.Run("Wrong key", func(t *testing.T) {
+		expectVerification(head, rows...)
+		report, err := NewIntegrityEventsDBModule(db, []byte("other")).VerifyChain(ctx, from, to, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), report.FirstBroken.Seq)
+	})
+	t.Run("No chained event in range", func(t *testing.T) {
+		db.Reset()
+		db.On("^SELECT MIN").WithRows([]any{nil, nil})
+		report, err := module.VerifyChain(ctx, from, to, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, ChainReport{}, report)
+	})
+	t.Run("Concurrent first insert is retried", func(t *testing.T) {
+		db.Reset()
+		// Empty chain, then the event inserted by a concurrent writer
+		db.On("^SELECT integrity_seq, integrity_hash FROM audit").Once()
+		db.On("^SELECT integrity_seq, integrity_hash FROM audit").Once().WithRows([]any{rows[0][13], rows[0][14]})
+		db.On("^INSERT INTO audit \\(").Once().WithError(errors.New("Error 1062 (23000): Duplicate entry '1' for key 'audit_integrity_seq_idx'"))
+		db.On("^INSERT INTO audit \\(").Once()
+		db.On("^INSERT INTO audit_integrity_head")
+
+		assert.Nil(t, module.ReportEvent(ctx, "LOGIN", "back-office"))
+		var inserts = db.StatementsMatching("^INSERT INTO audit \\(")
+		assert.Len(t, inserts, 2)
+		assert.Equal(t, int64(1), inserts[0].Args[13])
+		assert.Equal(t, int64(2), inserts[1].Args[13])
+		assert.True(t, db.Transactions()[0].IsRolledBack())
+		assert.True(t, db.Transactions()[1].IsCommitted())
+	})
+	t.Run("Query fails", func(t *testing.T) {
+		var anyError = errors.New("any error")
+		db.Reset()
+		db.On("^SELECT MIN").WithError(anyError)
+		_, err := module.VerifyChain(ctx, from, to, nil)
+		assert.Equal(t, anyError, err)
+
+		db.On("^SELECT integrity_seq").WithError(anyError)
+		assert.Equal(t, anyError, module.ReportEvent(ctx, "LOGIN", "back-office"))
+	})
+}
@@ -0,0 +1,285 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+)
+
+// MySQL errors which don't prevent the same events from being inserted later
+const (
+	mysqlErrTooManyConnections uint16 = 1040
+	mysqlErrServerShutdown     uint16 = 1053
+	mysqlErrQueryInterrupted   uint16 = 1317
+)
+
+var (
+	// ErrEventsWriterClosed is returned when events are stored or flushed once the buffered writer is closed
+	ErrEventsWriterClosed = errors.New("audit events writer is closed")
+
+	transientInsertErrorCodes = []uint16{MySQLErrLockWaitTimeout, MySQLErrLockDeadlock, mysqlErrTooManyConnections,
+		mysqlErrServerShutdown, mysqlErrQueryInterrupted}
+)
+
+// BufferOptions configures a buffered audit events writer
+type BufferOptions struct {
+	// BatchSize is the maximum number of events written with a single INSERT. Defaults to 100
+	BatchSize int
+	// FlushInterval is the maximum time an event stays in the buffer. Defaults to 1 second
+	FlushInterval time.Duration
+	// BufferSize is the number of events which can be stored before Store blocks. Defaults to 10 times BatchSize
+	BufferSize int
+}
+
+type flushRequest struct {
+	ctx    context.Context
+	stop   bool
+	result chan error
+}
+
+// BufferedEventsDBModule is an EventsDBModule storing events asynchronously: events are buffered and written with
+// multi-row INSERT statements when a batch is full or when the flush interval elapses. When the buffer is full, Store
+// blocks until events are written, until its context is done or until the writer is closed
+// Events which can't be written because of the database availability are kept and written later. Events rejected by the
+// database (unknown column, value too long, ...) are dropped and logged as errors
+type BufferedEventsDBModule struct {
+	*eventsDBModule
+	options  BufferOptions
+	logger   log.Logger
+	events   chan []any
+	requests chan flushRequest
+	done     chan struct{}
+	// closing is closed by Close: the pending Store calls return without waiting for space in the buffer
+	closing   chan struct{}
+	closeOnce sync.Once
+	// stored is closed once the writer is closing and no Store call is running anymore. The mutex protects the counter of
+	// running Store calls and is never held while waiting
+	stored  chan struct{}
+	mutex   sync.Mutex
+	closed  bool
+	storing int
+}
+
+// NewBufferedEventsDBModule creates a buffered audit events writer. Close must be called on shutdown to write the
+// buffered events
+func NewBufferedEventsDBModule(db sqltypes.CloudtrustDB, options BufferOptions, logger log.Logger) *BufferedEventsDBModule {
+	if options.BatchSize <= 0 {
+		options.BatchSize = 100
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = time.Second
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = 10 * options.BatchSize
+	}
+	var module = &BufferedEventsDBModule{
+		eventsDBModule: &eventsDBModule{db: db},
+		options:        options,
+		logger:         logger,
+		events:         make(chan []any, options.BufferSize),
+		requests:       make(chan flushRequest),
+		done:           make(chan struct{}),
+		closing:        make(chan struct{}),
+		stored:         make(chan struct{}),
+	}
+	go module.writeLoop()
+	return module
+}
+
+// Store adds an event to the buffer
+func (bm *BufferedEventsDBModule) Store(ctx context.Context, m map[string]string) error {
+	var values, ok = auditValues(m)
+	if !ok {
+		return nil
+	}
+
+	if !bm.beginStore() {
+		return ErrEventsWriterClosed
+	}
+	defer bm.endStore()
+
+	select {
+	case bm.events <- values:
+		return nil
+	case <-bm.closing:
+		return ErrEventsWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (bm *BufferedEventsDBModule) beginStore() bool {
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+	if bm.closed {
+		return false
+	}
+	bm.storing++
+	return true
+}
+
+func (bm *BufferedEventsDBModule) endStore() {
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+	bm.storing--
+	if bm.closed && bm.storing == 0 {
+		close(bm.stored)
+	}
+}
+
+// ReportEvent adds an event to the buffer
+func (bm *BufferedEventsDBModule) ReportEvent(ctx context.Context, apiCall string, origin string, values ...string) error {
+	event := CreateEvent(apiCall, origin)
+	event.AddAgentDetails(ctx)
+	event.AddEventValues(values...)
+	return bm.Store(ctx, event.details)
+}
+
+// Flush writes all the events stored before the call
+func (bm *BufferedEventsDBModule) Flush(ctx context.Context) error {
+	return bm.request(ctx, false)
+}
+
+// Close stops accepting events, writes the buffered ones and stops the writer. If the events can't be written, the
+// writer keeps running and Close can be called again
+func (bm *BufferedEventsDBModule) Close(ctx context.Context) error {
+	bm.closeOnce.Do(func() {
+		bm.mutex.Lock()
+		defer bm.mutex.Unlock()
+		bm.closed = true
+		close(bm.closing)
+		if bm.storing == 0 {
+			close(bm.stored)
+		}
+	})
+	// Waits for the running Store calls: they return as soon as their event is buffered or rejected
+	select {
+	case <-bm.stored:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var err = bm.request(ctx, true)
+	if err == ErrEventsWriterClosed {
+		return nil
+	}
+	return err
+}
+
+func (bm *BufferedEventsDBModule) request(ctx context.Context, stop bool) error {
+	var req = flushRequest{ctx: ctx, stop: stop, result: make(chan error, 1)}
+	select {
+	case bm.requests <- req:
+	case <-bm.done:
+		return ErrEventsWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (bm *BufferedEventsDBModule) writeLoop() {
+	defer close(bm.done)
+
+	var ticker = time.NewTicker(bm.options.FlushInterval)
+	defer ticker.Stop()
+
+	var pending = make([][]any, 0, bm.options.BatchSize)
+	for {
+		// Stop reading events while a full batch can't be written: the buffer fills up and Store applies backpressure
+		var input = bm.events
+		if len(pending) >= bm.options.BatchSize {
+			input = nil
+		}
+
+		select {
+		case values := <-input:
+			pending = append(pending, values)
+			if len(pending) >= bm.options.BatchSize {
+				_ = bm.write(context.Background(), &pending)
+			}
+		case <-ticker.C:
+			_ = bm.write(context.Background(), &pending)
+		case req := <-bm.requests:
+			var err = bm.drain(req.ctx, &pending)
+			req.result <- err
+			if req.stop && err == nil {
+				return
+			}
+		}
+	}
+}
+
+// drain writes the pending events and the ones available in the buffer
+func (bm *BufferedEventsDBModule) drain(ctx context.Context, pending *[][]any) error {
+	for {
+		select {
+		case values := <-bm.events:
+			*pending = append(*pending, values)
+			if len(*pending) >= bm.options.BatchSize {
+				if err := bm.write(ctx, pending); err != nil {
+					return err
+				}
+			}
+		default:
+			return bm.write(ctx, pending)
+		}
+	}
+}
+
+// write inserts the pending events by batches. Events are kept to be written later if the insertion fails because of a
+// transient error. When a batch is rejected by the database, its events are written one by one and the rejected ones
+// are dropped
+func (bm *BufferedEventsDBModule) write(ctx context.Context, pending *[][]any) error {
+	var oneByOne = 0
+	for len(*pending) > 0 {
+		var count = min(len(*pending), bm.options.BatchSize)
+		if oneByOne > 0 {
+			count = 1
+			oneByOne--
+		}
+
+		var err = bm.insert(ctx, (*pending)[:count])
+		switch {
+		case err == nil:
+		case !isPermanentInsertError(err):
+			bm.logger.Error(ctx, "msg", "Can't store audit events", "count", len(*pending), "err", err.Error())
+			return err
+		case count > 1:
+			oneByOne = count
+			continue
+		default:
+			bm.logger.Error(ctx, "msg", "Audit event rejected by the database, it is dropped", "event", (*pending)[0], "err", err.Error())
+		}
+		*pending = append((*pending)[:0], (*pending)[count:]...)
+	}
+	return nil
+}
+
+func (bm *BufferedEventsDBModule) insert(ctx context.Context, batch [][]any) error {
+	var args = make([]any, 0, len(batch)*len(batch[0]))
+	for _, values := range batch {
+		args = append(args, values...)
+	}
+	var stmt = insertEventHeader + insertEventValues + strings.Repeat(", "+insertEventValues, len(batch)-1)
+	var _, err = bm.db.ExecContext(ctx, stmt, args...)
+	return err
+}
+
+// isPermanentInsertError tells if inserting the same events again would fail: the database rejected them (unknown
+// column, value too long, ...). Errors without MySQL error code (connection lost, open circuit, ...) are transient
+func isPermanentInsertError(err error) bool {
+	var code, ok = MySQLErrorCode(err)
+	return ok && !slices.Contains(transientInsertErrorCodes, code)
+}
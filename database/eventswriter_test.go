@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/dbtest"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const columnsPerEvent = 13
+
+func TestBufferedEventsDBModule(t *testing.T) {
+	var ctx = context.TODO()
+	var event = map[string]string{CtEventType: "LOGIN", CtEventOrigin: "back-office", CtEventAuditTime: "2024-03-01 10:00:00.000"}
+
+	t.Run("Events are grouped by batches", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		db.On("^INSERT INTO audit")
+		var module = NewBufferedEventsDBModule(db, BufferOptions{BatchSize: 2, FlushInterval: time.Hour}, log.NewNopLogger())
+
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, module.Store(ctx, event))
+		}
+		assert.Eventually(t, func() bool { return len(db.Statements()) == 1 }, time.Second, time.Millisecond)
+		assert.Len(t, db.Statements()[0].Args, 2*columnsPerEvent)
+
+		assert.Nil(t, module.Flush(ctx))
+		assert.Len(t, db.Statements(), 2)
+		assert.Len(t, db.Statements()[1].Args, columnsPerEvent)
+		assert.Equal(t, "LOGIN", db.Statements()[1].Args[8])
+
+		assert.Nil(t, module.Close(ctx))
+	})
+	t.Run("Events are written after the flush interval", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		db.On("^INSERT INTO audit")
+		var module = NewBufferedEventsDBModule(db, BufferOptions{FlushInterval: 5 * time.Millisecond}, log.NewNopLogger())
+		defer module.Close(ctx)
+
+		assert.Nil(t, module.ReportEvent(ctx, "LOGOUT", "back-office", "ip", "127.0.0.1"))
+		// Events without ct_event_type are ignored
+		assert.Nil(t, module.Store(ctx, map[string]string{CtEventOrigin: "back-office"}))
+
+		assert.Eventually(t, func() bool { return len(db.Statements()) == 1 }, time.Second, time.Millisecond)
+		assert.Len(t, db.Statements()[0].Args, columnsPerEvent)
+	})
+	t.Run("Backpressure when events can't be written", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		db.On("^INSERT INTO audit").WithError(errors.New("database unavailable"))
+		var module = NewBufferedEventsDBModule(db, BufferOptions{BatchSize: 1, BufferSize: 1, FlushInterval: time.Hour}, log.NewNopLogger())
+
+		assert.Nil(t, module.Store(ctx, event))
+		assert.Nil(t, module.Store(ctx, event))
+
+		var timeoutCtx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, module.Store(timeoutCtx, event))
+		assert.NotNil(t, module.Close(ctx))
+
+		// Once the database is back, closing the writer stores the buffered events
+		db.Reset()
+		db.On("^INSERT INTO audit")
+		assert.Nil(t, module.Close(ctx))
+		assert.Len(t, db.Statements(), 2)
+	})
+	t.Run("Close doesn't wait for the Store calls blocked on a full buffer", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		db.On("^INSERT INTO audit").WithError(errors.New("database unavailable"))
+		var module = NewBufferedEventsDBModule(db, BufferOptions{BatchSize: 1, BufferSize: 1, FlushInterval: time.Hour}, log.NewNopLogger())
+
+		assert.Nil(t, module.Store(ctx, event))
+		assert.Nil(t, module.Store(ctx, event))
+		var stored = make(chan error)
+		go func() { stored <- module.Store(context.Background(), event) }()
+		assert.Eventually(t, func() bool {
+			module.mutex.Lock()
+			defer module.mutex.Unlock()
+			return module.storing == 1
+		}, time.Second, time.Millisecond)
+
+		var timeoutCtx, cancel = context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		assert.NotNil(t, module.Close(timeoutCtx))
+		assert.Equal(t, ErrEventsWriterClosed, <-stored)
+	})
+	t.Run("Events rejected by the database are dropped", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		var rejected = map[string]string{CtEventType: "LOGIN", CtEventTargetUsername: "too-long", CtEventAuditTime: "2024-03-01 10:00:00.000"}
+		var rejectedValues, _ = auditValues(rejected)
+		var tooLong = errors.New("Error 1406 (22001): Data too long for column 'username' at row 2")
+		db.On("^INSERT INTO audit").WithArgs(rejectedValues...).WithError(tooLong)
+		db.On(`^INSERT INTO audit .*\), \(`).WithError(tooLong)
+		db.On("^INSERT INTO audit")
+		var module = NewBufferedEventsDBModule(db, BufferOptions{BatchSize: 3, FlushInterval: time.Hour}, log.NewNopLogger())
+		defer module.Close(ctx)
+
+		assert.Nil(t, module.Store(ctx, event))
+		assert.Nil(t, module.Store(ctx, rejected))
+		assert.Nil(t, module.Store(ctx, event))
+		assert.Nil(t, module.Flush(ctx))
+
+		// The batch, then each event
+		var statements = db.Statements()
+		assert.Len(t, statements, 4)
+		assert.Len(t, statements[0].Args, 3*columnsPerEvent)
+		for _, statement := range statements[1:] {
+			assert.Len(t, statement.Args, columnsPerEvent)
+		}
+		assert.Nil(t, module.Flush(ctx))
+		assert.Len(t, db.Statements(), 4)
+	})
+	t.Run("Closed writer", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		var module = NewBufferedEventsDBModule(db, BufferOptions{}, log.NewNopLogger())
+
+		assert.Nil(t, module.Close(ctx))
+		assert.Nil(t, module.Close(ctx))
+		assert.Equal(t, ErrEventsWriterClosed, module.Store(ctx, event))
+		assert.Equal(t, ErrEventsWriterClosed, module.Flush(ctx))
+		assert.Len(t, db.Statements(), 0)
+	})
+	t.Run("Search is not buffered", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		db.On("FROM audit")
+		var module AuditSearcher = NewBufferedEventsDBModule(db, BufferOptions{}, log.NewNopLogger())
+		defer module.(*BufferedEventsDBModule).Close(ctx)
+
+		page, err := module.Search(ctx, AuditQuery{})
+		assert.Nil(t, err)
+		assert.Len(t, page.Events, 0)
+	})
+}
+
+func TestIsPermanentInsertError(t *testing.T) {
+	assert.False(t, isPermanentInsertError(errors.New("database unavailable")))
+	assert.False(t, isPermanentInsertError(ErrCircuitOpen))
+	assert.False(t, isPermanentInsertError(errors.New("Error 1213 (40001): Deadlock found when trying to get lock")))
+	assert.False(t, isPermanentInsertError(errors.New("Error 1040: Too many connections")))
+	assert.True(t, isPermanentInsertError(errors.New("Error 1054 (42S22): Unknown column 'x' in 'field list'")))
+	assert.True(t, isPermanentInsertError(errors.New("Error 1406 (22001): Data too long for column 'username' at row 1")))
+}
@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/cloudtrust/common-service/v2/metrics"
+	"github.com/cloudtrust/common-service/v2/tracing"
+)
+
+const (
+	defaultQueryHistogramName = "db_query_duration"
+	defaultQuerySpanName      = "db_query"
+	otherStatementLabel       = "other"
+)
+
+// sqlCommands are the SQL commands used as statement label when the statement is not named
+var sqlCommands = map[string]bool{
+	"select": true, "insert": true, "update": true, "delete": true, "replace": true, "call": true, "commit": true,
+	"with": true, "create": true, "alter": true, "drop": true, "truncate": true, "set": true,
+}
+
+type statementNameKey struct{}
+
+// WithStatementName returns a context naming the statements executed with it. The name is used as the "statement" label
+// of the histogram and must come from a bounded set (e.g. "insert_audit"). Statements which are not named are labelled
+// with their SQL command (select, insert, ...)
+func WithStatementName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, statementNameKey{}, name)
+}
+
+// statementLabel returns the bounded "statement" label of a normalized statement
+func statementLabel(ctx context.Context, statement string) string {
+	if name, ok := ctx.Value(statementNameKey{}).(string); ok && name != "" {
+		return name
+	}
+	var command, _, _ = strings.Cut(statement, " ")
+	command = strings.ToLower(command)
+	if sqlCommands[command] {
+		return command
+	}
+	return otherStatementLabel
+}
+
+// InstrumentationOptions configures an instrumented database
+type InstrumentationOptions struct {
+	// Name of the database, used as the "database" label of the histogram and in the slow-query log
+	Name string
+	// HistogramName is the name of the histogram recording the statement durations in seconds, labelled by database,
+	// operation and statement name (see WithStatementName). Defaults to db_query_duration
+	HistogramName string
+	// SpanName is the operation name of the tracing spans. Defaults to db_query
+	SpanName string
+	// SlowQueryThreshold: statements running longer are logged as warnings. Zero disables the slow-query log
+	SlowQueryThreshold time.Duration
+}
+
+type instrumentation struct {
+	histogram metrics.Histogram
+	tracer    tracing.OpentracingClient
+	logger    log.Logger
+	options   InstrumentationOptions
+}
+
+// InstrumentedDB is a CloudtrustDB decorator measuring the duration of each statement, tracing them and logging the slow
+// ones. Transactions created with BeginTx are instrumented too
+type InstrumentedDB struct {
+	db sqltypes.CloudtrustDB
+	*instrumentation
+}
+
+type instrumentedTransaction struct {
+	tx sqltypes.Transaction
+	*instrumentation
+}
+
+// NewInstrumentedDB creates an instrumented decorator around the given database
+func NewInstrumentedDB(db sqltypes.CloudtrustDB, m metrics.Metrics, tracer tracing.OpentracingClient, logger log.Logger, opts InstrumentationOptions) *InstrumentedDB {
+	if opts.HistogramName == "" {
+		opts.HistogramName = defaultQueryHistogramName
+	}
+	if opts.SpanName == "" {
+		opts.SpanName = defaultQuerySpanName
+	}
+	return &InstrumentedDB{
+		db: db,
+		instrumentation: &instrumentation{
+			histogram: m.NewHistogram(opts.HistogramName),
+			tracer:    tracer,
+			logger:    logger,
+			options:   opts,
+		},
+	}
+}
+
+// normalizeStatement removes the indentation and line breaks of a statement
+func normalizeStatement(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// measure starts a span for the given statement and returns the context to use for the call and the function to invoke
+// once the statement has been executed. The full statement is only kept on the span and in the slow-query log
+func (i *instrumentation) measure(ctx context.Context, operation string, query string) (context.Context, func()) {
+	var statement = normalizeStatement(query)
+	var spanCtx, finisher = i.tracer.TryStartSpanWithTag(ctx, i.options.SpanName, "statement", statement)
+	var start = time.Now()
+
+	return spanCtx, func() {
+		var duration = time.Since(start)
+		if finisher != nil {
+			finisher.Finish()
+		}
+		i.histogram.With("database", i.options.Name, "operation", operation, "statement", statementLabel(ctx, statement)).Observe(duration.Seconds())
+		if i.options.SlowQueryThreshold > 0 && duration >= i.options.SlowQueryThreshold {
+			i.logger.Warn(ctx, "msg", "Slow database query", "database", i.options.Name, "operation", operation,
+				"statement", statement, "duration_ms", duration.Milliseconds())
+		}
+	}
+}
+
+// Unwrap returns the decorated database
+func (idb *InstrumentedDB) Unwrap() sqltypes.CloudtrustDB {
+	return idb.db
+}
+
+// BeginTx creates an instrumented transaction
+func (idb *InstrumentedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (sqltypes.Transaction, error) {
+	var tx, err = idb.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedTransaction{tx: tx, instrumentation: idb.instrumentation}, nil
+}
+
+// Exec an SQL query
+func (idb *InstrumentedDB) Exec(query string, args ...any) (sql.Result, error) {
+	var _, done = idb.measure(context.Background(), "exec", query)
+	defer done()
+	return idb.db.Exec(query, args...)
+}
+
+// Query a multiple-rows SQL result
+func (idb *InstrumentedDB) Query(query string, args ...any) (sqltypes.SQLRows, error) {
+	var _, done = idb.measure(context.Background(), "query", query)
+	defer done()
+	return idb.db.Query(query, args...)
+}
+
+// QueryRow a single-row SQL result
+func (idb *InstrumentedDB) QueryRow(query string, args ...any) sqltypes.SQLRow {
+	var _, done = idb.measure(context.Background(), "query_row", query)
+	defer done()
+	return idb.db.QueryRow(query, args...)
+}
+
+// ExecContext executes an SQL query using the given context
+func (idb *InstrumentedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var spanCtx, done = idb.measure(ctx, "exec", query)
+	defer done()
+	return idb.db.ExecContext(spanCtx, query, args...)
+}
+
+// QueryContext queries a multiple-rows SQL result using the given context
+func (idb *InstrumentedDB) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	var spanCtx, done = idb.measure(ctx, "query", query)
+	defer done()
+	return idb.db.QueryContext(spanCtx, query, args...)
+}
+
+// QueryRowContext queries a single-row SQL result using the given context
+func (idb *InstrumentedDB) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	var spanCtx, done = idb.measure(ctx, "query_row", query)
+	defer done()
+	return idb.db.QueryRowContext(spanCtx, query, args...)
+}
+
+// Ping check the connection with the database
+func (idb *InstrumentedDB) Ping() error {
+	return idb.db.Ping()
+}
+
+// PingContext check the connection with the database using the given context
+func (idb *InstrumentedDB) PingContext(ctx context.Context) error {
+	return idb.db.PingContext(ctx)
+}
+
+// Close the connection with the database
+func (idb *InstrumentedDB) Close() error {
+	return idb.db.Close()
+}
+
+// Stats returns database statistics
+func (idb *InstrumentedDB) Stats() sql.DBStats {
+	return idb.db.Stats()
+}
+
+func (itx *instrumentedTransaction) Commit() error {
+	var _, done = itx.measure(context.Background(), "commit", "COMMIT")
+	defer done()
+	return itx.tx.Commit()
+}
+
+func (itx *instrumentedTransaction) Rollback() error {
+	return itx.tx.Rollback()
+}
+
+func (itx *instrumentedTransaction) Close() error {
+	return itx.tx.Close()
+}
+
+func (itx *instrumentedTransaction) Exec(query string, args ...any) (sql.Result, error) {
+	var _, done = itx.measure(context.Background(), "exec", query)
+	defer done()
+	return itx.tx.Exec(query, args...)
+}
+
+func (itx *instrumentedTransaction) Query(query string, args ...any) (sqltypes.SQLRows, error) {
+	var _, done = itx.measure(context.Background(), "query", query)
+	defer done()
+	return itx.tx.Query(query, args...)
+}
+
+func (itx *instrumentedTransaction) QueryRow(query string, args ...any) sqltypes.SQLRow {
+	var _, done = itx.measure(context.Background(), "query_row", query)
+	defer done()
+	return itx.tx.QueryRow(query, args...)
+}
+
+func (itx *instrumentedTransaction) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var spanCtx, done = itx.measure(ctx, "exec", query)
+	defer done()
+	return itx.tx.ExecContext(spanCtx, query, args...)
+}
+
+func (itx *instrumentedTransaction) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	var spanCtx, done = itx.measure(ctx, "query", query)
+	defer done()
+	return itx.tx.QueryContext(spanCtx, query, args...)
+}
+
+func (itx *instrumentedTransaction) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	var spanCtx, done = itx.measure(ctx, "query_row", query)
+	defer done()
+	return itx.tx.QueryRowContext(spanCtx, query, args...)
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	cs "github.com/cloudtrust/common-service/v2"
+	"github.com/cloudtrust/common-service/v2/database/mock"
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestNormalizeStatement(t *testing.T) {
+	assert.Equal(t, "SELECT a, b FROM t WHERE id=?", normalizeStatement("\n\t\tSELECT a, b\n\t\tFROM t\n\t\tWHERE id=?\n\t"))
+}
+
+func TestStatementLabel(t *testing.T) {
+	var ctx = context.TODO()
+	assert.Equal(t, "insert", statementLabel(ctx, "INSERT INTO t VALUES (?)"))
+	assert.Equal(t, "other", statementLabel(ctx, "SHOW TABLES"))
+	assert.Equal(t, "other", statementLabel(ctx, ""))
+	assert.Equal(t, "insert_audit", statementLabel(WithStatementName(ctx, "insert_audit"), "INSERT INTO t VALUES (?)"))
+}
+
+func TestInstrumentedDB(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockDB = mock.NewCloudtrustDB(mockCtrl)
+	var mockTx = mock.NewTransaction(mockCtrl)
+	var mockMetrics = mock.NewMetrics(mockCtrl)
+	var mockHistogram = mock.NewHistogram(mockCtrl)
+	var mockTracer = mock.NewOpentracingClient(mockCtrl)
+	var mockFinisher = mock.NewFinisher(mockCtrl)
+	var mockLogger = mock.NewLogger(mockCtrl)
+
+	var ctx = context.WithValue(context.TODO(), cs.CtContextCorrelationID, "corr-id")
+	var spanCtx = context.WithValue(ctx, cs.CtContextRealmID, "span")
+	var query = "SELECT col\n\tFROM table"
+	var statement = "SELECT col FROM table"
+	var anyError = errors.New("any error")
+	var sqlRow = sqltypes.NewSQLRowError(anyError)
+
+	var expectMeasure = func(ctx context.Context, spanCtx context.Context, operation string, statement string, label string) {
+		mockTracer.EXPECT().TryStartSpanWithTag(ctx, "db_query", "statement", statement).Return(spanCtx, mockFinisher)
+		mockFinisher.EXPECT().Finish()
+		mockHistogram.EXPECT().With("database", "audit", "operation", operation, "statement", label).Return(mockHistogram)
+		mockHistogram.EXPECT().Observe(gomock.Any())
+	}
+
+	mockMetrics.EXPECT().NewHistogram("db_query_duration").Return(mockHistogram)
+	var db = NewInstrumentedDB(mockDB, mockMetrics, mockTracer, mockLogger, InstrumentationOptions{Name: "audit"})
+	assert.Equal(t, mockDB, db.Unwrap())
+
+	t.Run("ExecContext", func(t *testing.T) {
+		expectMeasure(ctx, spanCtx, "exec", statement, "select")
+		mockDB.EXPECT().ExecContext(spanCtx, query, 1).Return(nil, anyError)
+		_, err := db.ExecContext(ctx, query, 1)
+		assert.Equal(t, anyError, err)
+	})
+	t.Run("QueryContext", func(t *testing.T) {
+		expectMeasure(ctx, spanCtx, "query", statement, "select")
+		mockDB.EXPECT().QueryContext(spanCtx, query).Return(nil, nil)
+		_, err := db.QueryContext(ctx, query)
+		assert.Nil(t, err)
+	})
+	t.Run("QueryRowContext", func(t *testing.T) {
+		expectMeasure(ctx, spanCtx, "query_row", statement, "select")
+		mockDB.EXPECT().QueryRowContext(spanCtx, query).Return(sqlRow)
+		assert.Equal(t, sqlRow, db.QueryRowContext(ctx, query))
+	})
+	t.Run("Without context, no parent span", func(t *testing.T) {
+		for _, operation := range []string{"exec", "query", "query_row"} {
+			mockTracer.EXPECT().TryStartSpanWithTag(context.Background(), "db_query", "statement", statement).Return(context.Background(), nil)
+			mockHistogram.EXPECT().With("database", "audit", "operation", operation, "statement", "select").Return(mockHistogram)
+			mockHistogram.EXPECT().Observe(gomock.Any())
+		}
+		mockDB.EXPECT().Exec(query).Return(nil, nil)
+		mockDB.EXPECT().Query(query).Return(nil, nil)
+		mockDB.EXPECT().QueryRow(query).Return(sqlRow)
+		_, _ = db.Exec(query)
+		_, _ = db.Query(query)
+		assert.Equal(t, sqlRow, db.QueryRow(query))
+	})
+	t.Run("Transaction", func(t *testing.T) {
+		mockDB.EXPECT().BeginTx(ctx, nil).Return(mockTx, nil)
+		tx, err := db.BeginTx(ctx, nil)
+		assert.Nil(t, err)
+
+		expectMeasure(ctx, spanCtx, "exec", statement, "select")
+		mockTx.EXPECT().ExecContext(spanCtx, query).Return(nil, nil)
+		_, _ = tx.ExecContext(ctx, query)
+
+		expectMeasure(context.Background(), context.Background(), "commit", "COMMIT", "commit")
+		mockTx.EXPECT().Commit().Return(nil)
+		assert.Nil(t, tx.Commit())
+
+		mockTx.EXPECT().Close().Return(nil)
+		assert.Nil(t, tx.Close())
+	})
+	t.Run("Named statement", func(t *testing.T) {
+		var namedCtx = WithStatementName(ctx, "select_table")
+		expectMeasure(namedCtx, spanCtx, "query", statement, "select_table")
+		mockDB.EXPECT().QueryContext(spanCtx, query).Return(nil, nil)
+		_, err := db.QueryContext(namedCtx, query)
+		assert.Nil(t, err)
+	})
+	t.Run("BeginTx fails", func(t *testing.T) {
+		mockDB.EXPECT().BeginTx(ctx, nil).Return(nil, anyError)
+		_, err := db.BeginTx(ctx, nil)
+		assert.Equal(t, anyError, err)
+	})
+	t.Run("Not measured calls", func(t *testing.T) {
+		mockDB.EXPECT().Ping().Return(nil)
+		mockDB.EXPECT().PingContext(ctx).Return(nil)
+		mockDB.EXPECT().Stats().Return(sql.DBStats{InUse: 2})
+		mockDB.EXPECT().Close().Return(nil)
+		assert.Nil(t, db.Ping())
+		assert.Nil(t, db.PingContext(ctx))
+		assert.Equal(t, 2, db.Stats().InUse)
+		assert.Nil(t, db.Close())
+	})
+}
+
+func TestInstrumentedDBSlowQueries(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockDB = mock.NewCloudtrustDB(mockCtrl)
+	var mockMetrics = mock.NewMetrics(mockCtrl)
+	var mockHistogram = mock.NewHistogram(mockCtrl)
+	var mockTracer = mock.NewOpentracingClient(mockCtrl)
+	var mockLogger = mock.NewLogger(mockCtrl)
+	var ctx = context.WithValue(context.TODO(), cs.CtContextCorrelationID, "corr-id")
+
+	mockMetrics.EXPECT().NewHistogram("queries").Return(mockHistogram)
+	mockTracer.EXPECT().TryStartSpanWithTag(gomock.Any(), "sql", "statement", gomock.Any()).Return(ctx, nil).AnyTimes()
+	mockHistogram.EXPECT().With(gomock.Any()).Return(mockHistogram).AnyTimes()
+	mockHistogram.EXPECT().Observe(gomock.Any()).AnyTimes()
+	var db = NewInstrumentedDB(mockDB, mockMetrics, mockTracer, mockLogger, InstrumentationOptions{
+		Name:               "audit",
+		HistogramName:      "queries",
+		SpanName:           "sql",
+		SlowQueryThreshold: 5 * time.Millisecond,
+	})
+
+	t.Run("Fast query", func(t *testing.T) {
+		mockDB.EXPECT().ExecContext(ctx, "UPDATE t SET a=1").Return(nil, nil)
+		_, _ = db.ExecContext(ctx, "UPDATE t SET a=1")
+	})
+	t.Run("Slow query is logged with the caller context", func(t *testing.T) {
+		mockDB.EXPECT().ExecContext(ctx, "UPDATE t SET a=2").DoAndReturn(func(_ context.Context, _ string, _ ...any) (sql.Result, error) {
+			time.Sleep(10 * time.Millisecond)
+			return nil, nil
+		})
+		mockLogger.EXPECT().Warn(ctx, "msg", "Slow database query", "database", "audit", "operation", "exec",
+			"statement", "UPDATE t SET a=2", "duration_ms", gomock.Any())
+		_, _ = db.ExecContext(ctx, "UPDATE t SET a=2")
+	})
+	t.Run("Disabled slow-query log", func(t *testing.T) {
+		mockMetrics.EXPECT().NewHistogram("db_query_duration").Return(mockHistogram)
+		var db = NewInstrumentedDB(mockDB, mockMetrics, mockTracer, log.NewNopLogger(), InstrumentationOptions{SpanName: "sql"})
+		mockDB.EXPECT().ExecContext(ctx, "UPDATE t SET a=3").DoAndReturn(func(_ context.Context, _ string, _ ...any) (sql.Result, error) {
+			time.Sleep(10 * time.Millisecond)
+			return nil, nil
+		})
+		_, _ = db.ExecContext(ctx, "UPDATE t SET a=3")
+	})
+}
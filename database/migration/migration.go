@@ -0,0 +1,209 @@
+package migration
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Versioned migration scripts are named like Flyway ones: V<version>__<description>.sql
+	// Version segments are separated by dots or underscores
+	versionedScriptRegexp = regexp.MustCompile(`^V(\d+(?:[._]\d+)*)__(.+)\.sql$`)
+	versionRegexp         = regexp.MustCompile(`^\d+(?:[._]\d+)*$`)
+)
+
+// Version is a migration version made of any number of numeric segments
+type Version []int
+
+// ParseVersion parses a version like 1, 1.2, 2.14.1 or 2_14_1
+func ParseVersion(value string) (Version, error) {
+	if !versionRegexp.MatchString(value) {
+		return nil, fmt.Errorf("version %s does not match the required format", value)
+	}
+	var segments = strings.FieldsFunc(value, func(r rune) bool { return r == '.' || r == '_' })
+	var res = make(Version, len(segments))
+	for i, segment := range segments {
+		var err error
+		if res[i], err = strconv.Atoi(segment); err != nil {
+			return nil, fmt.Errorf("version %s does not match the required format", value)
+		}
+	}
+	return res, nil
+}
+
+// Compare returns -1, 0 or 1 if the version is lower, equal or greater than the other one. Missing segments are considered
+// as zeros: 1.2 equals 1.2.0
+func (v Version) Compare(other Version) int {
+	for i := 0; i < len(v) || i < len(other); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(other) {
+			b = other[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// String returns the version as it is stored in the flyway_schema_history table
+func (v Version) String() string {
+	var segments = make([]string, len(v))
+	for i, segment := range v {
+		segments[i] = strconv.Itoa(segment)
+	}
+	return strings.Join(segments, ".")
+}
+
+// Migration is a versioned SQL script
+type Migration struct {
+	Version     Version
+	Description string
+	Script      string
+	Checksum    int32
+	Content     string
+}
+
+// LoadMigrations loads the versioned migration scripts found in the root directory of the given file system, sorted by version.
+// Files which are not versioned migration scripts are ignored
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Migration
+	for _, entry := range entries {
+		var match = versionedScriptRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(".", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		version, err := ParseVersion(match[1])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, Migration{
+			Version:     version,
+			Description: strings.ReplaceAll(match[2], "_", " "),
+			Script:      entry.Name(),
+			Checksum:    Checksum(content),
+			Content:     string(content),
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Version.Compare(res[j].Version) < 0 })
+	for i := 1; i < len(res); i++ {
+		if res[i-1].Version.Compare(res[i].Version) == 0 {
+			return nil, fmt.Errorf("found more than one migration with version %s (%s, %s)", res[i].Version, res[i-1].Script, res[i].Script)
+		}
+	}
+	return res, nil
+}
+
+// Checksum computes the checksum of a script the same way Flyway does: a CRC32 of all the lines of the script, without
+// line terminators and without the UTF-8 byte order mark
+func Checksum(content []byte) int32 {
+	var crc = crc32.NewIEEE()
+	var scanner = bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xEF\xBB\xBF"))))
+	scanner.Buffer(make([]byte, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		_, _ = crc.Write(bytes.TrimSuffix(scanner.Bytes(), []byte("\r")))
+	}
+	return int32(crc.Sum32())
+}
+
+// SplitStatements splits a script into SQL statements separated by semicolons. Semicolons in quoted strings, quoted
+// identifiers and comments are ignored. Comments are removed, except the executable comments (/*! ... */, as written by
+// mysqldump) and the optimizer hints (/*+ ... */) which are part of the statement
+func SplitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	var quote rune
+	var lineComment, blockComment, keptComment bool
+
+	var runes = []rune(script)
+	for i := 0; i < len(runes); i++ {
+		var c = runes[i]
+		var next rune
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case lineComment:
+			if c == '\n' {
+				lineComment = false
+				current.WriteRune(c)
+			}
+			continue
+		case blockComment:
+			if keptComment {
+				current.WriteRune(c)
+			}
+			if c == '*' && next == '/' {
+				if keptComment {
+					current.WriteRune(next)
+				}
+				blockComment = false
+				i++
+			}
+			continue
+		case quote != 0:
+			current.WriteRune(c)
+			if c == '\\' && quote != '`' && next != 0 {
+				current.WriteRune(next)
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '#' || (c == '-' && next == '-' && (i+2 >= len(runes) || runes[i+2] == ' ' || runes[i+2] == '\t' || runes[i+2] == '\n')):
+			lineComment = true
+		case c == '/' && next == '*':
+			blockComment = true
+			keptComment = i+2 < len(runes) && (runes[i+2] == '!' || runes[i+2] == '+')
+			if keptComment {
+				current.WriteString("/*")
+			}
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			current.WriteRune(c)
+		case c == ';':
+			statements = appendStatement(statements, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	return appendStatement(statements, current.String())
+}
+
+func appendStatement(statements []string, statement string) []string {
+	statement = strings.TrimSpace(statement)
+	if statement == "" {
+		return statements
+	}
+	return append(statements, statement)
+}
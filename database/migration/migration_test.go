@@ -0,0 +1,160 @@
+package migration
+
+import (
+	"hash/crc32"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	for _, invalidVersion := range []string{"", "A.b", "1.", ".1", "1..2", "1-2"} {
+		_, err := ParseVersion(invalidVersion)
+		assert.NotNil(t, err, invalidVersion)
+	}
+
+	for input, expected := range map[string]string{"3": "3", "1.2": "1.2", "2.14.1": "2.14.1", "2_14_1": "2.14.1", "1.01": "1.1"} {
+		version, err := ParseVersion(input)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, version.String())
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	var v = func(value string) Version {
+		var res, _ = ParseVersion(value)
+		return res
+	}
+	assert.Equal(t, 0, v("1.2").Compare(v("1.2.0")))
+	assert.Equal(t, -1, v("1.2").Compare(v("1.10")))
+	assert.Equal(t, 1, v("2.14.1").Compare(v("2.14")))
+	assert.Equal(t, -1, v("2").Compare(v("2.0.1")))
+	assert.Equal(t, 1, v("3").Compare(v("2.99.99")))
+}
+
+func TestChecksum(t *testing.T) {
+	// Line terminators and byte order mark are not part of the checksum
+	var expected = int32(crc32.ChecksumIEEE([]byte("CREATE TABLE t (id INT);INSERT INTO t VALUES (1);")))
+	assert.Equal(t, expected, Checksum([]byte("CREATE TABLE t (id INT);\nINSERT INTO t VALUES (1);\n")))
+	assert.Equal(t, expected, Checksum([]byte("\xEF\xBB\xBFCREATE TABLE t (id INT);\r\nINSERT INTO t VALUES (1);")))
+}
+
+func TestSplitStatements(t *testing.T) {
+	var script = `-- create the table
+CREATE TABLE t (
+	id INT, # the identifier
+	label VARCHAR(20) DEFAULT 'a;b'
+);
+/* multi-line
+   comment; */
+INSERT INTO t VALUES (1, "it\"s;");
+INSERT INTO ` + "`t;`" + ` VALUES (2, 'x');
+SELECT 5--1;
+`
+	var statements = SplitStatements(script)
+	assert.Equal(t, []string{
+		"CREATE TABLE t (\n\tid INT, \n\tlabel VARCHAR(20) DEFAULT 'a;b'\n)",
+		`INSERT INTO t VALUES (1, "it\"s;")`,
+		"INSERT INTO `t;` VALUES (2, 'x')",
+		"SELECT 5--1",
+	}, statements)
+
+	assert.Len(t, SplitStatements("  \n-- only a comment\n"), 0)
+
+	t.Run("Executable comments and optimizer hints are kept", func(t *testing.T) {
+		var statements = SplitStatements("/*!40101 SET NAMES utf8mb4 */;\nSELECT /*+ MAX_EXECUTION_TIME(1000) */ id /* removed; */ FROM t;")
+		assert.Equal(t, []string{
+			"/*!40101 SET NAMES utf8mb4 */",
+			"SELECT /*+ MAX_EXECUTION_TIME(1000) */ id  FROM t",
+		}, statements)
+	})
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var fsys = fstest.MapFS{
+			"V1_10__add_index.sql":    {Data: []byte("CREATE INDEX i ON t (id);")},
+			"V1_2__create_table.sql":  {Data: []byte("CREATE TABLE t (id INT);")},
+			"V1__init.sql":            {Data: []byte("SELECT 1;")},
+			"R__view.sql":             {Data: []byte("CREATE VIEW v AS SELECT 1;")},
+			"README.md":               {Data: []byte("not a migration")},
+			"sub/V9__not_in_root.sql": {Data: []byte("SELECT 1;")},
+		}
+		migrations, err := LoadMigrations(fsys)
+		assert.Nil(t, err)
+		assert.Len(t, migrations, 3)
+		assert.Equal(t, "1", migrations[0].Version.String())
+		assert.Equal(t, "1.2", migrations[1].Version.String())
+		assert.Equal(t, "create table", migrations[1].Description)
+		assert.Equal(t, "V1_2__create_table.sql", migrations[1].Script)
+		assert.Equal(t, "1.10", migrations[2].Version.String())
+		assert.Equal(t, Checksum([]byte("CREATE INDEX i ON t (id);")), migrations[2].Checksum)
+	})
+	t.Run("Duplicated versions", func(t *testing.T) {
+		var fsys = fstest.MapFS{
+			"V1_2__create_table.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+			"V1.2__other.sql":        {Data: []byte("SELECT 1;")},
+		}
+		_, err := LoadMigrations(fsys)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	var ptr = func(value string) *string { return &value }
+	var checksum = func(value int32) *int32 { return &value }
+	var migrations, _ = LoadMigrations(fstest.MapFS{
+		"V1__init.sql":           {Data: []byte("SELECT 1;")},
+		"V1_1__create_table.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+		"V2__add_index.sql":      {Data: []byte("CREATE INDEX i ON t (id);")},
+	})
+
+	t.Run("Empty history", func(t *testing.T) {
+		pending, err := Validate(migrations, nil)
+		assert.Nil(t, err)
+		assert.Len(t, pending, 3)
+	})
+	t.Run("Partially applied", func(t *testing.T) {
+		pending, err := Validate(migrations, []AppliedMigration{
+			{InstalledRank: 1, Version: ptr("1"), Type: TypeSQL, Script: "V1__init.sql", Checksum: checksum(migrations[0].Checksum), Success: true},
+			{InstalledRank: 2, Version: nil, Type: TypeSQL, Script: "R__view.sql", Success: true},
+			{InstalledRank: 3, Version: ptr("1.1"), Type: TypeSQL, Script: "V1_1__create_table.sql", Checksum: checksum(migrations[1].Checksum), Success: true},
+		})
+		assert.Nil(t, err)
+		assert.Len(t, pending, 1)
+		assert.Equal(t, "V2__add_index.sql", pending[0].Script)
+	})
+	t.Run("Baseline", func(t *testing.T) {
+		pending, err := Validate(migrations, []AppliedMigration{
+			{InstalledRank: 1, Version: ptr("1.1"), Type: TypeBaseline, Script: "<< Flyway Baseline >>", Success: true},
+		})
+		assert.Nil(t, err)
+		assert.Len(t, pending, 1)
+	})
+	t.Run("Checksum mismatch", func(t *testing.T) {
+		_, err := Validate(migrations, []AppliedMigration{
+			{InstalledRank: 1, Version: ptr("1"), Type: TypeSQL, Script: "V1__init.sql", Checksum: checksum(123), Success: true},
+		})
+		assert.NotNil(t, err)
+	})
+	t.Run("Failed migration", func(t *testing.T) {
+		_, err := Validate(migrations, []AppliedMigration{
+			{InstalledRank: 1, Version: ptr("1"), Type: TypeSQL, Script: "V1__init.sql", Checksum: checksum(migrations[0].Checksum), Success: false},
+		})
+		assert.NotNil(t, err)
+	})
+	t.Run("Missing older migration", func(t *testing.T) {
+		_, err := Validate(migrations, []AppliedMigration{
+			{InstalledRank: 1, Version: ptr("1"), Type: TypeSQL, Script: "V1__init.sql", Checksum: checksum(migrations[0].Checksum), Success: true},
+			{InstalledRank: 2, Version: ptr("2"), Type: TypeSQL, Script: "V2__add_index.sql", Checksum: checksum(migrations[2].Checksum), Success: true},
+		})
+		assert.NotNil(t, err)
+	})
+	t.Run("Invalid version in history", func(t *testing.T) {
+		_, err := Validate(migrations, []AppliedMigration{
+			{InstalledRank: 1, Version: ptr("x"), Type: TypeSQL, Script: "Vx__init.sql", Success: true},
+		})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,284 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+)
+
+const (
+	createHistoryTableStmt = `CREATE TABLE IF NOT EXISTS flyway_schema_history (
+		installed_rank INT NOT NULL,
+		version VARCHAR(50),
+		description VARCHAR(200) NOT NULL,
+		type VARCHAR(20) NOT NULL,
+		script VARCHAR(1000) NOT NULL,
+		checksum INT,
+		installed_by VARCHAR(100) NOT NULL,
+		installed_on TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		execution_time INT NOT NULL,
+		success TINYINT(1) NOT NULL,
+		PRIMARY KEY (installed_rank),
+		INDEX flyway_schema_history_s_idx (success)
+	)`
+	selectHistoryStmt = `SELECT installed_rank, version, description, type, script, checksum, success FROM flyway_schema_history ORDER BY installed_rank`
+	insertHistoryStmt = `INSERT INTO flyway_schema_history (installed_rank, version, description, type, script, checksum, installed_by, execution_time, success)
+		VALUES (?, ?, ?, ?, ?, ?, IFNULL(?, CURRENT_USER()), ?, ?)`
+	// Lock names are server-wide: the lock is specific to the current database
+	getLockStmt     = `SELECT GET_LOCK(CONCAT(IFNULL(DATABASE(), ''), '.flyway_schema_history'), ?)`
+	releaseLockStmt = `SELECT RELEASE_LOCK(CONCAT(IFNULL(DATABASE(), ''), '.flyway_schema_history'))`
+
+	releaseLockTimeout = 10 * time.Second
+)
+
+// Migration types as stored in flyway_schema_history
+const (
+	TypeSQL      = "SQL"
+	TypeBaseline = "BASELINE"
+)
+
+var (
+	// ErrLockTimeout is returned when the migration lock can't be acquired (another instance is running migrations)
+	ErrLockTimeout = errors.New("can't acquire database migration lock")
+)
+
+// AppliedMigration is a row of the flyway_schema_history table
+type AppliedMigration struct {
+	InstalledRank int
+	Version       *string
+	Description   string
+	Type          string
+	Script        string
+	Checksum      *int32
+	Success       bool
+}
+
+// Options of the migrator
+type Options struct {
+	// DryRun: pending migrations are checked and reported but not applied
+	DryRun bool
+	// LockTimeout is the maximum time to wait for another instance to finish its migrations
+	LockTimeout time.Duration
+	// InstalledBy is the value stored in the installed_by column. Defaults to the database user
+	InstalledBy string
+}
+
+// Migrator applies versioned SQL scripts to a database and records them in a Flyway compatible flyway_schema_history table
+type Migrator struct {
+	db         sqltypes.CloudtrustDB
+	migrations fs.FS
+	options    Options
+	logger     log.Logger
+}
+
+// NewMigrator creates a migrator. Scripts are read from the root directory of the given file system (usually an embed.FS
+// or one of its sub-directories obtained with fs.Sub)
+func NewMigrator(db sqltypes.CloudtrustDB, migrations fs.FS, options Options, logger log.Logger) *Migrator {
+	if options.LockTimeout <= 0 {
+		options.LockTimeout = time.Minute
+	}
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		options:    options,
+		logger:     logger,
+	}
+}
+
+// Migrate applies the pending migrations and returns them. In dry-run mode, pending migrations are only returned
+func (m *Migrator) Migrate(ctx context.Context) ([]Migration, error) {
+	migrations, err := LoadMigrations(m.migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lock is owned by a connection: a transaction is used to keep the same connection until the lock is released
+	lockTx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer lockTx.Close()
+
+	if err = m.acquireLock(ctx, lockTx); err != nil {
+		return nil, err
+	}
+	defer m.releaseLock(ctx, lockTx)
+
+	if !m.options.DryRun {
+		if _, err = m.db.ExecContext(ctx, createHistoryTableStmt); err != nil {
+			return nil, err
+		}
+	}
+
+	applied, err := m.loadHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := Validate(migrations, applied)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.options.DryRun {
+		for _, migration := range pending {
+			m.logger.Info(ctx, "msg", "Pending database migration (dry-run)", "version", migration.Version.String(), "script", migration.Script)
+		}
+		return pending, nil
+	}
+
+	var rank = nextInstalledRank(applied)
+	for i, migration := range pending {
+		if err = m.apply(ctx, rank+i, migration); err != nil {
+			return pending[:i], err
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) acquireLock(ctx context.Context, lockTx sqltypes.Transaction) error {
+	var locked sql.NullInt64
+	if err := lockTx.QueryRowContext(ctx, getLockStmt, int(m.options.LockTimeout.Seconds())).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return ErrLockTimeout
+	}
+	return nil
+}
+
+// releaseLock releases the migration lock even if ctx is done: the lock is owned by the connection which goes back to the
+// pool and would block the next migrations
+func (m *Migrator) releaseLock(ctx context.Context, lockTx sqltypes.Transaction) {
+	var releaseCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), releaseLockTimeout)
+	defer cancel()
+
+	var released sql.NullInt64
+	if err := lockTx.QueryRowContext(releaseCtx, releaseLockStmt).Scan(&released); err != nil {
+		m.logger.Error(ctx, "msg", "Can't release database migration lock", "err", err.Error())
+	} else if !released.Valid || released.Int64 != 1 {
+		m.logger.Error(ctx, "msg", "Database migration lock was not held when releasing it")
+	}
+}
+
+func (m *Migrator) loadHistory(ctx context.Context) ([]AppliedMigration, error) {
+	rows, err := m.db.QueryContext(ctx, selectHistoryStmt)
+	if err != nil {
+		if m.options.DryRun {
+			// In dry-run mode, the history table is not created: consider it as empty
+			m.logger.Warn(ctx, "msg", "Can't read flyway_schema_history", "err", err.Error())
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []AppliedMigration
+	for rows.Next() {
+		var (
+			applied  AppliedMigration
+			version  sql.NullString
+			checksum sql.NullInt32
+		)
+		if err = rows.Scan(&applied.InstalledRank, &version, &applied.Description, &applied.Type, &applied.Script, &checksum, &applied.Success); err != nil {
+			return nil, err
+		}
+		if version.Valid {
+			applied.Version = &version.String
+		}
+		if checksum.Valid {
+			applied.Checksum = &checksum.Int32
+		}
+		res = append(res, applied)
+	}
+	return res, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, rank int, migration Migration) error {
+	m.logger.Info(ctx, "msg", "Applying database migration", "version", migration.Version.String(), "script", migration.Script)
+
+	var start = time.Now()
+	var err error
+	for _, statement := range SplitStatements(migration.Content) {
+		if _, err = m.db.ExecContext(ctx, statement); err != nil {
+			break
+		}
+	}
+	var executionTime = time.Since(start).Milliseconds()
+
+	var installedBy any
+	if m.options.InstalledBy != "" {
+		installedBy = m.options.InstalledBy
+	}
+	_, errHistory := m.db.ExecContext(ctx, insertHistoryStmt, rank, migration.Version.String(), migration.Description, TypeSQL,
+		migration.Script, migration.Checksum, installedBy, executionTime, err == nil)
+
+	if err != nil {
+		m.logger.Error(ctx, "msg", "Database migration failed", "version", migration.Version.String(), "script", migration.Script, "err", err.Error())
+		return fmt.Errorf("migration %s failed: %w", migration.Script, err)
+	}
+	return errHistory
+}
+
+func nextInstalledRank(applied []AppliedMigration) int {
+	var rank = 1
+	for _, migration := range applied {
+		if migration.InstalledRank >= rank {
+			rank = migration.InstalledRank + 1
+		}
+	}
+	return rank
+}
+
+// Validate checks the applied migrations against the available ones and returns the pending migrations. It fails if a
+// migration failed, if the checksum of an applied migration changed or if a migration older than the current version is
+// not applied yet. Migrations up to a baseline version are considered as applied
+func Validate(migrations []Migration, applied []AppliedMigration) ([]Migration, error) {
+	var current, baseline Version
+	var appliedByVersion = make(map[string]AppliedMigration)
+	for _, migration := range applied {
+		if migration.Version == nil {
+			// Repeatable migrations are not managed
+			continue
+		}
+		if !migration.Success {
+			return nil, fmt.Errorf("migration %s failed and must be repaired manually", migration.Script)
+		}
+		var version, err = ParseVersion(*migration.Version)
+		if err != nil {
+			return nil, err
+		}
+		appliedByVersion[version.String()] = migration
+		if migration.Type == TypeBaseline && (baseline == nil || version.Compare(baseline) > 0) {
+			baseline = version
+		}
+		if current == nil || version.Compare(current) > 0 {
+			current = version
+		}
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		var done, ok = appliedByVersion[migration.Version.String()]
+		switch {
+		case ok && done.Type == TypeSQL && (done.Checksum == nil || *done.Checksum != migration.Checksum):
+			return nil, fmt.Errorf("checksum mismatch for migration %s", migration.Script)
+		case ok, baseline != nil && migration.Version.Compare(baseline) <= 0:
+			continue
+		case current != nil && migration.Version.Compare(current) < 0:
+			return nil, fmt.Errorf("migration %s is older than the current database version %s but was not applied", migration.Script, current)
+		default:
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,175 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cloudtrust/common-service/v2/database/migration/mock"
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+type migratorMocks struct {
+	t       *testing.T
+	db      *mock.CloudtrustDB
+	lockTx  *mock.Transaction
+	lockRow *mock.SQLRow
+	rows    *mock.SQLRows
+}
+
+func newMigratorMocks(t *testing.T, mockCtrl *gomock.Controller) *migratorMocks {
+	return &migratorMocks{
+		t:       t,
+		db:      mock.NewCloudtrustDB(mockCtrl),
+		lockTx:  mock.NewTransaction(mockCtrl),
+		lockRow: mock.NewSQLRow(mockCtrl),
+		rows:    mock.NewSQLRows(mockCtrl),
+	}
+}
+
+func (m *migratorMocks) expectLock(ctx context.Context, locked int64) {
+	m.db.EXPECT().BeginTx(ctx, nil).Return(m.lockTx, nil)
+	m.lockTx.EXPECT().QueryRowContext(ctx, getLockStmt, 60).Return(m.lockRow)
+	m.lockRow.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+		*(dest[0].(*sql.NullInt64)) = sql.NullInt64{Int64: locked, Valid: true}
+		return nil
+	})
+	m.lockTx.EXPECT().Close().Return(nil)
+}
+
+func (m *migratorMocks) expectUnlock(ctx context.Context) {
+	// The lock is released with a context which is not cancelled with ctx
+	m.lockTx.EXPECT().QueryRowContext(gomock.Any(), releaseLockStmt).DoAndReturn(func(releaseCtx context.Context, _ string, _ ...any) sqltypes.SQLRow {
+		assert.Nil(m.t, releaseCtx.Err())
+		return m.lockRow
+	})
+	m.lockRow.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+		*(dest[0].(*sql.NullInt64)) = sql.NullInt64{Int64: 1, Valid: true}
+		return nil
+	})
+}
+
+func (m *migratorMocks) expectHistory(ctx context.Context, applied []AppliedMigration) {
+	m.db.EXPECT().QueryContext(ctx, selectHistoryStmt).Return(m.rows, nil)
+	for _, item := range applied {
+		m.rows.EXPECT().Next().Return(true)
+		m.rows.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+			*(dest[0].(*int)) = item.InstalledRank
+			*(dest[1].(*sql.NullString)) = sql.NullString{String: *item.Version, Valid: true}
+			*(dest[2].(*string)) = item.Description
+			*(dest[3].(*string)) = item.Type
+			*(dest[4].(*string)) = item.Script
+			*(dest[5].(*sql.NullInt32)) = sql.NullInt32{Int32: *item.Checksum, Valid: true}
+			*(dest[6].(*bool)) = item.Success
+			return nil
+		})
+	}
+	m.rows.EXPECT().Next().Return(false)
+	m.rows.EXPECT().Err().Return(nil)
+	m.rows.EXPECT().Close().Return(nil)
+}
+
+func TestMigrate(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mocks = newMigratorMocks(t, mockCtrl)
+	var ctx = context.TODO()
+	var anyError = errors.New("any error")
+	var fsys = fstest.MapFS{
+		"V1__init.sql":         {Data: []byte("CREATE TABLE t (id INT);")},
+		"V2__insert_items.sql": {Data: []byte("INSERT INTO t VALUES (1);\nINSERT INTO t VALUES (2);")},
+	}
+	var version1 = "1"
+	var checksum1 = Checksum(fsys["V1__init.sql"].Data)
+	var history = []AppliedMigration{{InstalledRank: 1, Version: &version1, Description: "init", Type: TypeSQL, Script: "V1__init.sql", Checksum: &checksum1, Success: true}}
+
+	t.Run("Invalid migrations", func(t *testing.T) {
+		var migrator = NewMigrator(mocks.db, fstest.MapFS{"V1__a.sql": {}, "V1_0__b.sql": {}}, Options{}, nil)
+		_, err := migrator.Migrate(ctx)
+		assert.NotNil(t, err)
+	})
+	t.Run("Lock can't be acquired", func(t *testing.T) {
+		var migrator = NewMigrator(mocks.db, fsys, Options{}, log.NewNopLogger())
+		mocks.expectLock(ctx, 0)
+		_, err := migrator.Migrate(ctx)
+		assert.Equal(t, ErrLockTimeout, err)
+	})
+	t.Run("Can't create history table", func(t *testing.T) {
+		var migrator = NewMigrator(mocks.db, fsys, Options{}, log.NewNopLogger())
+		mocks.expectLock(ctx, 1)
+		mocks.db.EXPECT().ExecContext(ctx, createHistoryTableStmt).Return(nil, anyError)
+		mocks.expectUnlock(ctx)
+		_, err := migrator.Migrate(ctx)
+		assert.Equal(t, anyError, err)
+	})
+	t.Run("Lock is released when the context is cancelled", func(t *testing.T) {
+		var migrator = NewMigrator(mocks.db, fsys, Options{}, log.NewNopLogger())
+		var cancelledCtx, cancel = context.WithCancel(ctx)
+		cancel()
+		mocks.expectLock(cancelledCtx, 1)
+		mocks.db.EXPECT().ExecContext(cancelledCtx, createHistoryTableStmt).Return(nil, context.Canceled)
+		mocks.expectUnlock(cancelledCtx)
+		_, err := migrator.Migrate(cancelledCtx)
+		assert.Equal(t, context.Canceled, err)
+	})
+	t.Run("Lock release fails", func(t *testing.T) {
+		var migrator = NewMigrator(mocks.db, fsys, Options{}, log.NewNopLogger())
+		mocks.expectLock(ctx, 1)
+		mocks.db.EXPECT().ExecContext(ctx, createHistoryTableStmt).Return(nil, anyError)
+		mocks.lockTx.EXPECT().QueryRowContext(gomock.Any(), releaseLockStmt).Return(mocks.lockRow)
+		mocks.lockRow.EXPECT().Scan(gomock.Any()).Return(anyError)
+		_, err := migrator.Migrate(ctx)
+		assert.Equal(t, anyError, err)
+	})
+	t.Run("Dry-run", func(t *testing.T) {
+		var migrator = NewMigrator(mocks.db, fsys, Options{DryRun: true}, log.NewNopLogger())
+		mocks.expectLock(ctx, 1)
+		mocks.expectHistory(ctx, history)
+		mocks.expectUnlock(ctx)
+		pending, err := migrator.Migrate(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, pending, 1)
+		assert.Equal(t, "V2__insert_items.sql", pending[0].Script)
+	})
+	t.Run("Dry-run without history table", func(t *testing.T) {
+		var migrator = NewMigrator(mocks.db, fsys, Options{DryRun: true}, log.NewNopLogger())
+		mocks.expectLock(ctx, 1)
+		mocks.db.EXPECT().QueryContext(ctx, selectHistoryStmt).Return(nil, anyError)
+		mocks.expectUnlock(ctx)
+		pending, err := migrator.Migrate(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, pending, 2)
+	})
+	t.Run("Apply pending migration", func(t *testing.T) {
+		var migrator = NewMigrator(mocks.db, fsys, Options{InstalledBy: "tester"}, log.NewNopLogger())
+		mocks.expectLock(ctx, 1)
+		mocks.db.EXPECT().ExecContext(ctx, createHistoryTableStmt).Return(nil, nil)
+		mocks.expectHistory(ctx, history)
+		mocks.db.EXPECT().ExecContext(ctx, "INSERT INTO t VALUES (1)").Return(nil, nil)
+		mocks.db.EXPECT().ExecContext(ctx, "INSERT INTO t VALUES (2)").Return(nil, nil)
+		mocks.db.EXPECT().ExecContext(ctx, insertHistoryStmt, 2, "2", "insert items", TypeSQL, "V2__insert_items.sql",
+			Checksum(fsys["V2__insert_items.sql"].Data), "tester", gomock.Any(), true).Return(nil, nil)
+		mocks.expectUnlock(ctx)
+		applied, err := migrator.Migrate(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, applied, 1)
+	})
+	t.Run("Migration fails", func(t *testing.T) {
+		var migrator = NewMigrator(mocks.db, fsys, Options{}, log.NewNopLogger())
+		mocks.expectLock(ctx, 1)
+		mocks.db.EXPECT().ExecContext(ctx, createHistoryTableStmt).Return(nil, nil)
+		mocks.expectHistory(ctx, nil)
+		mocks.db.EXPECT().ExecContext(ctx, "CREATE TABLE t (id INT)").Return(nil, anyError)
+		mocks.db.EXPECT().ExecContext(ctx, insertHistoryStmt, 1, "1", "init", TypeSQL, "V1__init.sql", checksum1, nil, gomock.Any(), false).Return(nil, nil)
+		mocks.expectUnlock(ctx)
+		applied, err := migrator.Migrate(ctx)
+		assert.True(t, errors.Is(err, anyError))
+		assert.Len(t, applied, 0)
+	})
+}
@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/cloudtrust/common-service/v2/database/sqltypes (interfaces: SQLRow,CloudtrustDB,CloudtrustDBFactory)
+// Source: github.com/cloudtrust/common-service/v2/database/sqltypes (interfaces: SQLRow,SQLRows,CloudtrustDB,CloudtrustDBFactory,Transaction)
 //
 // Generated by this command:
 //
-//	mockgen --build_flags=--mod=mod -destination=./mock/cloudtrustdb.go -package=mock -mock_names=SQLRow=SQLRow,CloudtrustDB=CloudtrustDB,CloudtrustDBFactory=CloudtrustDBFactory github.com/cloudtrust/common-service/v2/database/sqltypes SQLRow,CloudtrustDB,CloudtrustDBFactory
+//	mockgen --build_flags=--mod=mod -destination=./mock/cloudtrustdb.go -package=mock -mock_names=SQLRow=SQLRow,SQLRows=SQLRows,CloudtrustDB=CloudtrustDB,CloudtrustDBFactory=CloudtrustDBFactory,Transaction=Transaction github.com/cloudtrust/common-service/v2/database/sqltypes SQLRow,SQLRows,CloudtrustDB,CloudtrustDBFactory,Transaction
 //
 
 // Package mock is a generated GoMock package.
@@ -60,6 +60,104 @@ func (mr *SQLRowMockRecorder) Scan(dest ...any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*SQLRow)(nil).Scan), dest...)
 }
 
+// SQLRows is a mock of SQLRows interface.
+type SQLRows struct {
+	ctrl     *gomock.Controller
+	recorder *SQLRowsMockRecorder
+	isgomock struct{}
+}
+
+// SQLRowsMockRecorder is the mock recorder for SQLRows.
+type SQLRowsMockRecorder struct {
+	mock *SQLRows
+}
+
+// NewSQLRows creates a new mock instance.
+func NewSQLRows(ctrl *gomock.Controller) *SQLRows {
+	mock := &SQLRows{ctrl: ctrl}
+	mock.recorder = &SQLRowsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *SQLRows) EXPECT() *SQLRowsMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *SQLRows) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *SQLRowsMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*SQLRows)(nil).Close))
+}
+
+// Err mocks base method.
+func (m *SQLRows) Err() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Err")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Err indicates an expected call of Err.
+func (mr *SQLRowsMockRecorder) Err() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Err", reflect.TypeOf((*SQLRows)(nil).Err))
+}
+
+// Next mocks base method.
+func (m *SQLRows) Next() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Next")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Next indicates an expected call of Next.
+func (mr *SQLRowsMockRecorder) Next() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*SQLRows)(nil).Next))
+}
+
+// NextResultSet mocks base method.
+func (m *SQLRows) NextResultSet() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextResultSet")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// NextResultSet indicates an expected call of NextResultSet.
+func (mr *SQLRowsMockRecorder) NextResultSet() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextResultSet", reflect.TypeOf((*SQLRows)(nil).NextResultSet))
+}
+
+// Scan mocks base method.
+func (m *SQLRows) Scan(dest ...any) error {
+	m.ctrl.T.Helper()
+	varargs := []any{}
+	for _, a := range dest {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Scan", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *SQLRowsMockRecorder) Scan(dest ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*SQLRows)(nil).Scan), dest...)
+}
+
 // CloudtrustDB is a mock of CloudtrustDB interface.
 type CloudtrustDB struct {
 	ctrl     *gomock.Controller
@@ -133,6 +231,26 @@ func (mr *CloudtrustDBMockRecorder) Exec(query any, args ...any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*CloudtrustDB)(nil).Exec), varargs...)
 }
 
+// ExecContext mocks base method.
+func (m *CloudtrustDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExecContext", varargs...)
+	ret0, _ := ret[0].(sql.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecContext indicates an expected call of ExecContext.
+func (mr *CloudtrustDBMockRecorder) ExecContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecContext", reflect.TypeOf((*CloudtrustDB)(nil).ExecContext), varargs...)
+}
+
 // Ping mocks base method.
 func (m *CloudtrustDB) Ping() error {
 	m.ctrl.T.Helper()
@@ -147,6 +265,20 @@ func (mr *CloudtrustDBMockRecorder) Ping() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*CloudtrustDB)(nil).Ping))
 }
 
+// PingContext mocks base method.
+func (m *CloudtrustDB) PingContext(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PingContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PingContext indicates an expected call of PingContext.
+func (mr *CloudtrustDBMockRecorder) PingContext(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingContext", reflect.TypeOf((*CloudtrustDB)(nil).PingContext), ctx)
+}
+
 // Query mocks base method.
 func (m *CloudtrustDB) Query(query string, args ...any) (sqltypes.SQLRows, error) {
 	m.ctrl.T.Helper()
@@ -167,6 +299,26 @@ func (mr *CloudtrustDBMockRecorder) Query(query any, args ...any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*CloudtrustDB)(nil).Query), varargs...)
 }
 
+// QueryContext mocks base method.
+func (m *CloudtrustDB) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryContext", varargs...)
+	ret0, _ := ret[0].(sqltypes.SQLRows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryContext indicates an expected call of QueryContext.
+func (mr *CloudtrustDBMockRecorder) QueryContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryContext", reflect.TypeOf((*CloudtrustDB)(nil).QueryContext), varargs...)
+}
+
 // QueryRow mocks base method.
 func (m *CloudtrustDB) QueryRow(query string, args ...any) sqltypes.SQLRow {
 	m.ctrl.T.Helper()
@@ -186,6 +338,25 @@ func (mr *CloudtrustDBMockRecorder) QueryRow(query any, args ...any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRow", reflect.TypeOf((*CloudtrustDB)(nil).QueryRow), varargs...)
 }
 
+// QueryRowContext mocks base method.
+func (m *CloudtrustDB) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryRowContext", varargs...)
+	ret0, _ := ret[0].(sqltypes.SQLRow)
+	return ret0
+}
+
+// QueryRowContext indicates an expected call of QueryRowContext.
+func (mr *CloudtrustDBMockRecorder) QueryRowContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRowContext", reflect.TypeOf((*CloudtrustDB)(nil).QueryRowContext), varargs...)
+}
+
 // Stats mocks base method.
 func (m *CloudtrustDB) Stats() sql.DBStats {
 	m.ctrl.T.Helper()
@@ -238,3 +409,187 @@ func (mr *CloudtrustDBFactoryMockRecorder) OpenDatabase() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenDatabase", reflect.TypeOf((*CloudtrustDBFactory)(nil).OpenDatabase))
 }
+
+// Transaction is a mock of Transaction interface.
+type Transaction struct {
+	ctrl     *gomock.Controller
+	recorder *TransactionMockRecorder
+	isgomock struct{}
+}
+
+// TransactionMockRecorder is the mock recorder for Transaction.
+type TransactionMockRecorder struct {
+	mock *Transaction
+}
+
+// NewTransaction creates a new mock instance.
+func NewTransaction(ctrl *gomock.Controller) *Transaction {
+	mock := &Transaction{ctrl: ctrl}
+	mock.recorder = &TransactionMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Transaction) EXPECT() *TransactionMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *Transaction) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *TransactionMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*Transaction)(nil).Close))
+}
+
+// Commit mocks base method.
+func (m *Transaction) Commit() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit.
+func (mr *TransactionMockRecorder) Commit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*Transaction)(nil).Commit))
+}
+
+// Exec mocks base method.
+func (m *Transaction) Exec(query string, args ...any) (sql.Result, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Exec", varargs...)
+	ret0, _ := ret[0].(sql.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exec indicates an expected call of Exec.
+func (mr *TransactionMockRecorder) Exec(query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*Transaction)(nil).Exec), varargs...)
+}
+
+// ExecContext mocks base method.
+func (m *Transaction) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExecContext", varargs...)
+	ret0, _ := ret[0].(sql.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecContext indicates an expected call of ExecContext.
+func (mr *TransactionMockRecorder) ExecContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecContext", reflect.TypeOf((*Transaction)(nil).ExecContext), varargs...)
+}
+
+// Query mocks base method.
+func (m *Transaction) Query(query string, args ...any) (sqltypes.SQLRows, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Query", varargs...)
+	ret0, _ := ret[0].(sqltypes.SQLRows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Query indicates an expected call of Query.
+func (mr *TransactionMockRecorder) Query(query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*Transaction)(nil).Query), varargs...)
+}
+
+// QueryContext mocks base method.
+func (m *Transaction) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryContext", varargs...)
+	ret0, _ := ret[0].(sqltypes.SQLRows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryContext indicates an expected call of QueryContext.
+func (mr *TransactionMockRecorder) QueryContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryContext", reflect.TypeOf((*Transaction)(nil).QueryContext), varargs...)
+}
+
+// QueryRow mocks base method.
+func (m *Transaction) QueryRow(query string, args ...any) sqltypes.SQLRow {
+	m.ctrl.T.Helper()
+	varargs := []any{query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryRow", varargs...)
+	ret0, _ := ret[0].(sqltypes.SQLRow)
+	return ret0
+}
+
+// QueryRow indicates an expected call of QueryRow.
+func (mr *TransactionMockRecorder) QueryRow(query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRow", reflect.TypeOf((*Transaction)(nil).QueryRow), varargs...)
+}
+
+// QueryRowContext mocks base method.
+func (m *Transaction) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryRowContext", varargs...)
+	ret0, _ := ret[0].(sqltypes.SQLRow)
+	return ret0
+}
+
+// QueryRowContext indicates an expected call of QueryRowContext.
+func (mr *TransactionMockRecorder) QueryRowContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRowContext", reflect.TypeOf((*Transaction)(nil).QueryRowContext), varargs...)
+}
+
+// Rollback mocks base method.
+func (m *Transaction) Rollback() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rollback")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rollback indicates an expected call of Rollback.
+func (mr *TransactionMockRecorder) Rollback() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rollback", reflect.TypeOf((*Transaction)(nil).Rollback))
+}
@@ -10,6 +10,7 @@
 package mock
 
 import (
+	context "context"
 	sql "database/sql"
 	reflect "reflect"
 
@@ -74,6 +75,26 @@ func (mr *DbTransactionIntfMockRecorder) Exec(query any, args ...any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*DbTransactionIntf)(nil).Exec), varargs...)
 }
 
+// ExecContext mocks base method.
+func (m *DbTransactionIntf) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExecContext", varargs...)
+	ret0, _ := ret[0].(sql.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecContext indicates an expected call of ExecContext.
+func (mr *DbTransactionIntfMockRecorder) ExecContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecContext", reflect.TypeOf((*DbTransactionIntf)(nil).ExecContext), varargs...)
+}
+
 // Query mocks base method.
 func (m *DbTransactionIntf) Query(query string, args ...any) (*sql.Rows, error) {
 	m.ctrl.T.Helper()
@@ -94,6 +115,26 @@ func (mr *DbTransactionIntfMockRecorder) Query(query any, args ...any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*DbTransactionIntf)(nil).Query), varargs...)
 }
 
+// QueryContext mocks base method.
+func (m *DbTransactionIntf) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryContext", varargs...)
+	ret0, _ := ret[0].(*sql.Rows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryContext indicates an expected call of QueryContext.
+func (mr *DbTransactionIntfMockRecorder) QueryContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryContext", reflect.TypeOf((*DbTransactionIntf)(nil).QueryContext), varargs...)
+}
+
 // QueryRow mocks base method.
 func (m *DbTransactionIntf) QueryRow(query string, args ...any) *sql.Row {
 	m.ctrl.T.Helper()
@@ -113,6 +154,25 @@ func (mr *DbTransactionIntfMockRecorder) QueryRow(query any, args ...any) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRow", reflect.TypeOf((*DbTransactionIntf)(nil).QueryRow), varargs...)
 }
 
+// QueryRowContext mocks base method.
+func (m *DbTransactionIntf) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryRowContext", varargs...)
+	ret0, _ := ret[0].(*sql.Row)
+	return ret0
+}
+
+// QueryRowContext indicates an expected call of QueryRowContext.
+func (mr *DbTransactionIntfMockRecorder) QueryRowContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRowContext", reflect.TypeOf((*DbTransactionIntf)(nil).QueryRowContext), varargs...)
+}
+
 // Rollback mocks base method.
 func (m *DbTransactionIntf) Rollback() error {
 	m.ctrl.T.Helper()
@@ -0,0 +1,145 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/cloudtrust/common-service/v2/healthcheck (interfaces: HealthChecker)
+//
+// Generated by this command:
+//
+//	mockgen --build_flags=--mod=mod -destination=./mock/healthcheck.go -package=mock -mock_names=HealthChecker=HealthChecker github.com/cloudtrust/common-service/v2/healthcheck HealthChecker
+//
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	http "net/http"
+	reflect "reflect"
+	time "time"
+
+	events "github.com/cloudtrust/common-service/v2/events"
+	healthcheck "github.com/cloudtrust/common-service/v2/healthcheck"
+	ratelimit "github.com/go-kit/kit/ratelimit"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// HealthChecker is a mock of HealthChecker interface.
+type HealthChecker struct {
+	ctrl     *gomock.Controller
+	recorder *HealthCheckerMockRecorder
+	isgomock struct{}
+}
+
+// HealthCheckerMockRecorder is the mock recorder for HealthChecker.
+type HealthCheckerMockRecorder struct {
+	mock *HealthChecker
+}
+
+// NewHealthChecker creates a new mock instance.
+func NewHealthChecker(ctrl *gomock.Controller) *HealthChecker {
+	mock := &HealthChecker{ctrl: ctrl}
+	mock.recorder = &HealthCheckerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *HealthChecker) EXPECT() *HealthCheckerMockRecorder {
+	return m.recorder
+}
+
+// AddAuditEventsReporterModule mocks base method.
+func (m *HealthChecker) AddAuditEventsReporterModule(name string, reporter events.AuditEventsReporterModule, timeout, cacheDuration time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddAuditEventsReporterModule", name, reporter, timeout, cacheDuration)
+}
+
+// AddAuditEventsReporterModule indicates an expected call of AddAuditEventsReporterModule.
+func (mr *HealthCheckerMockRecorder) AddAuditEventsReporterModule(name, reporter, timeout, cacheDuration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAuditEventsReporterModule", reflect.TypeOf((*HealthChecker)(nil).AddAuditEventsReporterModule), name, reporter, timeout, cacheDuration)
+}
+
+// AddDatabase mocks base method.
+func (m *HealthChecker) AddDatabase(name string, db healthcheck.HealthDatabase, cacheDuration time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddDatabase", name, db, cacheDuration)
+}
+
+// AddDatabase indicates an expected call of AddDatabase.
+func (mr *HealthCheckerMockRecorder) AddDatabase(name, db, cacheDuration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDatabase", reflect.TypeOf((*HealthChecker)(nil).AddDatabase), name, db, cacheDuration)
+}
+
+// AddHTTPEndpoint mocks base method.
+func (m *HealthChecker) AddHTTPEndpoint(name, targetURL string, timeoutDuration time.Duration, expectedStatus int, cacheDuration time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddHTTPEndpoint", name, targetURL, timeoutDuration, expectedStatus, cacheDuration)
+}
+
+// AddHTTPEndpoint indicates an expected call of AddHTTPEndpoint.
+func (mr *HealthCheckerMockRecorder) AddHTTPEndpoint(name, targetURL, timeoutDuration, expectedStatus, cacheDuration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddHTTPEndpoint", reflect.TypeOf((*HealthChecker)(nil).AddHTTPEndpoint), name, targetURL, timeoutDuration, expectedStatus, cacheDuration)
+}
+
+// AddHTTPEndpoints mocks base method.
+func (m *HealthChecker) AddHTTPEndpoints(endpoints map[string]string, timeoutDuration time.Duration, expectedStatus int, cacheDuration time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddHTTPEndpoints", endpoints, timeoutDuration, expectedStatus, cacheDuration)
+}
+
+// AddHTTPEndpoints indicates an expected call of AddHTTPEndpoints.
+func (mr *HealthCheckerMockRecorder) AddHTTPEndpoints(endpoints, timeoutDuration, expectedStatus, cacheDuration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddHTTPEndpoints", reflect.TypeOf((*HealthChecker)(nil).AddHTTPEndpoints), endpoints, timeoutDuration, expectedStatus, cacheDuration)
+}
+
+// AddHealthChecker mocks base method.
+func (m *HealthChecker) AddHealthChecker(name string, checker healthcheck.BasicChecker) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddHealthChecker", name, checker)
+}
+
+// AddHealthChecker indicates an expected call of AddHealthChecker.
+func (mr *HealthCheckerMockRecorder) AddHealthChecker(name, checker any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddHealthChecker", reflect.TypeOf((*HealthChecker)(nil).AddHealthChecker), name, checker)
+}
+
+// CheckStatus mocks base method.
+func (m *HealthChecker) CheckStatus() healthcheck.HealthResponse {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckStatus")
+	ret0, _ := ret[0].(healthcheck.HealthResponse)
+	return ret0
+}
+
+// CheckStatus indicates an expected call of CheckStatus.
+func (mr *HealthCheckerMockRecorder) CheckStatus() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckStatus", reflect.TypeOf((*HealthChecker)(nil).CheckStatus))
+}
+
+// MakeHandler mocks base method.
+func (m *HealthChecker) MakeHandler(rateLimit ratelimit.Allower) http.HandlerFunc {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakeHandler", rateLimit)
+	ret0, _ := ret[0].(http.HandlerFunc)
+	return ret0
+}
+
+// MakeHandler indicates an expected call of MakeHandler.
+func (mr *HealthCheckerMockRecorder) MakeHandler(rateLimit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeHandler", reflect.TypeOf((*HealthChecker)(nil).MakeHandler), rateLimit)
+}
+
+// RemoveHealthChecker mocks base method.
+func (m *HealthChecker) RemoveHealthChecker(name string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RemoveHealthChecker", name)
+}
+
+// RemoveHealthChecker indicates an expected call of RemoveHealthChecker.
+func (mr *HealthCheckerMockRecorder) RemoveHealthChecker(name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveHealthChecker", reflect.TypeOf((*HealthChecker)(nil).RemoveHealthChecker), name)
+}
@@ -1,5 +1,9 @@
 package database
 
 //go:generate mockgen --build_flags=--mod=mod -destination=./mock/configuration.go -package=mock -mock_names=Configuration=Configuration github.com/cloudtrust/common-service/v2 Configuration
-//go:generate mockgen --build_flags=--mod=mod -destination=./mock/cloudtrustdb.go -package=mock -mock_names=SQLRow=SQLRow,CloudtrustDB=CloudtrustDB,CloudtrustDBFactory=CloudtrustDBFactory github.com/cloudtrust/common-service/v2/database/sqltypes SQLRow,CloudtrustDB,CloudtrustDBFactory
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/cloudtrustdb.go -package=mock -mock_names=SQLRow=SQLRow,SQLRows=SQLRows,CloudtrustDB=CloudtrustDB,CloudtrustDBFactory=CloudtrustDBFactory,Transaction=Transaction github.com/cloudtrust/common-service/v2/database/sqltypes SQLRow,SQLRows,CloudtrustDB,CloudtrustDBFactory,Transaction
 //go:generate mockgen --build_flags=--mod=mod -destination=./mock/database.go -package=mock -mock_names=DbTransactionIntf=DbTransactionIntf github.com/cloudtrust/common-service/v2/database DbTransactionIntf
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/metrics.go -package=mock -mock_names=Metrics=Metrics,Counter=Counter,Gauge=Gauge,Histogram=Histogram github.com/cloudtrust/common-service/v2/metrics Metrics,Counter,Gauge,Histogram
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/tracing.go -package=mock -mock_names=OpentracingClient=OpentracingClient,Finisher=Finisher github.com/cloudtrust/common-service/v2/tracing OpentracingClient,Finisher
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/logging.go -package=mock -mock_names=Logger=Logger github.com/cloudtrust/common-service/v2/log Logger
+//go:generate mockgen --build_flags=--mod=mod -destination=./mock/healthcheck.go -package=mock -mock_names=HealthChecker=HealthChecker github.com/cloudtrust/common-service/v2/healthcheck HealthChecker
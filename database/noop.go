@@ -36,9 +36,9 @@ func (r *NoopSQLRow) Scan(dest ...any) error {
 // NoopDB is a database client that does nothing.
 type NoopDB struct{}
 
-// BeginTx creates a transaction
+// BeginTx creates a transaction that does nothing
 func (db *NoopDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (sqltypes.Transaction, error) {
-	return nil, nil
+	return &NoopTransaction{}, nil
 }
 
 // Exec does nothing.
@@ -56,15 +56,75 @@ func (db *NoopDB) QueryRow(query string, args ...any) sqltypes.SQLRow {
 	return &NoopSQLRow{}
 }
 
+// ExecContext does nothing.
+func (db *NoopDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return NoopResult{}, nil
+}
+
+// QueryContext does nothing.
+func (db *NoopDB) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	return &NoopSQLRows{}, nil
+}
+
+// QueryRowContext does nothing.
+func (db *NoopDB) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	return &NoopSQLRow{}
+}
+
 // Ping does nothing
 func (db *NoopDB) Ping() error { return nil }
 
+// PingContext does nothing
+func (db *NoopDB) PingContext(ctx context.Context) error { return nil }
+
 // Close does nothing
 func (db *NoopDB) Close() error { return nil }
 
 // Stats does nothing
 func (db *NoopDB) Stats() sql.DBStats { return sql.DBStats{} }
 
+// NoopTransaction is a transaction that does nothing.
+type NoopTransaction struct{}
+
+// Commit does nothing
+func (tx *NoopTransaction) Commit() error { return nil }
+
+// Rollback does nothing
+func (tx *NoopTransaction) Rollback() error { return nil }
+
+// Close does nothing
+func (tx *NoopTransaction) Close() error { return nil }
+
+// Exec does nothing.
+func (tx *NoopTransaction) Exec(query string, args ...any) (sql.Result, error) {
+	return NoopResult{}, nil
+}
+
+// Query does nothing.
+func (tx *NoopTransaction) Query(query string, args ...any) (sqltypes.SQLRows, error) {
+	return &NoopSQLRows{}, nil
+}
+
+// QueryRow does nothing.
+func (tx *NoopTransaction) QueryRow(query string, args ...any) sqltypes.SQLRow {
+	return &NoopSQLRow{}
+}
+
+// ExecContext does nothing.
+func (tx *NoopTransaction) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return NoopResult{}, nil
+}
+
+// QueryContext does nothing.
+func (tx *NoopTransaction) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	return &NoopSQLRows{}, nil
+}
+
+// QueryRowContext does nothing.
+func (tx *NoopTransaction) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	return &NoopSQLRow{}
+}
+
 // NoopResult is a sql.Result that does nothing.
 type NoopResult struct{}
 
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,9 +18,28 @@ func TestNoop(t *testing.T) {
 		db.BeginTx(nil, nil)
 		db.Exec("select 1 from dual")
 		db.QueryRow("select count(1) from dual").Scan()
+		db.ExecContext(context.TODO(), "select 1 from dual")
+		db.QueryContext(context.TODO(), "select 1 from dual")
+		db.QueryRowContext(context.TODO(), "select count(1) from dual").Scan()
 		db.Ping()
+		db.PingContext(context.TODO())
 		db.Close()
 	})
+	t.Run("NoopTransaction", func(t *testing.T) {
+		db, _ := cfg.OpenDatabase()
+		tx, err := db.BeginTx(context.TODO(), nil)
+		assert.Nil(t, err)
+		defer tx.Close()
+
+		tx.Exec("select 1 from dual")
+		tx.Query("select 1 from dual")
+		tx.QueryRow("select count(1) from dual").Scan()
+		tx.ExecContext(context.TODO(), "select 1 from dual")
+		tx.QueryContext(context.TODO(), "select 1 from dual")
+		tx.QueryRowContext(context.TODO(), "select count(1) from dual").Scan()
+		assert.Nil(t, tx.Rollback())
+		assert.Nil(t, tx.Commit())
+	})
 	t.Run("NoopResult", func(t *testing.T) {
 		var result NoopResult
 		result.LastInsertId()
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/cloudtrust/common-service/v2/metrics"
+)
+
+// Names of the gauges published by the PoolStatsCollector. Each value is labelled with the name of the database
+const (
+	GaugeDbOpenConnections = "db_pool_open_connections"
+	GaugeDbInUse           = "db_pool_in_use"
+	GaugeDbIdle            = "db_pool_idle"
+	GaugeDbWaitCount       = "db_pool_wait_count"
+	GaugeDbWaitDuration    = "db_pool_wait_duration_ms"
+	GaugeDbReconnections   = "db_reconnections"
+
+	dbNameLabel = "database"
+)
+
+// ReconnectionCounter is implemented by databases able to restore a lost connection (ReconnectableCloudtrustDB)
+type ReconnectionCounter interface {
+	Reconnections() int64
+}
+
+// PoolStatsCollector samples the connection pool statistics of named databases and publishes them as gauges
+type PoolStatsCollector struct {
+	databases     map[string]sqltypes.CloudtrustDB
+	mutex         sync.Mutex
+	logger        log.Logger
+	openConns     metrics.Gauge
+	inUse         metrics.Gauge
+	idle          metrics.Gauge
+	waitCount     metrics.Gauge
+	waitDuration  metrics.Gauge
+	reconnections metrics.Gauge
+}
+
+// NewPoolStatsCollector creates a collector publishing its gauges through the given metrics client
+func NewPoolStatsCollector(m metrics.Metrics, logger log.Logger) *PoolStatsCollector {
+	return &PoolStatsCollector{
+		databases:     make(map[string]sqltypes.CloudtrustDB),
+		logger:        logger,
+		openConns:     m.NewGauge(GaugeDbOpenConnections),
+		inUse:         m.NewGauge(GaugeDbInUse),
+		idle:          m.NewGauge(GaugeDbIdle),
+		waitCount:     m.NewGauge(GaugeDbWaitCount),
+		waitDuration:  m.NewGauge(GaugeDbWaitDuration),
+		reconnections: m.NewGauge(GaugeDbReconnections),
+	}
+}
+
+// Register adds a database to the collector. A database already registered with the same name is replaced
+func (c *PoolStatsCollector) Register(name string, db sqltypes.CloudtrustDB) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.databases[name] = db
+}
+
+// Unregister removes a database from the collector
+func (c *PoolStatsCollector) Unregister(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.databases, name)
+}
+
+// Collect samples the statistics of all the registered databases once
+func (c *PoolStatsCollector) Collect() {
+	c.mutex.Lock()
+	var databases = make(map[string]sqltypes.CloudtrustDB, len(c.databases))
+	var names = make([]string, 0, len(c.databases))
+	for name, db := range c.databases {
+		databases[name] = db
+		names = append(names, name)
+	}
+	c.mutex.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		c.collect(name, databases[name])
+	}
+}
+
+func (c *PoolStatsCollector) collect(name string, db sqltypes.CloudtrustDB) {
+	var stats = db.Stats()
+	c.openConns.With(dbNameLabel, name).Set(float64(stats.OpenConnections))
+	c.inUse.With(dbNameLabel, name).Set(float64(stats.InUse))
+	c.idle.With(dbNameLabel, name).Set(float64(stats.Idle))
+	c.waitCount.With(dbNameLabel, name).Set(float64(stats.WaitCount))
+	c.waitDuration.With(dbNameLabel, name).Set(float64(stats.WaitDuration / time.Millisecond))
+	if counter, ok := reconnectionCounter(db); ok {
+		c.reconnections.With(dbNameLabel, name).Set(float64(counter.Reconnections()))
+	}
+}
+
+// reconnectionCounter looks for a ReconnectionCounter through the decorators of the given database
+func reconnectionCounter(db sqltypes.CloudtrustDB) (ReconnectionCounter, bool) {
+	for {
+		if counter, ok := db.(ReconnectionCounter); ok {
+			return counter, true
+		}
+		var decorator, ok = db.(interface{ Unwrap() sqltypes.CloudtrustDB })
+		if !ok {
+			return nil, false
+		}
+		db = decorator.Unwrap()
+	}
+}
+
+// CollectLoop samples the statistics each time the given channel ticks (usually time.NewTicker(interval).C) until the
+// context is done
+func (c *PoolStatsCollector) CollectLoop(ctx context.Context, ticks <-chan time.Time) {
+	c.logger.Debug(ctx, "msg", "Database pool statistics collection started")
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Debug(ctx, "msg", "Database pool statistics collection stopped")
+			return
+		case <-ticks:
+			c.Collect()
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/mock"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPoolStatsCollector(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockMetrics = mock.NewMetrics(mockCtrl)
+	var gauges = map[string]*mock.Gauge{}
+	for _, name := range []string{GaugeDbOpenConnections, GaugeDbInUse, GaugeDbIdle, GaugeDbWaitCount, GaugeDbWaitDuration, GaugeDbReconnections} {
+		gauges[name] = mock.NewGauge(mockCtrl)
+		mockMetrics.EXPECT().NewGauge(name).Return(gauges[name])
+	}
+	var expectGauge = func(name string, dbName string, value float64) {
+		gauges[name].EXPECT().With(dbNameLabel, dbName).Return(gauges[name])
+		gauges[name].EXPECT().Set(value)
+	}
+
+	var mockDB = mock.NewCloudtrustDB(mockCtrl)
+	var mockDBFactory = mock.NewCloudtrustDBFactory(mockCtrl)
+	var stats = sql.DBStats{OpenConnections: 5, InUse: 3, Idle: 2, WaitCount: 7, WaitDuration: 1500 * time.Millisecond}
+
+	var collector = NewPoolStatsCollector(mockMetrics, log.NewNopLogger())
+
+	t.Run("No database", func(t *testing.T) {
+		collector.Collect()
+	})
+
+	t.Run("Basic database", func(t *testing.T) {
+		collector.Register("audit", mockDB)
+		defer collector.Unregister("audit")
+
+		mockDB.EXPECT().Stats().Return(stats)
+		expectGauge(GaugeDbOpenConnections, "audit", 5)
+		expectGauge(GaugeDbInUse, "audit", 3)
+		expectGauge(GaugeDbIdle, "audit", 2)
+		expectGauge(GaugeDbWaitCount, "audit", 7)
+		expectGauge(GaugeDbWaitDuration, "audit", 1500)
+		collector.Collect()
+	})
+
+	t.Run("Reconnectable database", func(t *testing.T) {
+		mockDBFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
+		var db, _ = NewReconnectableCloudtrustDBExt(mockDBFactory, log.NewNopLogger(), testBackoff)
+		collector.Register("config", db)
+		defer collector.Unregister("config")
+
+		// Lose the connection once
+		mockDB.EXPECT().Ping().Return(sql.ErrConnDone)
+		mockDB.EXPECT().Close().Return(nil)
+		mockDBFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
+		assert.NotNil(t, db.Ping())
+		assert.Eventually(t, func() bool {
+			return db.(*ReconnectableCloudtrustDB).CircuitState() == CircuitClosed
+		}, time.Second, time.Millisecond)
+
+		mockDB.EXPECT().Stats().Return(stats)
+		expectGauge(GaugeDbOpenConnections, "config", 5)
+		expectGauge(GaugeDbInUse, "config", 3)
+		expectGauge(GaugeDbIdle, "config", 2)
+		expectGauge(GaugeDbWaitCount, "config", 7)
+		expectGauge(GaugeDbWaitDuration, "config", 1500)
+		expectGauge(GaugeDbReconnections, "config", 1)
+		collector.Collect()
+	})
+
+	t.Run("Collect loop", func(t *testing.T) {
+		collector.Register("audit", mockDB)
+		defer collector.Unregister("audit")
+
+		var ctx, cancel = context.WithCancel(context.TODO())
+		var ticks = make(chan time.Time)
+		var done = make(chan struct{})
+		go func() {
+			collector.CollectLoop(ctx, ticks)
+			close(done)
+		}()
+
+		mockDB.EXPECT().Stats().Return(sql.DBStats{})
+		for _, gauge := range []string{GaugeDbOpenConnections, GaugeDbInUse, GaugeDbIdle, GaugeDbWaitCount, GaugeDbWaitDuration} {
+			expectGauge(gauge, "audit", 0)
+		}
+		ticks <- time.Now()
+		cancel()
+		<-done
+	})
+}
+
+func TestReconnectionCounterThroughDecorators(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockDB = mock.NewCloudtrustDB(mockCtrl)
+	var mockMetrics = mock.NewMetrics(mockCtrl)
+	mockMetrics.EXPECT().NewHistogram(gomock.Any()).Return(mock.NewHistogram(mockCtrl)).AnyTimes()
+
+	var _, ok = reconnectionCounter(NewInstrumentedDB(mockDB, mockMetrics, nil, nil, InstrumentationOptions{}))
+	assert.False(t, ok)
+
+	var reconnectable = &ReconnectableCloudtrustDB{connection: mockDB}
+	counter, ok := reconnectionCounter(NewInstrumentedDB(reconnectable, mockMetrics, nil, nil, InstrumentationOptions{}))
+	assert.True(t, ok)
+	assert.Equal(t, reconnectable, counter)
+}
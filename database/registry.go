@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	cs "github.com/cloudtrust/common-service/v2"
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/healthcheck"
+	"github.com/cloudtrust/common-service/v2/log"
+)
+
+const defaultHealthCacheDuration = 10 * time.Second
+
+var (
+	// ErrDatabaseAlreadyRegistered is returned when a database name is used twice in a registry
+	ErrDatabaseAlreadyRegistered = errors.New("database already registered")
+)
+
+// RegistryOptions configures a database registry
+type RegistryOptions struct {
+	// HealthChecker receives a health check for each database. Optional
+	HealthChecker healthcheck.HealthChecker
+	// HealthCacheDuration is the cache duration of the health checks. Defaults to 10 seconds
+	HealthCacheDuration time.Duration
+	// PoolStats collects the pool statistics of each database. Optional
+	PoolStats *PoolStatsCollector
+	// Backoff is the policy applied between reconnection attempts. Defaults to DefaultReconnectBackoff()
+	Backoff *BackoffPolicy
+	// RegisterTLSConfig registers the TLS configurations of the databases opened by OpenFromConfig in the MySQL driver
+	// (usually mysql.RegisterTLSConfig). Required when TLS files are configured
+	RegisterTLSConfig func(name string, config *tls.Config) error
+	// Migrations are the migration scripts of the databases opened by OpenFromConfig, by prefix. Optional
+	Migrations map[string]fs.FS
+}
+
+// Registry opens and holds the named databases of a service
+type Registry struct {
+	options   RegistryOptions
+	logger    log.Logger
+	mutex     sync.RWMutex
+	databases map[string]sqltypes.CloudtrustDB
+	opening   map[string]struct{}
+	names     []string
+}
+
+// NewRegistry creates an empty database registry
+func NewRegistry(options RegistryOptions, logger log.Logger) *Registry {
+	if options.HealthCacheDuration <= 0 {
+		options.HealthCacheDuration = defaultHealthCacheDuration
+	}
+	if options.Backoff == nil {
+		var backoff = DefaultReconnectBackoff()
+		options.Backoff = &backoff
+	}
+	return &Registry{
+		options:   options,
+		logger:    logger,
+		databases: make(map[string]sqltypes.CloudtrustDB),
+		opening:   make(map[string]struct{}),
+	}
+}
+
+// OpenFromConfig opens a reconnectable database for each prefix, using the parameters read by GetDbConfig completed with
+// the TLS registration function and the migrations of the options. Databases are named after their prefix and their
+// migrations are logged with the logger of the registry. If a database can't be opened, the ones opened by this call
+// are closed
+func (r *Registry) OpenFromConfig(v cs.Configuration, prefixes ...string) error {
+	var opened []string
+	for _, prefix := range prefixes {
+		var cfg = GetDbConfig(v, prefix)
+		cfg.RegisterTLSConfig = r.options.RegisterTLSConfig
+		cfg.Migrations = r.options.Migrations[prefix]
+		cfg.Logger = r.logger
+		if err := r.Open(prefix, cfg); err != nil {
+			for i := len(opened) - 1; i >= 0; i-- {
+				_ = r.closeDatabase(opened[i])
+			}
+			return err
+		}
+		opened = append(opened, prefix)
+	}
+	return nil
+}
+
+// Open opens a reconnectable database with the given factory (usually a *DbConfig) and registers it. The registry is not
+// locked while the database is opened and migrated: the name is only reserved
+func (r *Registry) Open(name string, dbConnFactory sqltypes.CloudtrustDBFactory) error {
+	if err := r.reserve(name); err != nil {
+		return err
+	}
+	db, err := NewReconnectableCloudtrustDBExt(dbConnFactory, r.logger, *r.options.Backoff)
+
+	r.mutex.Lock()
+	delete(r.opening, name)
+	if err == nil {
+		r.databases[name] = db
+		r.names = append(r.names, name)
+	}
+	r.mutex.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("can't open database %s: %w", name, err)
+	}
+	if r.options.HealthChecker != nil {
+		r.options.HealthChecker.AddDatabase(name, db, r.options.HealthCacheDuration)
+	}
+	if r.options.PoolStats != nil {
+		r.options.PoolStats.Register(name, db)
+	}
+	return nil
+}
+
+func (r *Registry) reserve(name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.databases[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDatabaseAlreadyRegistered, name)
+	}
+	if _, ok := r.opening[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDatabaseAlreadyRegistered, name)
+	}
+	r.opening[name] = struct{}{}
+	return nil
+}
+
+// Get returns the database registered with the given name
+func (r *Registry) Get(name string) (sqltypes.CloudtrustDB, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	db, ok := r.databases[name]
+	return db, ok
+}
+
+// Names returns the names of the registered databases in their registration order
+func (r *Registry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]string(nil), r.names...)
+}
+
+// Close closes all the databases in the reverse order of their registration
+func (r *Registry) Close() error {
+	var names = r.Names()
+	var errs []error
+	for i := len(names) - 1; i >= 0; i-- {
+		if err := r.closeDatabase(names[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Registry) closeDatabase(name string) error {
+	r.mutex.Lock()
+	db, ok := r.databases[name]
+	if ok {
+		delete(r.databases, name)
+		for i, registered := range r.names {
+			if registered == name {
+				r.names = append(r.names[:i], r.names[i+1:]...)
+				break
+			}
+		}
+	}
+	r.mutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+	if r.options.HealthChecker != nil {
+		r.options.HealthChecker.RemoveHealthChecker(name)
+	}
+	if r.options.PoolStats != nil {
+		r.options.PoolStats.Unregister(name)
+	}
+	if err := db.Close(); err != nil {
+		r.logger.Warn(context.Background(), "msg", "Can't close database", "database", name, "err", err.Error())
+		return fmt.Errorf("can't close database %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+
+	"github.com/cloudtrust/common-service/v2/database/mock"
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRegistry(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockHealth = mock.NewHealthChecker(mockCtrl)
+	var mockMetrics = mock.NewMetrics(mockCtrl)
+	mockMetrics.EXPECT().NewGauge(gomock.Any()).Return(mock.NewGauge(mockCtrl)).AnyTimes()
+	var poolStats = NewPoolStatsCollector(mockMetrics, log.NewNopLogger())
+
+	var newRegistry = func() *Registry {
+		return NewRegistry(RegistryOptions{HealthChecker: mockHealth, PoolStats: poolStats, Backoff: &testBackoff}, log.NewNopLogger())
+	}
+	var newDB = func() (*mock.CloudtrustDB, *mock.CloudtrustDBFactory) {
+		var mockDB = mock.NewCloudtrustDB(mockCtrl)
+		var mockFactory = mock.NewCloudtrustDBFactory(mockCtrl)
+		mockFactory.EXPECT().OpenDatabase().Return(mockDB, nil)
+		return mockDB, mockFactory
+	}
+
+	t.Run("Databases are registered and closed in reverse order", func(t *testing.T) {
+		var registry = newRegistry()
+		var auditDB, auditFactory = newDB()
+		var configDB, configFactory = newDB()
+
+		mockHealth.EXPECT().AddDatabase("audit", gomock.Any(), defaultHealthCacheDuration)
+		mockHealth.EXPECT().AddDatabase("config", gomock.Any(), defaultHealthCacheDuration)
+		assert.Nil(t, registry.Open("audit", auditFactory))
+		assert.Nil(t, registry.Open("config", configFactory))
+		assert.Equal(t, []string{"audit", "config"}, registry.Names())
+		assert.Len(t, poolStats.databases, 2)
+
+		var db, ok = registry.Get("audit")
+		assert.True(t, ok)
+		assert.IsType(t, &ReconnectableCloudtrustDB{}, db)
+		_, ok = registry.Get("users")
+		assert.False(t, ok)
+
+		gomock.InOrder(
+			mockHealth.EXPECT().RemoveHealthChecker("config"),
+			configDB.EXPECT().Close().Return(nil),
+			mockHealth.EXPECT().RemoveHealthChecker("audit"),
+			auditDB.EXPECT().Close().Return(nil),
+		)
+		assert.Nil(t, registry.Close())
+		assert.Len(t, registry.Names(), 0)
+		assert.Len(t, poolStats.databases, 0)
+	})
+
+	t.Run("Name already used", func(t *testing.T) {
+		var registry = newRegistry()
+		var auditDB, auditFactory = newDB()
+		mockHealth.EXPECT().AddDatabase("audit", gomock.Any(), gomock.Any())
+		assert.Nil(t, registry.Open("audit", auditFactory))
+
+		var err = registry.Open("audit", mock.NewCloudtrustDBFactory(mockCtrl))
+		assert.ErrorIs(t, err, ErrDatabaseAlreadyRegistered)
+
+		mockHealth.EXPECT().RemoveHealthChecker("audit")
+		auditDB.EXPECT().Close().Return(nil)
+		assert.Nil(t, registry.Close())
+	})
+
+	t.Run("Name is reserved while the database is opened", func(t *testing.T) {
+		var registry = newRegistry()
+		var mockDB = mock.NewCloudtrustDB(mockCtrl)
+		var mockFactory = mock.NewCloudtrustDBFactory(mockCtrl)
+		var opening = make(chan struct{})
+		var release = make(chan struct{})
+		mockFactory.EXPECT().OpenDatabase().DoAndReturn(func() (sqltypes.CloudtrustDB, error) {
+			close(opening)
+			<-release
+			return mockDB, nil
+		})
+		mockHealth.EXPECT().AddDatabase("audit", gomock.Any(), gomock.Any())
+
+		var done = make(chan error)
+		go func() { done <- registry.Open("audit", mockFactory) }()
+		<-opening
+
+		// The registry is not locked during the opening
+		assert.Len(t, registry.Names(), 0)
+		assert.ErrorIs(t, registry.Open("audit", mock.NewCloudtrustDBFactory(mockCtrl)), ErrDatabaseAlreadyRegistered)
+		close(release)
+		assert.Nil(t, <-done)
+
+		mockHealth.EXPECT().RemoveHealthChecker("audit")
+		mockDB.EXPECT().Close().Return(nil)
+		assert.Nil(t, registry.Close())
+	})
+
+	t.Run("Can't open database", func(t *testing.T) {
+		var registry = newRegistry()
+		var openErr = errors.New("open error")
+		var mockFactory = mock.NewCloudtrustDBFactory(mockCtrl)
+		mockFactory.EXPECT().OpenDatabase().Return(nil, openErr)
+
+		var err = registry.Open("audit", mockFactory)
+		assert.ErrorIs(t, err, openErr)
+		assert.Len(t, registry.Names(), 0)
+	})
+
+	t.Run("Close errors are returned", func(t *testing.T) {
+		var registry = NewRegistry(RegistryOptions{}, log.NewNopLogger())
+		var closeErr = errors.New("close error")
+		var auditDB, auditFactory = newDB()
+		var configDB, configFactory = newDB()
+		assert.Nil(t, registry.Open("audit", auditFactory))
+		assert.Nil(t, registry.Open("config", configFactory))
+
+		configDB.EXPECT().Close().Return(closeErr)
+		auditDB.EXPECT().Close().Return(nil)
+		assert.ErrorIs(t, registry.Close(), closeErr)
+		assert.Len(t, registry.Names(), 0)
+	})
+}
+
+func TestRegistryOpenFromConfig(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockConf = mock.NewConfiguration(mockCtrl)
+	var mockHealth = mock.NewHealthChecker(mockCtrl)
+
+	t.Run("Disabled databases", func(t *testing.T) {
+		var registry = NewRegistry(RegistryOptions{HealthChecker: mockHealth}, log.NewNopLogger())
+		mockConf.EXPECT().GetBool("audit-db-enabled").Return(false)
+		mockConf.EXPECT().GetBool("config-db-enabled").Return(false)
+		mockHealth.EXPECT().AddDatabase("audit-db", gomock.Any(), gomock.Any())
+		mockHealth.EXPECT().AddDatabase("config-db", gomock.Any(), gomock.Any())
+
+		assert.Nil(t, registry.OpenFromConfig(mockConf, "audit-db", "config-db"))
+		assert.Equal(t, []string{"audit-db", "config-db"}, registry.Names())
+		mockHealth.EXPECT().RemoveHealthChecker("config-db")
+		mockHealth.EXPECT().RemoveHealthChecker("audit-db")
+		assert.Nil(t, registry.Close())
+	})
+
+	t.Run("Opened databases are closed when one fails", func(t *testing.T) {
+		var registry = NewRegistry(RegistryOptions{HealthChecker: mockHealth}, log.NewNopLogger())
+		mockConf.EXPECT().GetBool("audit-db-enabled").Return(false)
+		mockHealth.EXPECT().AddDatabase("audit-db", gomock.Any(), gomock.Any())
+		// The opened database doesn't stay registered in the health checker
+		mockHealth.EXPECT().RemoveHealthChecker("audit-db")
+		// Schema check enabled without a version
+		mockConf.EXPECT().GetBool("config-db-enabled").Return(true)
+		mockConf.EXPECT().GetBool("config-db-migration").Return(true)
+		mockConf.EXPECT().GetBool(gomock.Any()).Return(false).AnyTimes()
+		mockConf.EXPECT().GetString(gomock.Any()).Return("").AnyTimes()
+		mockConf.EXPECT().GetInt(gomock.Any()).Return(0).AnyTimes()
+		mockConf.EXPECT().GetStringSlice(gomock.Any()).Return(nil).AnyTimes()
+
+		var err = registry.OpenFromConfig(mockConf, "audit-db", "config-db")
+		assert.NotNil(t, err)
+		assert.Len(t, registry.Names(), 0)
+	})
+	t.Run("TLS configuration is registered with the registry function", func(t *testing.T) {
+		var certFile, keyFile = writeTestCertificate(t, t.TempDir())
+		var registered []string
+		var register = func(name string, _ *tls.Config) error {
+			registered = append(registered, name)
+			return nil
+		}
+		var registry = NewRegistry(RegistryOptions{RegisterTLSConfig: register}, log.NewNopLogger())
+		var mockConf = mock.NewConfiguration(mockCtrl)
+		mockConf.EXPECT().GetBool("tls-db-enabled").Return(true)
+		mockConf.EXPECT().GetString("tls-db-tls-ca-file").Return(certFile)
+		mockConf.EXPECT().GetString("tls-db-tls-cert-file").Return(certFile)
+		mockConf.EXPECT().GetString("tls-db-tls-key-file").Return(keyFile)
+		mockConf.EXPECT().GetString("tls-db-protocol").Return("tcp")
+		mockConf.EXPECT().GetString("tls-db-host-port").Return("db.local:3306")
+		mockConf.EXPECT().GetString(gomock.Any()).Return("").AnyTimes()
+		mockConf.EXPECT().GetBool(gomock.Any()).Return(false).AnyTimes()
+		mockConf.EXPECT().GetInt(gomock.Any()).Return(0).AnyTimes()
+		mockConf.EXPECT().GetStringSlice(gomock.Any()).Return(nil).AnyTimes()
+
+		// No MySQL driver is registered in the tests: the connection fails once the TLS configuration is registered
+		var err = registry.OpenFromConfig(mockConf, "tls-db")
+		assert.NotNil(t, err)
+		assert.NotErrorIs(t, err, ErrInvalidDbConfig)
+		assert.Len(t, registered, 1)
+	})
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	cs "github.com/cloudtrust/common-service/v2"
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/cloudtrust/common-service/v2/metrics"
+	"github.com/cloudtrust/common-service/v2/validation"
+)
+
+const (
+	deleteEventsOfType       = `DELETE FROM audit WHERE ct_event_type=? AND audit_time<? LIMIT ?`
+	deleteEventsDefault      = `DELETE FROM audit WHERE audit_time<? LIMIT ?`
+	deleteEventsDefaultTypes = `DELETE FROM audit WHERE audit_time<? AND (ct_event_type IS NULL OR ct_event_type NOT IN (?%s)) LIMIT ?`
+
+	// CounterAuditPurgedEvents is the name of the counter of purged audit events. Values are labelled with the
+	// ct_event_type of the retention rule ("default" for the default retention)
+	CounterAuditPurgedEvents = "audit_purged_events"
+
+	defaultRetentionLabel = "default"
+	defaultPurgeChunkSize = 1000
+)
+
+var (
+	// ErrInvalidRetentionPolicy is returned when the audit retention policy is not valid
+	ErrInvalidRetentionPolicy = errors.New("invalid audit retention policy")
+)
+
+// RetentionPolicy defines how long audit events are kept. Durations are large durations as accepted by the validation
+// package (for instance 1y6m)
+type RetentionPolicy struct {
+	// Default retention of the events. Empty to keep the events which do not match an override forever
+	Default string
+	// ByEventType overrides the default retention for some ct_event_type values
+	ByEventType map[string]string
+	// ChunkSize is the maximum number of events deleted by a single statement. Defaults to 1000
+	ChunkSize int
+}
+
+// GetRetentionPolicy gets the audit retention policy from the configuration
+// For its configuration, parameters are built with the given prefix, then a dash symbol, then one of these suffixes:
+// retention, retention-overrides (list of <ct_event_type>=<duration>), retention-chunk-size
+func GetRetentionPolicy(v cs.Configuration, prefix string) (RetentionPolicy, error) {
+	var policy = RetentionPolicy{
+		Default:     v.GetString(prefix + "-retention"),
+		ByEventType: make(map[string]string),
+		ChunkSize:   v.GetInt(prefix + "-retention-chunk-size"),
+	}
+	for _, override := range v.GetStringSlice(prefix + "-retention-overrides") {
+		var parts = strings.SplitN(override, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return RetentionPolicy{}, fmt.Errorf("%w: %s-retention-overrides values must be formatted as <ct_event_type>=<duration>", ErrInvalidRetentionPolicy, prefix)
+		}
+		policy.ByEventType[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return policy, policy.Validate()
+}
+
+// Validate checks the retention durations. Returns an error wrapping ErrInvalidRetentionPolicy
+func (p RetentionPolicy) Validate() error {
+	if p.Default != "" && !validation.IsValidLargeDuration(p.Default) {
+		return fmt.Errorf("%w: invalid default retention %s", ErrInvalidRetentionPolicy, p.Default)
+	}
+	for _, eventType := range p.eventTypes() {
+		if !validation.IsValidLargeDuration(p.ByEventType[eventType]) {
+			return fmt.Errorf("%w: invalid retention %s for %s", ErrInvalidRetentionPolicy, p.ByEventType[eventType], eventType)
+		}
+	}
+	return nil
+}
+
+func (p RetentionPolicy) eventTypes() []string {
+	var res = make([]string, 0, len(p.ByEventType))
+	for eventType := range p.ByEventType {
+		res = append(res, eventType)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// AuditPurger deletes the audit events older than their retention
+type AuditPurger struct {
+	db      sqltypes.CloudtrustDB
+	policy  RetentionPolicy
+	counter metrics.Counter
+	logger  log.Logger
+	now     func() time.Time
+}
+
+// NewAuditPurger creates an audit purger. Fails if the retention policy is not valid
+func NewAuditPurger(db sqltypes.CloudtrustDB, policy RetentionPolicy, m metrics.Metrics, logger log.Logger) (*AuditPurger, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if policy.ChunkSize <= 0 {
+		policy.ChunkSize = defaultPurgeChunkSize
+	}
+	return &AuditPurger{
+		db:      db,
+		policy:  policy,
+		counter: m.NewCounter(CounterAuditPurgedEvents),
+		logger:  logger,
+		now:     time.Now,
+	}, nil
+}
+
+// Purge deletes the expired events and returns the number of deleted events
+func (p *AuditPurger) Purge(ctx context.Context) (int64, error) {
+	var now = p.now().UTC()
+	var total int64
+
+	var eventTypes = p.policy.eventTypes()
+	for _, eventType := range eventTypes {
+		var limit = validation.SubstractLargeDuration(now, p.policy.ByEventType[eventType]).Format(AuditTimeFormat)
+		var count, err = p.purgeByChunks(ctx, eventType, deleteEventsOfType, eventType, limit)
+		total += count
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if p.policy.Default != "" {
+		var limit = validation.SubstractLargeDuration(now, p.policy.Default).Format(AuditTimeFormat)
+		var stmt = deleteEventsDefault
+		var args = []any{limit}
+		if len(eventTypes) > 0 {
+			stmt = fmt.Sprintf(deleteEventsDefaultTypes, strings.Repeat(",?", len(eventTypes)-1))
+			for _, eventType := range eventTypes {
+				args = append(args, eventType)
+			}
+		}
+		var count, err = p.purgeByChunks(ctx, defaultRetentionLabel, stmt, args...)
+		total += count
+		if err != nil {
+			return total, err
+		}
+	}
+
+	p.logger.Info(ctx, "msg", "Audit events purged", "count", total)
+	return total, nil
+}
+
+// purgeByChunks executes the given DELETE statement until it deletes less than a chunk
+func (p *AuditPurger) purgeByChunks(ctx context.Context, label string, stmt string, args ...any) (int64, error) {
+	var total int64
+	args = append(args, p.policy.ChunkSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		var res, err = p.db.ExecContext(ctx, stmt, args...)
+		if err != nil {
+			p.logger.Error(ctx, "msg", "Can't purge audit events", "retention", label, "err", err.Error())
+			return total, err
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		if count > 0 {
+			total += count
+			p.counter.With("ct_event_type", label).Add(float64(count))
+		}
+		if count < int64(p.policy.ChunkSize) {
+			return total, nil
+		}
+	}
+}
+
+// PurgeLoop purges the expired events each time the given channel ticks (usually time.NewTicker(interval).C) until the
+// context is done
+func (p *AuditPurger) PurgeLoop(ctx context.Context, ticks <-chan time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+			_, _ = p.Purge(ctx)
+		}
+	}
+}
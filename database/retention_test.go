@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/dbtest"
+	"github.com/cloudtrust/common-service/v2/database/mock"
+	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetRetentionPolicy(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+	var mockConf = mock.NewConfiguration(mockCtrl)
+
+	t.Run("Valid policy", func(t *testing.T) {
+		mockConf.EXPECT().GetString("audit-retention").Return("1y6m")
+		mockConf.EXPECT().GetInt("audit-retention-chunk-size").Return(500)
+		mockConf.EXPECT().GetStringSlice("audit-retention-overrides").Return([]string{"LOGIN=3m", " GET_USER = 2w"})
+		policy, err := GetRetentionPolicy(mockConf, "audit")
+		assert.Nil(t, err)
+		assert.Equal(t, RetentionPolicy{Default: "1y6m", ByEventType: map[string]string{"LOGIN": "3m", "GET_USER": "2w"}, ChunkSize: 500}, policy)
+	})
+	t.Run("Invalid override format", func(t *testing.T) {
+		mockConf.EXPECT().GetString("audit-retention").Return("")
+		mockConf.EXPECT().GetInt("audit-retention-chunk-size").Return(0)
+		mockConf.EXPECT().GetStringSlice("audit-retention-overrides").Return([]string{"LOGIN"})
+		_, err := GetRetentionPolicy(mockConf, "audit")
+		assert.ErrorIs(t, err, ErrInvalidRetentionPolicy)
+		assert.Contains(t, err.Error(), "audit-retention-overrides")
+	})
+	t.Run("Invalid duration", func(t *testing.T) {
+		mockConf.EXPECT().GetString("audit-retention").Return("1y")
+		mockConf.EXPECT().GetInt("audit-retention-chunk-size").Return(0)
+		mockConf.EXPECT().GetStringSlice("audit-retention-overrides").Return([]string{"LOGIN=soon"})
+		_, err := GetRetentionPolicy(mockConf, "audit")
+		assert.ErrorIs(t, err, ErrInvalidRetentionPolicy)
+	})
+}
+
+func TestAuditPurger(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockMetrics = mock.NewMetrics(mockCtrl)
+	var mockCounter = mock.NewCounter(mockCtrl)
+	var ctx = context.TODO()
+	var now = time.Date(2024, 9, 15, 12, 0, 0, 0, time.UTC)
+	mockMetrics.EXPECT().NewCounter(CounterAuditPurgedEvents).Return(mockCounter).AnyTimes()
+
+	t.Run("Invalid policy", func(t *testing.T) {
+		_, err := NewAuditPurger(dbtest.NewFakeDB(), RetentionPolicy{Default: "forever"}, mockMetrics, log.NewNopLogger())
+		assert.ErrorIs(t, err, ErrInvalidRetentionPolicy)
+	})
+	t.Run("Purge by chunks with overrides", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		db.On("ct_event_type=?").WithArgs("LOGIN", "2024-06-15 12:00:00.000", 2).WithRowsAffected(2).Once()
+		db.On("ct_event_type=?").WithArgs("LOGIN", "2024-06-15 12:00:00.000", 2).WithRowsAffected(1).Once()
+		db.On("ct_event_type=?").WithArgs("LOGOUT", "2024-09-01 12:00:00.000", 2).WithRowsAffected(0)
+		db.On("NOT IN").WithArgs("2023-03-15 12:00:00.000", "LOGIN", "LOGOUT", 2).WithRowsAffected(1)
+
+		var purger, err = NewAuditPurger(db, RetentionPolicy{
+			Default:     "1y6m",
+			ByEventType: map[string]string{"LOGIN": "3m", "LOGOUT": "2w"},
+			ChunkSize:   2,
+		}, mockMetrics, log.NewNopLogger())
+		assert.Nil(t, err)
+		purger.now = func() time.Time { return now }
+
+		mockCounter.EXPECT().With("ct_event_type", "LOGIN").Return(mockCounter).Times(2)
+		mockCounter.EXPECT().Add(2.0)
+		mockCounter.EXPECT().Add(1.0).Times(2)
+		mockCounter.EXPECT().With("ct_event_type", "default").Return(mockCounter)
+
+		count, err := purger.Purge(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), count)
+		assert.Len(t, db.Statements(), 4)
+		assert.Equal(t, "DELETE FROM audit WHERE audit_time<? AND (ct_event_type IS NULL OR ct_event_type NOT IN (?,?)) LIMIT ?", db.Statements()[3].Query)
+	})
+	t.Run("Default retention only", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		db.On("^DELETE FROM audit WHERE audit_time<\\? LIMIT \\?$").WithArgs("2024-09-14 12:00:00.000", defaultPurgeChunkSize)
+
+		var purger, _ = NewAuditPurger(db, RetentionPolicy{Default: "1d"}, mockMetrics, log.NewNopLogger())
+		purger.now = func() time.Time { return now }
+
+		count, err := purger.Purge(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), count)
+	})
+	t.Run("Delete fails", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		var anyError = errors.New("any error")
+		db.On("DELETE").WithError(anyError)
+
+		var purger, _ = NewAuditPurger(db, RetentionPolicy{ByEventType: map[string]string{"LOGIN": "1d"}}, mockMetrics, log.NewNopLogger())
+		_, err := purger.Purge(ctx)
+		assert.Equal(t, anyError, err)
+	})
+	t.Run("Scheduled purge", func(t *testing.T) {
+		var db = dbtest.NewFakeDB()
+		db.On("DELETE")
+		var purger, _ = NewAuditPurger(db, RetentionPolicy{Default: "1y"}, mockMetrics, log.NewNopLogger())
+
+		var loopCtx, cancel = context.WithCancel(ctx)
+		var ticks = make(chan time.Time)
+		var done = make(chan struct{})
+		go func() {
+			purger.PurgeLoop(loopCtx, ticks)
+			close(done)
+		}()
+		ticks <- time.Now()
+		ticks <- time.Now()
+		assert.Eventually(t, func() bool { return len(db.Statements()) == 2 }, time.Second, time.Millisecond)
+		cancel()
+		<-done
+	})
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+)
+
+const (
+	defaultReplicaRetryDelay = 30 * time.Second
+)
+
+type replicaDB struct {
+	db sqltypes.CloudtrustDB
+	// unhealthyUntil is a UnixNano timestamp. The replica is not used before this time
+	unhealthyUntil atomic.Int64
+}
+
+// routingCloudtrustDB sends read-only queries (Query, QueryRow) to read replicas and any other statement to the primary
+// database. Replicas are used in a round-robin way. When a replica fails, it is excluded for a while and the query is sent
+// to the next replica or, if none is available, to the primary database
+type routingCloudtrustDB struct {
+	primary    sqltypes.CloudtrustDB
+	replicas   []*replicaDB
+	next       atomic.Uint64
+	retryDelay time.Duration
+	now        func() time.Time
+}
+
+// NewRoutingCloudtrustDB creates a CloudtrustDB which sends Exec and BeginTx to the primary database and Query/QueryRow to
+// the healthy replicas
+func NewRoutingCloudtrustDB(primary sqltypes.CloudtrustDB, replicas []sqltypes.CloudtrustDB) sqltypes.CloudtrustDB {
+	var res = &routingCloudtrustDB{
+		primary:    primary,
+		retryDelay: defaultReplicaRetryDelay,
+		now:        time.Now,
+	}
+	for _, replica := range replicas {
+		res.replicas = append(res.replicas, &replicaDB{db: replica})
+	}
+	return res
+}
+
+func (r *routingCloudtrustDB) isHealthy(replica *replicaDB) bool {
+	return r.now().UnixNano() >= replica.unhealthyUntil.Load()
+}
+
+func (r *routingCloudtrustDB) markUnhealthy(replica *replicaDB) {
+	replica.unhealthyUntil.Store(r.now().Add(r.retryDelay).UnixNano())
+}
+
+// healthyReplicas returns the replicas which can currently be used, starting with the next one in round-robin order
+func (r *routingCloudtrustDB) healthyReplicas() []*replicaDB {
+	var count = len(r.replicas)
+	if count == 0 {
+		return nil
+	}
+	var start = int(r.next.Add(1) % uint64(count))
+	var res []*replicaDB
+	for i := 0; i < count; i++ {
+		var replica = r.replicas[(start+i)%count]
+		if r.isHealthy(replica) {
+			res = append(res, replica)
+		}
+	}
+	return res
+}
+
+// checkReplicaError returns true if the replica failed because it is not reachable
+func (r *routingCloudtrustDB) checkReplicaError(replica *replicaDB, err error) bool {
+	if err == nil || err == sql.ErrNoRows {
+		return false
+	}
+	if replica.db.Ping() != nil {
+		r.markUnhealthy(replica)
+		return true
+	}
+	return false
+}
+
+func (r *routingCloudtrustDB) routeQuery(query func(db sqltypes.CloudtrustDB) (sqltypes.SQLRows, error)) (sqltypes.SQLRows, error) {
+	for _, replica := range r.healthyReplicas() {
+		var rows, err = query(replica.db)
+		if !r.checkReplicaError(replica, err) {
+			return rows, err
+		}
+	}
+	return query(r.primary)
+}
+
+func (r *routingCloudtrustDB) routeQueryRow(queryRow func(db sqltypes.CloudtrustDB) sqltypes.SQLRow) sqltypes.SQLRow {
+	var replicas = r.healthyReplicas()
+	if len(replicas) == 0 {
+		return queryRow(r.primary)
+	}
+	// Errors are only known when the row is scanned: the query can't be sent again to another database but the replica
+	// health is updated for the next queries
+	return &replicaSQLRow{row: queryRow(replicas[0].db), replica: replicas[0], router: r}
+}
+
+type replicaSQLRow struct {
+	row     sqltypes.SQLRow
+	replica *replicaDB
+	router  *routingCloudtrustDB
+}
+
+func (r *replicaSQLRow) Scan(dest ...any) error {
+	var err = r.row.Scan(dest...)
+	r.router.checkReplicaError(r.replica, err)
+	return err
+}
+
+// BeginTx creates a transaction on the primary database
+func (r *routingCloudtrustDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (sqltypes.Transaction, error) {
+	return r.primary.BeginTx(ctx, opts)
+}
+
+// Exec executes an SQL statement on the primary database
+func (r *routingCloudtrustDB) Exec(query string, args ...any) (sql.Result, error) {
+	return r.primary.Exec(query, args...)
+}
+
+// Query a multiple-rows SQL result on a replica
+func (r *routingCloudtrustDB) Query(query string, args ...any) (sqltypes.SQLRows, error) {
+	return r.routeQuery(func(db sqltypes.CloudtrustDB) (sqltypes.SQLRows, error) {
+		return db.Query(query, args...)
+	})
+}
+
+// QueryRow a single-row SQL result on a replica
+func (r *routingCloudtrustDB) QueryRow(query string, args ...any) sqltypes.SQLRow {
+	return r.routeQueryRow(func(db sqltypes.CloudtrustDB) sqltypes.SQLRow {
+		return db.QueryRow(query, args...)
+	})
+}
+
+// ExecContext executes an SQL statement on the primary database
+func (r *routingCloudtrustDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+// QueryContext queries a multiple-rows SQL result on a replica
+func (r *routingCloudtrustDB) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	return r.routeQuery(func(db sqltypes.CloudtrustDB) (sqltypes.SQLRows, error) {
+		return db.QueryContext(ctx, query, args...)
+	})
+}
+
+// QueryRowContext queries a single-row SQL result on a replica
+func (r *routingCloudtrustDB) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	return r.routeQueryRow(func(db sqltypes.CloudtrustDB) sqltypes.SQLRow {
+		return db.QueryRowContext(ctx, query, args...)
+	})
+}
+
+// Ping checks the primary database. Replicas health is also refreshed
+func (r *routingCloudtrustDB) Ping() error {
+	return r.PingContext(context.Background())
+}
+
+// PingContext checks the primary database. Replicas health is also refreshed, unless the context is done: the ping then
+// fails because of the caller, not of the replica
+func (r *routingCloudtrustDB) PingContext(ctx context.Context) error {
+	for _, replica := range r.replicas {
+		if replica.db.PingContext(ctx) == nil {
+			replica.unhealthyUntil.Store(0)
+		} else if ctx.Err() == nil {
+			r.markUnhealthy(replica)
+		}
+	}
+	return r.primary.PingContext(ctx)
+}
+
+// Close closes the primary database and all the replicas
+func (r *routingCloudtrustDB) Close() error {
+	var err = r.primary.Close()
+	for _, replica := range r.replicas {
+		if errReplica := replica.db.Close(); err == nil {
+			err = errReplica
+		}
+	}
+	return err
+}
+
+// Stats returns the statistics of the primary database
+func (r *routingCloudtrustDB) Stats() sql.DBStats {
+	return r.primary.Stats()
+}
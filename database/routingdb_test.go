@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/mock"
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRoutingCloudtrustDB(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var primary = mock.NewCloudtrustDB(mockCtrl)
+	var replica1 = mock.NewCloudtrustDB(mockCtrl)
+	var replica2 = mock.NewCloudtrustDB(mockCtrl)
+	var anyError = errors.New("any error")
+	var ctx = context.TODO()
+	var now = time.Now()
+
+	var newRoutingDB = func() *routingCloudtrustDB {
+		var db = NewRoutingCloudtrustDB(primary, []sqltypes.CloudtrustDB{replica1, replica2}).(*routingCloudtrustDB)
+		db.now = func() time.Time { return now }
+		return db
+	}
+
+	t.Run("Writes are sent to the primary", func(t *testing.T) {
+		var db = newRoutingDB()
+		primary.EXPECT().Exec("update", 1).Return(nil, nil)
+		primary.EXPECT().ExecContext(ctx, "update", 2).Return(nil, nil)
+		primary.EXPECT().BeginTx(ctx, nil).Return(nil, nil)
+		primary.EXPECT().Stats().Return(sql.DBStats{OpenConnections: 3})
+
+		_, _ = db.Exec("update", 1)
+		_, _ = db.ExecContext(ctx, "update", 2)
+		_, _ = db.BeginTx(ctx, nil)
+		assert.Equal(t, 3, db.Stats().OpenConnections)
+	})
+	t.Run("Queries are sent to replicas in round-robin", func(t *testing.T) {
+		var db = newRoutingDB()
+		var row = sqltypes.NewSQLRowError(sql.ErrNoRows)
+		replica2.EXPECT().Query("select", 1).Return(nil, nil)
+		replica1.EXPECT().QueryContext(ctx, "select", 2).Return(nil, nil)
+		replica2.EXPECT().QueryRow("select", 3).Return(row)
+		replica1.EXPECT().QueryRowContext(ctx, "select", 4).Return(row)
+
+		_, err := db.Query("select", 1)
+		assert.Nil(t, err)
+		_, err = db.QueryContext(ctx, "select", 2)
+		assert.Nil(t, err)
+		assert.Equal(t, sql.ErrNoRows, db.QueryRow("select", 3).Scan())
+		assert.Equal(t, sql.ErrNoRows, db.QueryRowContext(ctx, "select", 4).Scan())
+	})
+	t.Run("SQL error on a healthy replica is returned", func(t *testing.T) {
+		var db = newRoutingDB()
+		replica2.EXPECT().Query("select").Return(nil, anyError)
+		replica2.EXPECT().Ping().Return(nil)
+
+		_, err := db.Query("select")
+		assert.Equal(t, anyError, err)
+	})
+	t.Run("Failover to the next replica, then to the primary", func(t *testing.T) {
+		var db = newRoutingDB()
+		replica2.EXPECT().Query("select").Return(nil, anyError)
+		replica2.EXPECT().Ping().Return(anyError)
+		replica1.EXPECT().Query("select").Return(nil, anyError)
+		replica1.EXPECT().Ping().Return(anyError)
+		primary.EXPECT().Query("select").Return(nil, nil)
+
+		_, err := db.Query("select")
+		assert.Nil(t, err)
+
+		// Both replicas are now excluded
+		primary.EXPECT().QueryRow("select").Return(sqltypes.NewSQLRowError(nil))
+		assert.Nil(t, db.QueryRow("select").Scan())
+
+		// Replicas are used again after the retry delay
+		now = now.Add(defaultReplicaRetryDelay)
+		replica2.EXPECT().Query("select").Return(nil, nil)
+		_, err = db.Query("select")
+		assert.Nil(t, err)
+	})
+	t.Run("Failing QueryRow excludes the replica for next queries", func(t *testing.T) {
+		var db = newRoutingDB()
+		replica2.EXPECT().QueryRow("select").Return(sqltypes.NewSQLRowError(anyError))
+		replica2.EXPECT().Ping().Return(anyError)
+		assert.Equal(t, anyError, db.QueryRow("select").Scan())
+
+		replica1.EXPECT().Query("select").Return(nil, nil)
+		_, _ = db.Query("select")
+		replica1.EXPECT().Query("select").Return(nil, nil)
+		_, _ = db.Query("select")
+	})
+	t.Run("Ping refreshes replicas health", func(t *testing.T) {
+		var db = newRoutingDB()
+		replica1.EXPECT().PingContext(gomock.Any()).Return(anyError)
+		replica2.EXPECT().PingContext(gomock.Any()).Return(nil)
+		primary.EXPECT().PingContext(gomock.Any()).Return(nil)
+		assert.Nil(t, db.Ping())
+		assert.False(t, db.isHealthy(db.replicas[0]))
+		assert.True(t, db.isHealthy(db.replicas[1]))
+	})
+	t.Run("Cancelled ping doesn't change replicas health", func(t *testing.T) {
+		var db = newRoutingDB()
+		var cancelledCtx, cancel = context.WithCancel(ctx)
+		cancel()
+		replica1.EXPECT().PingContext(cancelledCtx).Return(context.Canceled)
+		replica2.EXPECT().PingContext(cancelledCtx).Return(context.Canceled)
+		primary.EXPECT().PingContext(cancelledCtx).Return(context.Canceled)
+		assert.Equal(t, context.Canceled, db.PingContext(cancelledCtx))
+		assert.True(t, db.isHealthy(db.replicas[0]))
+		assert.True(t, db.isHealthy(db.replicas[1]))
+	})
+	t.Run("Close", func(t *testing.T) {
+		var db = newRoutingDB()
+		primary.EXPECT().Close().Return(nil)
+		replica1.EXPECT().Close().Return(anyError)
+		replica2.EXPECT().Close().Return(nil)
+		assert.Equal(t, anyError, db.Close())
+	})
+}
+
+func TestRoutingCloudtrustDBWithoutReplica(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var primary = mock.NewCloudtrustDB(mockCtrl)
+	var db = NewRoutingCloudtrustDB(primary, nil)
+
+	primary.EXPECT().Query("select").Return(nil, nil)
+	primary.EXPECT().QueryRow("select").Return(nil)
+	_, _ = db.Query("select")
+	_ = db.QueryRow("select")
+}
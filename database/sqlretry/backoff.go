@@ -0,0 +1,40 @@
+// Package sqlretry retries the MySQL transactions which fail with transient errors (deadlocks, lock wait timeouts).
+// It only depends on sqltypes so that it can be used by the packages which the database package itself depends on
+package sqlretry
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// maxBackoffInterval bounds the delays when MaxInterval is not set, so that the exponential growth can't overflow
+const maxBackoffInterval = time.Hour
+
+// BackoffPolicy defines an exponential backoff: the first delay is InitialInterval, then each delay is the previous
+// one multiplied by Multiplier. Delays never exceed MaxInterval (or one hour if MaxInterval is not set)
+// Jitter (between 0 and 1) randomizes each delay by plus or minus the given ratio so that several instances don't retry
+// at the same time
+type BackoffPolicy struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	Jitter          float64
+}
+
+// Interval returns the delay to wait before the given attempt (attempt 0 is the first retry)
+func (p BackoffPolicy) Interval(attempt int) time.Duration {
+	var maxInterval = maxBackoffInterval
+	if p.MaxInterval > 0 {
+		maxInterval = p.MaxInterval
+	}
+	var interval = float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(attempt))
+	// Also catches +Inf and NaN: the conversion of such values to time.Duration is undefined
+	if !(interval <= float64(maxInterval)) {
+		interval = float64(maxInterval)
+	}
+	if p.Jitter > 0 {
+		interval *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(interval)
+}
@@ -0,0 +1,40 @@
+package sqlretry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffPolicy(t *testing.T) {
+	var policy = BackoffPolicy{InitialInterval: 10 * time.Millisecond, MaxInterval: 50 * time.Millisecond, Multiplier: 2}
+
+	assert.Equal(t, 10*time.Millisecond, policy.Interval(0))
+	assert.Equal(t, 20*time.Millisecond, policy.Interval(1))
+	assert.Equal(t, 40*time.Millisecond, policy.Interval(2))
+	assert.Equal(t, 50*time.Millisecond, policy.Interval(3))
+	assert.Equal(t, 50*time.Millisecond, policy.Interval(10))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		var interval = policy.Interval(1)
+		assert.True(t, interval >= 10*time.Millisecond && interval <= 30*time.Millisecond, interval.String())
+	}
+}
+
+func TestBackoffPolicyLargeAttempt(t *testing.T) {
+	var policy = BackoffPolicy{InitialInterval: time.Second, Multiplier: 2}
+
+	assert.Equal(t, maxBackoffInterval, policy.Interval(100))
+	assert.Equal(t, maxBackoffInterval, policy.Interval(100000))
+
+	policy.MaxInterval = 30 * time.Second
+	assert.Equal(t, 30*time.Second, policy.Interval(100000))
+
+	policy = BackoffPolicy{InitialInterval: time.Second, Multiplier: 2, Jitter: 0.5}
+	for i := 0; i < 20; i++ {
+		var interval = policy.Interval(5000)
+		assert.True(t, interval > 0 && interval <= 3*maxBackoffInterval/2, interval.String())
+	}
+}
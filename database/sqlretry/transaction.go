@@ -0,0 +1,105 @@
+package sqlretry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+)
+
+// MySQL error codes which denote a transaction which can be safely retried
+const (
+	MySQLErrLockWaitTimeout uint16 = 1205
+	MySQLErrLockDeadlock    uint16 = 1213
+)
+
+// The MySQL driver formats its errors as "Error 1213 (40001): Deadlock found..." (or "Error 1213: ..." for older versions)
+var mysqlErrorCodeRegexp = regexp.MustCompile(`^Error (\d+)[ :]`)
+
+// RetryPolicy defines how a transaction is retried when it fails with a retryable MySQL error
+type RetryPolicy struct {
+	MaxAttempts         int
+	Backoff             BackoffPolicy
+	RetryableErrorCodes []uint16
+}
+
+// TransactionOptions are the options used by WithTransaction
+type TransactionOptions struct {
+	TxOptions   *sql.TxOptions
+	RetryPolicy RetryPolicy
+}
+
+// DefaultRetryPolicy returns a retry policy which retries deadlocks and lock wait timeouts up to 3 times
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		Backoff: BackoffPolicy{
+			InitialInterval: 50 * time.Millisecond,
+			MaxInterval:     time.Second,
+			Multiplier:      2,
+		},
+		RetryableErrorCodes: []uint16{MySQLErrLockDeadlock, MySQLErrLockWaitTimeout},
+	}
+}
+
+// MySQLErrorCode returns the MySQL error code of the given error or of one of the errors it wraps
+func MySQLErrorCode(err error) (uint16, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if match := mysqlErrorCodeRegexp.FindStringSubmatch(err.Error()); match != nil {
+			if code, convErr := strconv.ParseUint(match[1], 10, 16); convErr == nil {
+				return uint16(code), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (p RetryPolicy) isRetryable(err error) bool {
+	var code, ok = MySQLErrorCode(err)
+	return ok && slices.Contains(p.RetryableErrorCodes, code)
+}
+
+// WithTransaction executes the given function in a transaction. The transaction is committed if the function succeeds and
+// rolled back if it returns an error or panics. When the transaction fails with a retryable MySQL error (deadlock, lock wait
+// timeout, ...), the whole function is executed again in a new transaction according to the retry policy.
+// If opts is nil, default transaction options and DefaultRetryPolicy() are used
+func WithTransaction(ctx context.Context, db sqltypes.CloudtrustDB, opts *TransactionOptions, fn func(tx sqltypes.Transaction) error) error {
+	if opts == nil {
+		opts = &TransactionOptions{RetryPolicy: DefaultRetryPolicy()}
+	}
+	var policy = opts.RetryPolicy
+
+	for attempt := 1; ; attempt++ {
+		var err = runTransaction(ctx, db, opts.TxOptions, fn)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.isRetryable(err) {
+			return err
+		}
+
+		var timer = time.NewTimer(policy.Backoff.Interval(attempt - 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func runTransaction(ctx context.Context, db sqltypes.CloudtrustDB, txOptions *sql.TxOptions, fn func(tx sqltypes.Transaction) error) error {
+	var tx, err = db.BeginTx(ctx, txOptions)
+	if err != nil {
+		return err
+	}
+	// Close rolls back the transaction if it has not been committed, including when fn panics
+	defer tx.Close()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
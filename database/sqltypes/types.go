@@ -38,7 +38,11 @@ type CloudtrustDB interface {
 	Exec(query string, args ...any) (sql.Result, error)
 	Query(query string, args ...any) (SQLRows, error)
 	QueryRow(query string, args ...any) SQLRow
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (SQLRows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) SQLRow
 	Ping() error
+	PingContext(ctx context.Context) error
 	Close() error
 	Stats() sql.DBStats
 }
@@ -59,4 +63,7 @@ type Transaction interface {
 	Exec(query string, args ...any) (sql.Result, error)
 	Query(query string, args ...any) (SQLRows, error)
 	QueryRow(query string, args ...any) SQLRow
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (SQLRows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) SQLRow
 }
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/cloudtrust/common-service/v2/database/sqltypes"
@@ -19,6 +20,9 @@ type DbTransactionIntf interface {
 	Exec(query string, args ...any) (sql.Result, error)
 	Query(query string, args ...any) (*sql.Rows, error)
 	QueryRow(query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 // NewTransaction creates a transaction
@@ -60,3 +64,15 @@ func (tx *dbTransaction) Query(query string, args ...any) (sqltypes.SQLRows, err
 func (tx *dbTransaction) QueryRow(query string, args ...any) sqltypes.SQLRow {
 	return tx.tx.QueryRow(query, args...)
 }
+
+func (tx *dbTransaction) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.tx.ExecContext(ctx, query, args...)
+}
+
+func (tx *dbTransaction) QueryContext(ctx context.Context, query string, args ...any) (sqltypes.SQLRows, error) {
+	return tx.tx.QueryContext(ctx, query, args...)
+}
+
+func (tx *dbTransaction) QueryRowContext(ctx context.Context, query string, args ...any) sqltypes.SQLRow {
+	return tx.tx.QueryRowContext(ctx, query, args...)
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -17,6 +18,7 @@ func TestTransaction(t *testing.T) {
 	var sqlError = errors.New("I'm a SQL error")
 	var query = "select columns from table"
 	var param1 = "param1"
+	var ctx = context.TODO()
 
 	t.Run("Exec", func(t *testing.T) {
 		var tx = NewTransaction(mockTx)
@@ -48,4 +50,31 @@ func TestTransaction(t *testing.T) {
 		// Force commit... tx.Close() won't have to rollback
 		tx.Commit()
 	})
+	t.Run("ExecContext", func(t *testing.T) {
+		var tx = NewTransaction(mockTx)
+		defer tx.Close()
+
+		mockTx.EXPECT().ExecContext(ctx, query, param1).Return(nil, sqlError)
+		mockTx.EXPECT().Rollback().Return(nil)
+		var _, err = tx.ExecContext(ctx, query, param1)
+		assert.Equal(t, sqlError, err)
+	})
+	t.Run("QueryContext", func(t *testing.T) {
+		var tx = NewTransaction(mockTx)
+		defer tx.Close()
+
+		mockTx.EXPECT().QueryContext(ctx, query, param1).Return(nil, sqlError)
+		mockTx.EXPECT().Rollback().Return(nil)
+		var _, err = tx.QueryContext(ctx, query, param1)
+		assert.Equal(t, sqlError, err)
+	})
+	t.Run("QueryRowContext", func(t *testing.T) {
+		var tx = NewTransaction(mockTx)
+		defer tx.Close()
+
+		mockTx.EXPECT().QueryRowContext(ctx, query, param1).Return(nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		assert.Nil(t, tx.QueryRowContext(ctx, query, param1))
+		tx.Commit()
+	})
 }
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+
+	"github.com/cloudtrust/common-service/v2/database/sqlretry"
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+)
+
+// MySQL error codes which denote a transaction which can be safely retried
+const (
+	MySQLErrLockWaitTimeout = sqlretry.MySQLErrLockWaitTimeout
+	MySQLErrLockDeadlock    = sqlretry.MySQLErrLockDeadlock
+)
+
+// RetryPolicy defines how a transaction is retried. See sqlretry.RetryPolicy
+type RetryPolicy = sqlretry.RetryPolicy
+
+// TransactionOptions are the options used by WithTransaction. See sqlretry.TransactionOptions
+type TransactionOptions = sqlretry.TransactionOptions
+
+// DefaultRetryPolicy returns a retry policy which retries deadlocks and lock wait timeouts up to 3 times
+func DefaultRetryPolicy() RetryPolicy {
+	return sqlretry.DefaultRetryPolicy()
+}
+
+// MySQLErrorCode returns the MySQL error code of the given error or of one of the errors it wraps
+func MySQLErrorCode(err error) (uint16, bool) {
+	return sqlretry.MySQLErrorCode(err)
+}
+
+// WithTransaction executes the given function in a transaction which is retried on retryable MySQL errors. See
+// sqlretry.WithTransaction
+func WithTransaction(ctx context.Context, db sqltypes.CloudtrustDB, opts *TransactionOptions, fn func(tx sqltypes.Transaction) error) error {
+	return sqlretry.WithTransaction(ctx, db, opts, fn)
+}
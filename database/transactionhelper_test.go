@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/mock"
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMySQLErrorCode(t *testing.T) {
+	var deadlock = errors.New("Error 1213 (40001): Deadlock found when trying to get lock; try restarting transaction")
+
+	t.Run("Not a MySQL error", func(t *testing.T) {
+		var _, ok = MySQLErrorCode(errors.New("any error"))
+		assert.False(t, ok)
+	})
+	t.Run("Nil error", func(t *testing.T) {
+		var _, ok = MySQLErrorCode(nil)
+		assert.False(t, ok)
+	})
+	t.Run("MySQL error", func(t *testing.T) {
+		var code, ok = MySQLErrorCode(deadlock)
+		assert.True(t, ok)
+		assert.Equal(t, MySQLErrLockDeadlock, code)
+	})
+	t.Run("Old MySQL error format", func(t *testing.T) {
+		var code, ok = MySQLErrorCode(errors.New("Error 1205: Lock wait timeout exceeded"))
+		assert.True(t, ok)
+		assert.Equal(t, MySQLErrLockWaitTimeout, code)
+	})
+	t.Run("Wrapped MySQL error", func(t *testing.T) {
+		var code, ok = MySQLErrorCode(fmt.Errorf("can't update user: %w", deadlock))
+		assert.True(t, ok)
+		assert.Equal(t, MySQLErrLockDeadlock, code)
+	})
+}
+
+func TestWithTransaction(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockDB = mock.NewCloudtrustDB(mockCtrl)
+	var mockTx = mock.NewTransaction(mockCtrl)
+	var ctx = context.TODO()
+	var anyError = errors.New("any error")
+	var deadlock = errors.New("Error 1213 (40001): Deadlock found when trying to get lock; try restarting transaction")
+	var opts = &TransactionOptions{
+		RetryPolicy: RetryPolicy{
+			MaxAttempts:         3,
+			Backoff:             BackoffPolicy{InitialInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond, Multiplier: 2},
+			RetryableErrorCodes: []uint16{MySQLErrLockDeadlock},
+		},
+	}
+
+	t.Run("BeginTx fails", func(t *testing.T) {
+		mockDB.EXPECT().BeginTx(ctx, nil).Return(nil, anyError)
+		var err = WithTransaction(ctx, mockDB, opts, func(tx sqltypes.Transaction) error {
+			assert.Fail(t, "should not be called")
+			return nil
+		})
+		assert.Equal(t, anyError, err)
+	})
+	t.Run("Success", func(t *testing.T) {
+		mockDB.EXPECT().BeginTx(ctx, nil).Return(mockTx, nil)
+		mockTx.EXPECT().Exec("UPDATE table SET col=?", 1).Return(nil, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+		var err = WithTransaction(ctx, mockDB, opts, func(tx sqltypes.Transaction) error {
+			var _, err = tx.Exec("UPDATE table SET col=?", 1)
+			return err
+		})
+		assert.Nil(t, err)
+	})
+	t.Run("Non retryable error", func(t *testing.T) {
+		mockDB.EXPECT().BeginTx(ctx, nil).Return(mockTx, nil)
+		mockTx.EXPECT().Close().Return(nil)
+		var err = WithTransaction(ctx, mockDB, opts, func(tx sqltypes.Transaction) error {
+			return anyError
+		})
+		assert.Equal(t, anyError, err)
+	})
+	t.Run("Retryable error, then success", func(t *testing.T) {
+		var calls = 0
+		mockDB.EXPECT().BeginTx(ctx, nil).Return(mockTx, nil).Times(2)
+		mockTx.EXPECT().Close().Return(nil).Times(2)
+		mockTx.EXPECT().Commit().Return(nil)
+		var err = WithTransaction(ctx, mockDB, opts, func(tx sqltypes.Transaction) error {
+			calls++
+			if calls == 1 {
+				return deadlock
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, calls)
+	})
+	t.Run("Retryable error on commit, max attempts reached", func(t *testing.T) {
+		mockDB.EXPECT().BeginTx(ctx, nil).Return(mockTx, nil).Times(3)
+		mockTx.EXPECT().Commit().Return(deadlock).Times(3)
+		mockTx.EXPECT().Close().Return(nil).Times(3)
+		var err = WithTransaction(ctx, mockDB, opts, func(tx sqltypes.Transaction) error {
+			return nil
+		})
+		assert.Equal(t, deadlock, err)
+	})
+	t.Run("Retryable error, context cancelled", func(t *testing.T) {
+		var cancelledCtx, cancel = context.WithCancel(ctx)
+		cancel()
+		mockDB.EXPECT().BeginTx(cancelledCtx, nil).Return(mockTx, nil)
+		mockTx.EXPECT().Close().Return(nil)
+		var err = WithTransaction(cancelledCtx, mockDB, &TransactionOptions{RetryPolicy: RetryPolicy{
+			MaxAttempts:         3,
+			Backoff:             BackoffPolicy{InitialInterval: time.Hour},
+			RetryableErrorCodes: []uint16{MySQLErrLockDeadlock},
+		}}, func(tx sqltypes.Transaction) error {
+			return deadlock
+		})
+		assert.Equal(t, deadlock, err)
+	})
+	t.Run("Panic rolls back the transaction", func(t *testing.T) {
+		mockDB.EXPECT().BeginTx(ctx, nil).Return(mockTx, nil)
+		mockTx.EXPECT().Close().Return(nil)
+		assert.Panics(t, func() {
+			_ = WithTransaction(ctx, mockDB, opts, func(tx sqltypes.Transaction) error {
+				panic("unexpected")
+			})
+		})
+	})
+	t.Run("Default options", func(t *testing.T) {
+		mockDB.EXPECT().BeginTx(ctx, nil).Return(mockTx, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+		mockTx.EXPECT().Close().Return(nil)
+		assert.Nil(t, WithTransaction(ctx, mockDB, nil, func(tx sqltypes.Transaction) error {
+			return nil
+		}))
+	})
+	t.Run("Noop database", func(t *testing.T) {
+		assert.Nil(t, WithTransaction(ctx, &NoopDB{}, nil, func(tx sqltypes.Transaction) error {
+			var _, err = tx.Exec("UPDATE table SET col=?", 1)
+			return err
+		}))
+	})
+}
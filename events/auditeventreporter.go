@@ -31,13 +31,7 @@ func NewAuditEventReporterModule(producer Producer, logger log.Logger) AuditEven
 }
 
 func (e *auditEventsReporterModule) ReportEvent(ctx context.Context, event Event) {
-	serializedEvent := event.serialize()
-	base64Event := base64.StdEncoding.EncodeToString(serializedEvent)
-
-	key, ok := event.details[CtEventAgentUserID]
-	if !ok {
-		key = "DEFAULT-KEY"
-	}
+	key, base64Event := event.encode()
 
 	err := e.producer.SendPartitionedMessageBytes(key, []byte(base64Event))
 
@@ -53,3 +47,14 @@ func (e *auditEventsReporterModule) ReportEvent(ctx context.Context, event Event
 		}
 	}
 }
+
+// encode returns the partition key and the base64 encoded content of the Kafka message of an event
+func (e *Event) encode() (string, string) {
+	base64Event := base64.StdEncoding.EncodeToString(e.serialize())
+
+	key, ok := e.details[CtEventAgentUserID]
+	if !ok {
+		key = "DEFAULT-KEY"
+	}
+	return key, base64Event
+}
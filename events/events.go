@@ -94,6 +94,12 @@ func NewEventFromContext(ctx context.Context, logger log.Logger, origin string,
 	return NewEvent(origin, eventType, agentRealmName, agentUserID, agentUserName, targetRealmName, details)
 }
 
+// WithTime returns a copy of the event occurring at the given time
+func (e Event) WithTime(t time.Time) Event {
+	e.time = t.UTC()
+	return e
+}
+
 func extractAgentValueFromContext(ctx context.Context, logger log.Logger, key cs.CtContext) string {
 	value := ctx.Value(key)
 	if value == nil {
@@ -2,6 +2,7 @@ package events
 
 import (
 	"testing"
+	"time"
 
 	cs "github.com/cloudtrust/common-service/v2"
 	"github.com/cloudtrust/common-service/v2/events/fb"
@@ -272,3 +273,11 @@ func TestSerialize(t *testing.T) {
 	assert.Equal(t, targetRealmName, deserializedDetails[CtEventTargetRealmName])
 	assert.Equal(t, details["my_custom_field"], deserializedDetails["my_custom_field"])
 }
+
+func TestEventWithTime(t *testing.T) {
+	var eventTime = time.Date(2024, time.March, 5, 10, 20, 30, 0, time.UTC)
+	var e = NewEvent("test", "test_event", "TEST1", "testerID", "tester", "TEST2", nil).WithTime(eventTime)
+
+	var event = fb.GetRootAsCloudtrustEvent(e.serialize(), 0)
+	assert.Equal(t, eventTime.UnixMilli(), event.Time())
+}
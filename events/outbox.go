@@ -0,0 +1,218 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/sqltypes"
+	"github.com/cloudtrust/common-service/v2/log"
+)
+
+// The outbox requires the following table in the database of the service:
+//
+//	CREATE TABLE audit_outbox (
+//	  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+//	  partition_key VARCHAR(255) NOT NULL,
+//	  payload MEDIUMTEXT NOT NULL,
+//	  created_at DATETIME(3) NOT NULL,
+//	  sent_at DATETIME(3) NULL,
+//	  INDEX audit_outbox_pending_idx (sent_at, id)
+//	);
+//
+// Several relays can run (one per instance of the service) but a single one publishes at a time: events are published
+// while holding a named lock so that the events having the same partition key are published in their insertion order.
+// Pending rows are read with FOR UPDATE, without skipping locked rows: the relay waits for the transactions which inserted
+// events and are not committed yet, instead of publishing the events inserted after them first
+const (
+	insertOutboxEvent   = `INSERT INTO audit_outbox (partition_key, payload, created_at) VALUES (?, ?, ?)`
+	selectOutboxEvents  = `SELECT id, partition_key, payload FROM audit_outbox WHERE sent_at IS NULL ORDER BY id LIMIT ? FOR UPDATE`
+	markOutboxEventSent = `UPDATE audit_outbox SET sent_at=? WHERE id=?`
+	deleteOutboxEvent   = `DELETE FROM audit_outbox WHERE id=?`
+	// Lock names are server-wide: the lock is specific to the current database
+	getRelayLockStmt     = `SELECT GET_LOCK(CONCAT(IFNULL(DATABASE(), ''), '.audit_outbox'), 0)`
+	releaseRelayLockStmt = `SELECT RELEASE_LOCK(CONCAT(IFNULL(DATABASE(), ''), '.audit_outbox'))`
+
+	outboxTimeFormat       = "2006-01-02 15:04:05.000"
+	defaultOutboxBatchSize = 100
+	releaseLockTimeout     = 10 * time.Second
+)
+
+// AuditOutboxModule stores audit events in the transaction of the business change. They are published to Kafka by an
+// OutboxRelay once the transaction is committed
+type AuditOutboxModule interface {
+	ReportEvent(ctx context.Context, tx sqltypes.Transaction, event Event) error
+}
+
+type auditOutboxModule struct{}
+
+// NewAuditOutboxModule creates an instance of AuditOutboxModule
+func NewAuditOutboxModule() AuditOutboxModule {
+	return &auditOutboxModule{}
+}
+
+func (o *auditOutboxModule) ReportEvent(ctx context.Context, tx sqltypes.Transaction, event Event) error {
+	key, base64Event := event.encode()
+	_, err := tx.ExecContext(ctx, insertOutboxEvent, key, base64Event, event.time.UTC().Format(outboxTimeFormat))
+	return err
+}
+
+// OutboxOptions configures an outbox relay
+type OutboxOptions struct {
+	// BatchSize is the maximum number of events published within a single transaction. Defaults to 100
+	BatchSize int
+	// DeleteSent deletes the published events instead of marking them as sent
+	DeleteSent bool
+}
+
+type outboxEvent struct {
+	id      int64
+	key     string
+	payload string
+}
+
+// OutboxRelay publishes the events of the audit outbox through a Producer. Delivery is at-least-once: an event can be
+// published again if its row can't be updated after publication. Only one relay publishes at a time: the others skip their
+// turn while the lock of the outbox is held
+type OutboxRelay struct {
+	db       sqltypes.CloudtrustDB
+	producer Producer
+	options  OutboxOptions
+	logger   log.Logger
+	now      func() time.Time
+}
+
+// NewOutboxRelay creates an outbox relay
+func NewOutboxRelay(db sqltypes.CloudtrustDB, producer Producer, options OutboxOptions, logger log.Logger) *OutboxRelay {
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultOutboxBatchSize
+	}
+	return &OutboxRelay{
+		db:       db,
+		producer: producer,
+		options:  options,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// Relay publishes the pending events in their insertion order until the outbox is empty or a publication fails. It
+// returns the number of published events (0 when another relay is publishing)
+func (r *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	// The lock is owned by a connection: a transaction is used to keep the same connection until the lock is released
+	lockTx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer lockTx.Close()
+
+	if locked, err := r.acquireLock(ctx, lockTx); err != nil || !locked {
+		return 0, err
+	}
+	defer r.releaseLock(ctx, lockTx)
+
+	var total int
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		var count, err = r.relayBatch(ctx)
+		total += count
+		if err != nil || count < r.options.BatchSize {
+			return total, err
+		}
+	}
+}
+
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+
+	pending, err := r.pendingEvents(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	var sent int
+	var sendErr error
+	for _, event := range pending {
+		// Next events are not published to keep the order of the events
+		if sendErr = r.producer.SendPartitionedMessageBytes(event.key, []byte(event.payload)); sendErr != nil {
+			r.logger.Warn(ctx, "msg", "Can't publish audit event from outbox", "id", event.id, "err", sendErr.Error())
+			break
+		}
+		if r.options.DeleteSent {
+			_, err = tx.ExecContext(ctx, deleteOutboxEvent, event.id)
+		} else {
+			_, err = tx.ExecContext(ctx, markOutboxEventSent, r.now().UTC().Format(outboxTimeFormat), event.id)
+		}
+		if err != nil {
+			r.logger.Error(ctx, "msg", "Can't update audit outbox", "id", event.id, "err", err.Error())
+			return 0, err
+		}
+		sent++
+	}
+
+	if err = tx.Commit(); err != nil {
+		r.logger.Error(ctx, "msg", "Can't commit audit outbox update", "err", err.Error())
+		return 0, err
+	}
+	return sent, sendErr
+}
+
+// acquireLock takes the lock of the outbox without waiting. It returns false if another relay holds it
+func (r *OutboxRelay) acquireLock(ctx context.Context, lockTx sqltypes.Transaction) (bool, error) {
+	var locked sql.NullInt64
+	if err := lockTx.QueryRowContext(ctx, getRelayLockStmt).Scan(&locked); err != nil {
+		return false, err
+	}
+	return locked.Valid && locked.Int64 == 1, nil
+}
+
+// releaseLock releases the lock of the outbox even if ctx is done: the lock is owned by the connection which goes back to
+// the pool and would prevent the other relays from publishing
+func (r *OutboxRelay) releaseLock(ctx context.Context, lockTx sqltypes.Transaction) {
+	var releaseCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), releaseLockTimeout)
+	defer cancel()
+
+	var released sql.NullInt64
+	if err := lockTx.QueryRowContext(releaseCtx, releaseRelayLockStmt).Scan(&released); err != nil {
+		r.logger.Error(ctx, "msg", "Can't release audit outbox lock", "err", err.Error())
+	} else if !released.Valid || released.Int64 != 1 {
+		r.logger.Error(ctx, "msg", "Audit outbox lock was not held when releasing it")
+	}
+}
+
+func (r *OutboxRelay) pendingEvents(ctx context.Context, tx sqltypes.Transaction) ([]outboxEvent, error) {
+	rows, err := tx.QueryContext(ctx, selectOutboxEvents, r.options.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []outboxEvent
+	for rows.Next() {
+		var event outboxEvent
+		if err = rows.Scan(&event.id, &event.key, &event.payload); err != nil {
+			return nil, err
+		}
+		res = append(res, event)
+	}
+	return res, rows.Err()
+}
+
+// RelayLoop publishes the pending events each time the given channel ticks (usually time.NewTicker(interval).C) until
+// the context is done
+func (r *OutboxRelay) RelayLoop(ctx context.Context, ticks <-chan time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+			_, _ = r.Relay(ctx)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package events
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudtrust/common-service/v2/database/dbtest"
+	"github.com/cloudtrust/common-service/v2/events/fb"
+	"github.com/cloudtrust/common-service/v2/events/mock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAuditOutboxReportEvent(t *testing.T) {
+	var db = dbtest.NewFakeDB()
+	var ctx = context.TODO()
+	var event = NewEvent("test", "test_event", "TEST1", "testerID", "tester", "TEST2", nil).
+		WithTime(time.Date(2024, time.March, 5, 10, 20, 30, 0, time.UTC))
+
+	db.On("INSERT INTO audit_outbox")
+	var tx, _ = db.BeginTx(ctx, nil)
+	assert.Nil(t, NewAuditOutboxModule().ReportEvent(ctx, tx, event))
+
+	var statements = db.StatementsMatching("INSERT INTO audit_outbox")
+	assert.Len(t, statements, 1)
+	assert.Equal(t, 1, statements[0].Tx)
+	assert.Equal(t, "testerID", statements[0].Args[0])
+	assert.Equal(t, "2024-03-05 10:20:30.000", statements[0].Args[2])
+
+	var payload, err = base64.StdEncoding.DecodeString(statements[0].Args[1].(string))
+	assert.Nil(t, err)
+	var fbEvent = fb.GetRootAsCloudtrustEvent(payload, 0)
+	assert.Equal(t, "test_event", string(fbEvent.CtEventType()))
+	assert.Equal(t, event.time.UnixMilli(), fbEvent.Time())
+}
+
+func TestOutboxRelay(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var producer = mock.NewProducer(mockCtrl)
+	var logger = mock.NewLogger(mockCtrl)
+	var db = dbtest.NewFakeDB()
+	var ctx = context.TODO()
+	var now = time.Date(2024, time.March, 5, 10, 20, 30, 0, time.UTC)
+
+	var newRelay = func(options OutboxOptions) *OutboxRelay {
+		var relay = NewOutboxRelay(db, producer, options, logger)
+		relay.now = func() time.Time { return now }
+		return relay
+	}
+
+	var expectLock = func() {
+		db.On("^SELECT GET_LOCK").WithRows([]any{int64(1)})
+		db.On("^SELECT RELEASE_LOCK").WithRows([]any{int64(1)})
+	}
+
+	t.Run("Empty outbox", func(t *testing.T) {
+		db.Reset()
+		expectLock()
+		db.On("SELECT id, partition_key, payload FROM audit_outbox").WithArgs(100)
+
+		var count, err = newRelay(OutboxOptions{}).Relay(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count)
+		assert.True(t, db.Transactions()[1].IsCommitted())
+		// The lock is released after the commit, with the connection which acquired it
+		var statements = db.Statements()
+		assert.Equal(t, 1, statements[0].Tx)
+		assert.Contains(t, statements[len(statements)-1].Query, "RELEASE_LOCK")
+		assert.Equal(t, 1, statements[len(statements)-1].Tx)
+	})
+
+	t.Run("Another relay is publishing", func(t *testing.T) {
+		db.Reset()
+		db.On("^SELECT GET_LOCK").WithRows([]any{int64(0)})
+
+		var count, err = newRelay(OutboxOptions{}).Relay(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count)
+		assert.Len(t, db.StatementsMatching("FROM audit_outbox"), 0)
+		assert.Len(t, db.StatementsMatching("RELEASE_LOCK"), 0)
+	})
+
+	t.Run("Lock release fails", func(t *testing.T) {
+		var dbErr = errors.New("db error")
+		db.Reset()
+		db.On("^SELECT GET_LOCK").WithRows([]any{int64(1)})
+		db.On("^SELECT RELEASE_LOCK").WithError(dbErr)
+		db.On("SELECT id, partition_key, payload FROM audit_outbox")
+		logger.EXPECT().Error(ctx, "msg", "Can't release audit outbox lock", "err", "db error")
+
+		var _, err = newRelay(OutboxOptions{}).Relay(ctx)
+		assert.Nil(t, err)
+	})
+
+	t.Run("Events are published and marked as sent by batches", func(t *testing.T) {
+		db.Reset()
+		expectLock()
+		db.On("SELECT id, partition_key, payload FROM audit_outbox").WithRows([]any{1, "key1", "payload1"}, []any{2, "key2", "payload2"}).Once()
+		db.On("SELECT id, partition_key, payload FROM audit_outbox").WithRows([]any{3, "key1", "payload3"}).Once()
+		db.On("UPDATE audit_outbox SET sent_at")
+		gomock.InOrder(
+			producer.EXPECT().SendPartitionedMessageBytes("key1", []byte("payload1")).Return(nil),
+			producer.EXPECT().SendPartitionedMessageBytes("key2", []byte("payload2")).Return(nil),
+			producer.EXPECT().SendPartitionedMessageBytes("key1", []byte("payload3")).Return(nil),
+		)
+
+		var count, err = newRelay(OutboxOptions{BatchSize: 2}).Relay(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, count)
+
+		var updates = db.StatementsMatching("UPDATE audit_outbox")
+		assert.Len(t, updates, 3)
+		assert.Equal(t, []any{"2024-03-05 10:20:30.000", int64(1)}, updates[0].Args)
+		assert.Len(t, db.Transactions(), 3)
+		assert.True(t, db.Transactions()[2].IsCommitted())
+	})
+
+	t.Run("Pending events are read in their insertion order without skipping locked ones", func(t *testing.T) {
+		db.Reset()
+		expectLock()
+		db.On("SELECT id, partition_key, payload FROM audit_outbox")
+
+		var _, err = newRelay(OutboxOptions{}).Relay(ctx)
+		assert.Nil(t, err)
+		var selects = db.StatementsMatching("SELECT id, partition_key, payload FROM audit_outbox")
+		assert.Len(t, selects, 1)
+		// A locked row is an event whose transaction is not committed yet: it must be published before the next ones
+		assert.True(t, strings.HasSuffix(selects[0].Query, "ORDER BY id LIMIT ? FOR UPDATE"), selects[0].Query)
+		assert.NotContains(t, selects[0].Query, "SKIP LOCKED")
+	})
+
+	t.Run("Sent events are deleted", func(t *testing.T) {
+		db.Reset()
+		expectLock()
+		db.On("SELECT id, partition_key, payload FROM audit_outbox").WithRows([]any{1, "key1", "payload1"})
+		db.On("DELETE FROM audit_outbox").WithArgs(int64(1))
+		producer.EXPECT().SendPartitionedMessageBytes("key1", []byte("payload1")).Return(nil)
+
+		var count, err = newRelay(OutboxOptions{DeleteSent: true}).Relay(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		assert.Len(t, db.StatementsMatching("DELETE FROM audit_outbox"), 1)
+	})
+
+	t.Run("Publication fails: next events are kept", func(t *testing.T) {
+		var kafkaErr = errors.New("kafka error")
+		db.Reset()
+		expectLock()
+		db.On("SELECT id, partition_key, payload FROM audit_outbox").WithRows([]any{1, "key1", "payload1"}, []any{2, "key2", "payload2"})
+		db.On("UPDATE audit_outbox SET sent_at")
+		producer.EXPECT().SendPartitionedMessageBytes("key1", []byte("payload1")).Return(kafkaErr)
+		logger.EXPECT().Warn(ctx, "msg", "Can't publish audit event from outbox", "id", int64(1), "err", "kafka error")
+
+		var count, err = newRelay(OutboxOptions{}).Relay(ctx)
+		assert.Equal(t, kafkaErr, err)
+		assert.Equal(t, 0, count)
+		assert.Len(t, db.StatementsMatching("UPDATE audit_outbox"), 0)
+	})
+
+	t.Run("Update fails: transaction is rolled back", func(t *testing.T) {
+		var dbErr = errors.New("db error")
+		db.Reset()
+		expectLock()
+		db.On("SELECT id, partition_key, payload FROM audit_outbox").WithRows([]any{1, "key1", "payload1"})
+		db.On("UPDATE audit_outbox SET sent_at").WithError(dbErr)
+		producer.EXPECT().SendPartitionedMessageBytes("key1", []byte("payload1")).Return(nil)
+		logger.EXPECT().Error(ctx, "msg", "Can't update audit outbox", "id", int64(1), "err", "db error")
+
+		var _, err = newRelay(OutboxOptions{}).Relay(ctx)
+		assert.Equal(t, dbErr, err)
+		assert.True(t, db.Transactions()[1].IsRolledBack())
+	})
+
+	t.Run("Commit fails", func(t *testing.T) {
+		var dbErr = errors.New("commit error")
+		db.Reset()
+		expectLock()
+		db.On("SELECT id, partition_key, payload FROM audit_outbox").WithRows([]any{1, "key1", "payload1"})
+		db.On("UPDATE audit_outbox SET sent_at")
+		db.FailCommit(dbErr)
+		producer.EXPECT().SendPartitionedMessageBytes("key1", []byte("payload1")).Return(nil)
+		logger.EXPECT().Error(ctx, "msg", "Can't commit audit outbox update", "err", "commit error")
+
+		var count, err = newRelay(OutboxOptions{}).Relay(ctx)
+		assert.Equal(t, dbErr, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("Can't begin transaction", func(t *testing.T) {
+		var dbErr = errors.New("begin error")
+		db.Reset()
+		db.FailBeginTx(dbErr)
+
+		var _, err = newRelay(OutboxOptions{}).Relay(ctx)
+		assert.Equal(t, dbErr, err)
+	})
+
+	t.Run("Relay loop", func(t *testing.T) {
+		db.Reset()
+		expectLock()
+		db.On("SELECT id, partition_key, payload FROM audit_outbox")
+		var ticks = make(chan time.Time)
+		var loopCtx, cancel = context.WithCancel(ctx)
+		var done = make(chan struct{})
+		go func() {
+			newRelay(OutboxOptions{}).RelayLoop(loopCtx, ticks)
+			close(done)
+		}()
+		ticks <- now
+		ticks <- now
+		cancel()
+		<-done
+		assert.GreaterOrEqual(t, len(db.StatementsMatching("SELECT id")), 1)
+	})
+}
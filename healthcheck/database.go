@@ -7,6 +7,12 @@ type HealthDatabase interface {
 	Ping() error
 }
 
+// CircuitBreakerDatabase is implemented by databases which stop reaching their server while it is known to be down
+// (closed, open or half-open circuit)
+type CircuitBreakerDatabase interface {
+	CircuitState() string
+}
+
 type databaseChecker struct {
 	alias    string
 	dbase    HealthDatabase
@@ -30,6 +36,12 @@ func (dbc *databaseChecker) CheckStatus() HealthStatus {
 
 	err := dbc.dbase.Ping()
 
+	dbc.response.Circuit = nil
+	if cb, ok := dbc.dbase.(CircuitBreakerDatabase); ok {
+		var state = cb.CircuitState()
+		dbc.response.Circuit = &state
+	}
+
 	if err != nil {
 		dbc.response.stateDown(err.Error())
 	} else {
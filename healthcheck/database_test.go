@@ -15,6 +15,7 @@ func TestDbHealthCheck(t *testing.T) {
 	defer mockCtrl.Finish()
 
 	var mockDB = mock.NewHealthDatabase(mockCtrl)
+	var mockCircuit = mock.NewCircuitBreakerDatabase(mockCtrl)
 	var mockTime = mock.NewTimeProvider(mockCtrl)
 	mockTime.EXPECT().Now().Return(testTime).AnyTimes()
 
@@ -41,4 +42,20 @@ func TestDbHealthCheck(t *testing.T) {
 		res = dbChecker.CheckStatus()
 		assert.Equal(t, errMsg, *res.Message)
 	}
+
+	{
+		var dbChecker = newDatabaseChecker("alias", circuitBreakerDB{mockDB, mockCircuit}, 10*time.Second, mockTime)
+		mockDB.EXPECT().Ping().Return(errors.New("database unavailable: circuit breaker is open"))
+		mockCircuit.EXPECT().CircuitState().Return("open")
+
+		var res = dbChecker.CheckStatus()
+		assert.Equal(t, "DOWN", *res.State)
+		assert.NotNil(t, res.Circuit)
+		assert.Equal(t, "open", *res.Circuit)
+	}
+}
+
+type circuitBreakerDB struct {
+	*mock.HealthDatabase
+	*mock.CircuitBreakerDatabase
 }
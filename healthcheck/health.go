@@ -3,6 +3,8 @@ package healthcheck
 import (
 	"context"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/cloudtrust/common-service/v2/events"
@@ -19,15 +21,22 @@ type HealthChecker interface {
 	AddHTTPEndpoints(endpoints map[string]string, timeoutDuration time.Duration, expectedStatus int, cacheDuration time.Duration)
 	AddDatabase(name string, db HealthDatabase, cacheDuration time.Duration)
 	AddAuditEventsReporterModule(name string, reporter events.AuditEventsReporterModule, timeout time.Duration, cacheDuration time.Duration)
+	RemoveHealthChecker(name string)
 	MakeHandler(rateLimit ratelimit.Allower) http.HandlerFunc
 }
 
 type healthchecker struct {
 	name     string
-	checkers []BasicChecker
+	mutex    sync.RWMutex
+	checkers []namedChecker
 	logger   log.Logger
 }
 
+type namedChecker struct {
+	name    string
+	checker BasicChecker
+}
+
 // BasicChecker is a basic health check processor
 type BasicChecker interface {
 	CheckStatus() HealthStatus
@@ -48,6 +57,7 @@ type HealthStatus struct {
 	State         *string       `json:"state,omitempty"`
 	Message       *string       `json:"message,omitempty"`
 	Connection    *string       `json:"connection,omitempty"`
+	Circuit       *string       `json:"circuit,omitempty"`
 	ValideUntil   time.Time     `json:"-"`
 	CacheDuration time.Duration `json:"-"`
 	TimeProvider  TimeProvider  `json:"-"`
@@ -93,12 +103,16 @@ func (hc *healthchecker) CheckStatus() HealthResponse {
 		State:   "UP",
 		Healthy: true,
 	}
+	hc.mutex.RLock()
+	var checkers = append([]namedChecker(nil), hc.checkers...)
+	hc.mutex.RUnlock()
+
 	// Channel to collect dependencies health status
-	results := make(chan HealthStatus, len(hc.checkers))
-	for _, checker := range hc.checkers {
-		go hc.execHealthChecker(checker, results)
+	results := make(chan HealthStatus, len(checkers))
+	for _, checker := range checkers {
+		go hc.execHealthChecker(checker.checker, results)
 	}
-	for range hc.checkers {
+	for range checkers {
 		status := <-results
 		if *status.State == "DOWN" {
 			res.Healthy = false
@@ -115,7 +129,19 @@ func (hc *healthchecker) execHealthChecker(checker BasicChecker, results chan<-
 }
 
 func (hc *healthchecker) AddHealthChecker(name string, checker BasicChecker) {
-	hc.checkers = append(hc.checkers, checker)
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+	hc.checkers = append(hc.checkers, namedChecker{name: name, checker: checker})
+}
+
+// RemoveHealthChecker removes the health checks added with the given name
+func (hc *healthchecker) RemoveHealthChecker(name string) {
+	hc.logger.Info(context.Background(), "msg", "Removing health checker", "processor", name)
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+	hc.checkers = slices.DeleteFunc(hc.checkers, func(checker namedChecker) bool {
+		return checker.name == name
+	})
 }
 
 func (hc *healthchecker) AddHTTPEndpoint(name string, targetURL string, timeoutDuration time.Duration, expectedStatus int, cacheDuration time.Duration) {
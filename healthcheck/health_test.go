@@ -90,6 +90,25 @@ func TestHealthCheckHandler(t *testing.T) {
 	})
 }
 
+func TestRemoveHealthChecker(t *testing.T) {
+	var mockCtrl = gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var mockDB = mock.NewHealthDatabase(mockCtrl)
+	var hc = NewHealthChecker("test-module", log.NewNopLogger())
+	hc.AddDatabase("db1", mockDB, 0)
+	hc.AddDatabase("db2", mockDB, 0)
+
+	hc.RemoveHealthChecker("db1")
+	hc.RemoveHealthChecker("unknown")
+
+	// The removed database is not pinged anymore
+	mockDB.EXPECT().Ping().Return(nil).Times(1)
+	var res = hc.CheckStatus()
+	assert.Len(t, res.Details, 1)
+	assert.Equal(t, "db2", *res.Details[0].Name)
+}
+
 func httpGet(targetURL string) (string, int, error) {
 	res, err := http.Get(targetURL)
 	if err != nil {
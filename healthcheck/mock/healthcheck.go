@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/cloudtrust/common-service/v2/healthcheck (interfaces: HealthDatabase)
+// Source: github.com/cloudtrust/common-service/v2/healthcheck (interfaces: HealthDatabase,CircuitBreakerDatabase)
 //
 // Generated by this command:
 //
-//	mockgen --build_flags=--mod=mod -destination=./mock/healthcheck.go -package=mock -mock_names=HealthDatabase=HealthDatabase github.com/cloudtrust/common-service/v2/healthcheck HealthDatabase
+//	mockgen --build_flags=--mod=mod -destination=./mock/healthcheck.go -package=mock -mock_names=HealthDatabase=HealthDatabase,CircuitBreakerDatabase=CircuitBreakerDatabase github.com/cloudtrust/common-service/v2/healthcheck HealthDatabase,CircuitBreakerDatabase
 //
 
 // Package mock is a generated GoMock package.
@@ -52,3 +52,41 @@ func (mr *HealthDatabaseMockRecorder) Ping() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*HealthDatabase)(nil).Ping))
 }
+
+// CircuitBreakerDatabase is a mock of CircuitBreakerDatabase interface.
+type CircuitBreakerDatabase struct {
+	ctrl     *gomock.Controller
+	recorder *CircuitBreakerDatabaseMockRecorder
+	isgomock struct{}
+}
+
+// CircuitBreakerDatabaseMockRecorder is the mock recorder for CircuitBreakerDatabase.
+type CircuitBreakerDatabaseMockRecorder struct {
+	mock *CircuitBreakerDatabase
+}
+
+// NewCircuitBreakerDatabase creates a new mock instance.
+func NewCircuitBreakerDatabase(ctrl *gomock.Controller) *CircuitBreakerDatabase {
+	mock := &CircuitBreakerDatabase{ctrl: ctrl}
+	mock.recorder = &CircuitBreakerDatabaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *CircuitBreakerDatabase) EXPECT() *CircuitBreakerDatabaseMockRecorder {
+	return m.recorder
+}
+
+// CircuitState mocks base method.
+func (m *CircuitBreakerDatabase) CircuitState() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CircuitState")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CircuitState indicates an expected call of CircuitState.
+func (mr *CircuitBreakerDatabaseMockRecorder) CircuitState() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CircuitState", reflect.TypeOf((*CircuitBreakerDatabase)(nil).CircuitState))
+}
@@ -6,8 +6,8 @@ import (
 	"context"
 	"testing"
 
-	"github.com/cloudtrust/common-service/v2/database/mock"
 	"github.com/cloudtrust/common-service/v2/log"
+	"github.com/cloudtrust/common-service/v2/metrics/mock"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 )